@@ -4,10 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"math"
 	"net"
 
 	"github.com/haikali3/gymbara-backend/internal/database"
 	pb "github.com/haikali3/gymbara-backend/pkg/proto"
+	"github.com/haikali3/gymbara-backend/pkg/training"
 	"github.com/haikali3/gymbara-backend/pkg/utils"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
@@ -33,11 +35,12 @@ func (s *workoutServer) GetWorkoutHistory(ctx context.Context, req *pb.WorkoutHi
 
 	// Query user workout history
 	query := `
-    SELECT ued.exercise_id, e.name, ued.custom_reps, ued.custom_load, ued.submitted_at
+    SELECT ued.exercise_id, e.name, ued.custom_reps, COALESCE(ued.custom_load, 0), ued.submitted_at, u.preferred_unit
     FROM UserExercisesDetails ued
     JOIN Exercises e ON ued.exercise_id = e.id
     JOIN UserWorkouts uw ON ued.user_workout_id = uw.id
-    WHERE uw.user_id = $1 AND ued.submitted_at BETWEEN $2 AND $3
+    JOIN Users u ON u.id = uw.user_id
+    WHERE ued.user_id = $1 AND ued.submitted_at BETWEEN $2 AND $3 AND ued.deleted_at IS NULL
     ORDER BY ued.submitted_at ASC
   `
 	utils.Logger.Info("Executing query", zap.String("query", query))
@@ -57,12 +60,16 @@ func (s *workoutServer) GetWorkoutHistory(ctx context.Context, req *pb.WorkoutHi
 	for rows.Next() {
 		var record pb.WorkoutRecord
 		var submittedAt sql.NullTime
+		var customLoad float64
+		var unit string
 
-		err := rows.Scan(&record.ExerciseId, &record.ExerciseName, &record.CustomReps, &record.CustomLoad, &submittedAt)
+		err := rows.Scan(&record.ExerciseId, &record.ExerciseName, &record.CustomReps, &customLoad, &submittedAt, &unit)
 		if err != nil {
 			utils.Logger.Error("Error scanning row", zap.Error(err))
 			return nil, err
 		}
+		// loads are stored in kg, custom_load is a whole number in the user's unit
+		record.CustomLoad = int32(math.Round(training.FromKg(customLoad, unit)))
 
 		if submittedAt.Valid {
 			record.SubmittedAt = timestamppb.New(submittedAt.Time)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"os"
@@ -78,13 +79,18 @@ func main() {
 
 	loadEnv()
 
-	// ⚙️ Initialize Google OAuth BEFORE you register any handlers
-	if err := auth.InitializeOAuthConfig(); err != nil {
+	// ⚙️ Initialize identity providers BEFORE you register any handlers
+	if err := auth.InitializeProviders(context.Background()); err != nil {
 		utils.Logger.Fatal("Failed to init OAuth config", zap.Error(err))
 	}
 
 	cfg := config.LoadConfig()
 
+	// session tokens are signed and verified locally with keys from config
+	if err := auth.InitializeSessionKeys(cfg); err != nil {
+		utils.Logger.Fatal("Failed to init session keys", zap.Error(err))
+	}
+
 	utils.Logger.Info("Starting Gymbara backend",
 		zap.String("environment", os.Getenv("APP_ENV")),
 		zap.String("backend_base_url", os.Getenv("BACKEND_BASE_URL")),
@@ -0,0 +1,65 @@
+// promote_admin grants a role to an existing user, used to bootstrap the first
+// admin. The user must have signed in at least once.
+//
+//	go run ./cmd/promote_admin -email you@example.com
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"github.com/haikali3/gymbara-backend/config"
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the user to promote")
+	role := flag.String("role", string(models.RoleAdmin), "role to grant: user, coach or admin")
+	flag.Parse()
+
+	utils.InitializeLogger()
+	defer func() {
+		if err := utils.SyncLogger(); err != nil {
+			utils.Logger.Error("Failed to sync logger", zap.Error(err))
+		}
+	}()
+
+	if *email == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if !models.Role(*role).Valid() {
+		utils.Logger.Fatal("Unknown role", zap.String("role", *role))
+	}
+
+	env := os.Getenv("APP_ENV")
+	if env == "" {
+		env = "development"
+	}
+	// variables may also come from the shell, so a missing file is fine
+	if err := godotenv.Load(".env." + env); err != nil {
+		utils.Logger.Warn("Environment file not loaded", zap.String("file", ".env."+env), zap.Error(err))
+	}
+
+	database.Connect(config.LoadConfig())
+	defer database.Close()
+
+	userID, err := database.SetUserRoleByEmail(*email, models.Role(*role))
+	if errors.Is(err, database.ErrUserNotFound) {
+		utils.Logger.Fatal("No user with this email, sign in once before promoting", zap.String("email", *email))
+	}
+	if err != nil {
+		utils.Logger.Fatal("Failed to promote user", zap.Error(err))
+	}
+
+	utils.Logger.Info("User promoted",
+		zap.Int("user_id", userID),
+		zap.String("email", *email),
+		zap.String("role", *role),
+	)
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -20,10 +21,18 @@ type Config struct {
 	DBMaxIdleConns    int
 	DBConnMaxLifetime time.Duration
 	DBConnMaxIdleTime time.Duration
+
+	// Session token signing. Every configured key can verify tokens, only the
+	// active key signs new ones, so old keys can be kept around during rotation.
+	SessionSigningKeys map[string]string
+	SessionActiveKeyID string
+	SessionTTL         time.Duration
+	RefreshTokenTTL    time.Duration
 }
 
 // LoadConfig loads environment variables and returns a Config struct
 func LoadConfig() *Config {
+	signingKeys, firstKeyID := getEnvAsKeyMap("SESSION_SIGNING_KEYS")
 
 	return &Config{
 		DBHost:     getEnv("DB_HOST", "localhost"),
@@ -38,6 +47,12 @@ func LoadConfig() *Config {
 		DBMaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
 		DBConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
 		DBConnMaxIdleTime: getEnvAsDuration("DB_CONN_MAX_IDLE_TIME", 1*time.Minute),
+
+		// Session configuration
+		SessionSigningKeys: signingKeys,
+		SessionActiveKeyID: getEnv("SESSION_ACTIVE_KEY_ID", firstKeyID),
+		SessionTTL:         getEnvAsDuration("SESSION_TTL", 15*time.Minute),
+		RefreshTokenTTL:    getEnvAsDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
 	}
 }
 
@@ -67,3 +82,20 @@ func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+// parses "kid1:secret1,kid2:secret2" into a map and returns the first key ID
+func getEnvAsKeyMap(key string) (map[string]string, string) {
+	keys := make(map[string]string)
+	firstKeyID := ""
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		kid, secret, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if !found || kid == "" || secret == "" {
+			continue
+		}
+		if firstKeyID == "" {
+			firstKeyID = kid
+		}
+		keys[kid] = secret
+	}
+	return keys, firstKeyID
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// API key scopes
+const (
+	// APIKeyScopeRead allows read-only requests such as workouts and progress
+	APIKeyScopeRead = "read"
+	// APIKeyScopeLogsWrite allows submitting workout logs
+	APIKeyScopeLogsWrite = "logs:write"
+)
+
+// every personal API key starts with this, so leaked keys are easy to spot
+const apiKeyPrefix = "gym_"
+
+// characters of the key kept in clear text to tell keys apart
+const apiKeyDisplayLength = 12
+
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// APIKeyClaims is who an API key acts for and what it may do
+type APIKeyClaims struct {
+	KeyID  int
+	UserID int
+	Email  string
+	Scopes []string
+}
+
+// HasScope reports whether the key was granted scope
+func (c *APIKeyClaims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidAPIKeyScope reports whether scope is one keys can be granted
+func IsValidAPIKeyScope(scope string) bool {
+	return scope == APIKeyScopeRead || scope == APIKeyScopeLogsWrite
+}
+
+// IsAPIKey tells API keys apart from other bearer tokens
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+// GenerateAPIKey creates a new key, returning the key to show once, its
+// display prefix and the hash to store
+func GenerateAPIKey() (key, prefix, keyHash string, err error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", "", "", err
+	}
+	key = apiKeyPrefix + secret
+	return key, key[:apiKeyDisplayLength], hashToken(key), nil
+}
+
+// ValidateAPIKey looks up an active API key and records that it was used
+func ValidateAPIKey(key string) (*APIKeyClaims, error) {
+	if !IsAPIKey(key) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	keyID, userID, email, scopes, err := database.GetActiveAPIKey(hashToken(key))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		utils.Logger.Error("Failed to look up api key", zap.Error(err))
+		return nil, err
+	}
+
+	// failures must not block the request
+	if err := database.TouchAPIKey(keyID); err != nil {
+		utils.Logger.Warn("Failed to update api key last used", zap.Int("api_key_id", keyID), zap.Error(err))
+	}
+
+	return &APIKeyClaims{KeyID: keyID, UserID: userID, Email: email, Scopes: scopes}, nil
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// marks an OAuth round trip as a link request, holds the state it belongs to
+const linkCookieName = "oauthlink"
+
+// LinkHandler starts linking /oauth/{provider}/link to the signed-in user.
+// The provider callback sees the link cookie and attaches the identity
+// instead of signing in.
+func LinkHandler(w http.ResponseWriter, r *http.Request) {
+	provider, ok := GetProvider(r.PathValue("provider"))
+	if !ok {
+		http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		return
+	}
+
+	accessToken, err := r.Cookie("access_token")
+	if err != nil {
+		http.Error(w, "Access token not found", http.StatusUnauthorized)
+		return
+	}
+	claims, err := ValidateToken(accessToken.Value)
+	if err != nil {
+		http.Error(w, "Invalid access token", http.StatusUnauthorized)
+		return
+	}
+
+	oauthStateString := GenerateStateOAuthCookie(w)
+	http.SetCookie(w, &http.Cookie{
+		Name:     linkCookieName,
+		Value:    oauthStateString,
+		Expires:  time.Now().Add(10 * time.Minute),
+		Path:     "/oauth",
+		Secure:   true,
+		HttpOnly: true,
+	})
+
+	utils.Logger.Info("Redirecting to OAuth URL to link identity",
+		zap.String("provider", provider.Name()),
+		zap.Int("user_id", claims.UserID),
+	)
+	http.Redirect(w, r, provider.AuthCodeURL(oauthStateString), http.StatusTemporaryRedirect)
+}
+
+// attaches the identity to the user of the current session
+func completeLink(w http.ResponseWriter, r *http.Request, identity models.OAuthIdentity) {
+	// the session is checked again, it may have ended during the round trip
+	accessToken, err := r.Cookie("access_token")
+	if err != nil {
+		http.Error(w, "Sign in again to link this account", http.StatusUnauthorized)
+		return
+	}
+	claims, err := ValidateToken(accessToken.Value)
+	if err != nil {
+		http.Error(w, "Sign in again to link this account", http.StatusUnauthorized)
+		return
+	}
+
+	if err := database.LinkIdentity(claims.UserID, identity); err != nil {
+		if errors.Is(err, database.ErrIdentityLinked) {
+			utils.Logger.Warn("Identity already linked to another user",
+				zap.Int("user_id", claims.UserID),
+				zap.String("provider", identity.Provider),
+			)
+			http.Error(w, "This account is already linked to another user", http.StatusConflict)
+			return
+		}
+		utils.Logger.Error("Error linking identity", zap.Error(err))
+		http.Error(w, "Failed to link account", http.StatusInternalServerError)
+		return
+	}
+
+	utils.Logger.Info("Identity linked",
+		zap.Int("user_id", claims.UserID),
+		zap.String("provider", identity.Provider),
+	)
+	http.Redirect(w, r, getFrontendURL(), http.StatusSeeOther)
+}
+
+// a link callback carries the link cookie set for this exact state
+func isLinkCallback(r *http.Request) bool {
+	linkCookie, err := r.Cookie(linkCookieName)
+	return err == nil && linkCookie.Value != "" && linkCookie.Value == r.FormValue("state")
+}
+
+func clearLinkCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     linkCookieName,
+		Value:    "",
+		MaxAge:   -1,
+		Path:     "/oauth",
+		Secure:   true,
+		HttpOnly: true,
+	})
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// LogoutHandler revokes the session of this device and clears its cookies
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, sessionID, ok := sessionFromCookies(r)
+	if !ok {
+		// nothing to revoke server-side, still make sure the browser forgets it
+		clearSessionCookies(w)
+		utils.WriteStandardResponse(w, http.StatusOK, "Logged out", nil)
+		return
+	}
+
+	// a session that is already revoked is as good as logged out
+	if err := database.RevokeSession(userID, sessionID); err != nil && !errors.Is(err, database.ErrSessionNotFound) {
+		utils.Logger.Error("Failed to revoke session", zap.Int("session_id", sessionID), zap.Error(err))
+		utils.WriteStandardResponse(w, http.StatusInternalServerError, "Failed to log out", nil)
+		return
+	}
+
+	clearSessionCookies(w)
+	utils.Logger.Info("User logged out", zap.Int("user_id", userID), zap.Int("session_id", sessionID))
+	utils.WriteStandardResponse(w, http.StatusOK, "Logged out", nil)
+}
+
+// LogoutAllHandler revokes every session of the user, on every device
+func LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// signing out everywhere needs a live session, not just a leftover cookie
+	accessToken, err := r.Cookie("access_token")
+	if err != nil {
+		utils.WriteStandardResponse(w, http.StatusUnauthorized, "Access token not found", nil)
+		return
+	}
+	claims, err := ValidateToken(accessToken.Value)
+	if err != nil {
+		utils.WriteStandardResponse(w, http.StatusUnauthorized, "Invalid access token", nil)
+		return
+	}
+
+	if err := database.RevokeAllSessions(claims.UserID); err != nil {
+		utils.Logger.Error("Failed to revoke all sessions", zap.Int("user_id", claims.UserID), zap.Error(err))
+		utils.WriteStandardResponse(w, http.StatusInternalServerError, "Failed to log out", nil)
+		return
+	}
+
+	clearSessionCookies(w)
+	utils.Logger.Info("User logged out of all devices", zap.Int("user_id", claims.UserID))
+	utils.WriteStandardResponse(w, http.StatusOK, "Logged out of all devices", nil)
+}
+
+// identifies the session of this device from the session cookie, falling back
+// to the refresh cookie once the session token has expired
+func sessionFromCookies(r *http.Request) (userID, sessionID int, ok bool) {
+	if accessToken, err := r.Cookie("access_token"); err == nil && sessionKeyring != nil {
+		claims, err := sessionKeyring.Verify(accessToken.Value, time.Now())
+		if err == nil || errors.Is(err, ErrExpiredToken) {
+			return claims.UserID, claims.SessionID, true
+		}
+	}
+
+	if refreshCookie, err := r.Cookie(refreshCookieName); err == nil && refreshCookie.Value != "" {
+		userID, sessionID, err := sessionForRefreshToken(refreshCookie.Value)
+		if err == nil {
+			return userID, sessionID, true
+		}
+	}
+
+	return 0, 0, false
+}
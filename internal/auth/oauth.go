@@ -6,14 +6,13 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/haikali3/gymbara-backend/internal/database"
-	"github.com/haikali3/gymbara-backend/pkg/models"
 	"github.com/haikali3/gymbara-backend/pkg/utils"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
@@ -29,7 +28,8 @@ const (
 // GoogleOauthConfig stores OAuth2 configuration
 var GoogleOauthConfig *oauth2.Config
 
-// initializes the OAuth configuration using env variables
+// initializes the Google OAuth configuration using env variables and registers
+// it as the "google" provider
 func InitializeOAuthConfig() error {
 	backendBaseURL := os.Getenv("BACKEND_BASE_URL")
 	if backendBaseURL == "" {
@@ -39,10 +39,12 @@ func InitializeOAuthConfig() error {
 	GoogleOauthConfig = &oauth2.Config{
 		ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
 		ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-		RedirectURL:  backendBaseURL + "/oauth/callback",
-		Scopes:       []string{ScopeUserInfoProfile, ScopeUserInfoEmail},
-		Endpoint:     google.Endpoint,
+		// Google keeps the original callback so existing console settings still work
+		RedirectURL: backendBaseURL + "/oauth/callback",
+		Scopes:      []string{ScopeUserInfoProfile, ScopeUserInfoEmail},
+		Endpoint:    google.Endpoint,
 	}
+	RegisterProvider(newGoogleProvider(GoogleOauthConfig))
 
 	utils.Logger.Info("OAuth configuration initialized",
 		zap.String("redirect_url", GoogleOauthConfig.RedirectURL),
@@ -71,20 +73,49 @@ func GenerateStateOAuthCookie(w http.ResponseWriter) string {
 
 // redirects the user to Google’s OAuth login
 func GoogleLoginHandler(w http.ResponseWriter, r *http.Request) {
-	if GoogleOauthConfig == nil {
-		if err := InitializeOAuthConfig(); err != nil {
-			utils.Logger.Fatal("Failed to initialize OAuth config", zap.Error(err))
-		}
+	ensureConfig()
+	beginLogin(w, r, "google")
+}
+
+// handles Google OAuth callback and stores user info
+func GoogleCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	completeLogin(w, r, "google")
+}
+
+// LoginHandler redirects the user to the login page of /oauth/{provider}/login
+func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	beginLogin(w, r, r.PathValue("provider"))
+}
+
+// CallbackHandler handles /oauth/{provider}/callback and signs the user in
+func CallbackHandler(w http.ResponseWriter, r *http.Request) {
+	completeLogin(w, r, r.PathValue("provider"))
+}
+
+func beginLogin(w http.ResponseWriter, r *http.Request, providerName string) {
+	provider, ok := GetProvider(providerName)
+	if !ok {
+		http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		return
 	}
 
 	oauthStateString := GenerateStateOAuthCookie(w)
-	authURL := GoogleOauthConfig.AuthCodeURL(oauthStateString, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
-	utils.Logger.Info("Redirecting to Google OAuth URL", zap.String("auth_url", authURL))
+	authURL := provider.AuthCodeURL(oauthStateString)
+	utils.Logger.Info("Redirecting to OAuth URL",
+		zap.String("provider", providerName),
+		zap.String("auth_url", authURL),
+	)
 	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
 }
 
-// handles Google OAuth callback and stores user info
-func GoogleCallbackHandler(w http.ResponseWriter, r *http.Request) {
+// exchanges the code, stores the user and starts a Gymbara session
+func completeLogin(w http.ResponseWriter, r *http.Request, providerName string) {
+	provider, ok := GetProvider(providerName)
+	if !ok {
+		http.Error(w, "Unknown identity provider", http.StatusNotFound)
+		return
+	}
+
 	//prevent csrf
 	if !validateOAuthState(r) {
 		stateCookie, err := r.Cookie("oauthstate")
@@ -100,70 +131,116 @@ func GoogleCallbackHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//exchg code for token from google's oauth2 server
-	token, err := GoogleOauthConfig.Exchange(context.Background(), r.FormValue("code"))
+	//exchg code for token from the provider's oauth2 server
+	token, err := provider.Exchange(r.Context(), r.FormValue("code"))
 	if err != nil {
-		utils.Logger.Error("Error exchanging code for token", zap.Error(err))
+		utils.Logger.Error("Error exchanging code for token", zap.String("provider", providerName), zap.Error(err))
 		http.Error(w, "Could not get token", http.StatusInternalServerError)
 		return
 	}
 	//track flow of access and refresh token
 	utils.Logger.Info("OAuth state validated", zap.String("state", r.FormValue("state")))
-	utils.Logger.Debug("Token received from Google",
+	utils.Logger.Debug("Token received from provider",
+		zap.String("provider", providerName),
 		zap.String("access_token", token.AccessToken),
 		zap.String("refresh_token", token.RefreshToken),
 		zap.Time("expiry", token.Expiry),
 	)
 
-	//get user info from google's api
-	utils.Logger.Info("Fetching user info for token", zap.String("access_token", token.AccessToken))
-	userInfo, err := fetchUserInfo(context.Background(), token)
+	//get user info from the provider
+	identity, err := provider.FetchIdentity(r.Context(), token)
 	if err != nil {
-		utils.Logger.Error("Error fetching user info", zap.Error(err))
+		utils.Logger.Error("Error fetching user info", zap.String("provider", providerName), zap.Error(err))
 		http.Error(w, "Failed to fetch user info", http.StatusInternalServerError)
 		return
 	}
+	if identity.Email == "" {
+		utils.Logger.Warn("Identity has no email address", zap.String("provider", providerName))
+		http.Error(w, "Your account has no email address", http.StatusBadRequest)
+		return
+	}
+
+	// a callback started from /oauth/{provider}/link attaches the identity to
+	// the signed-in user instead of signing in
+	if isLinkCallback(r) {
+		clearLinkCookie(w)
+		completeLink(w, r, identity)
+		return
+	}
 
 	// store user with access token and refresh token
-	err = database.StoreUserWithToken(userInfo, token.AccessToken, token.RefreshToken)
+	userID, err := database.StoreUserWithToken(identity, token.AccessToken, token.RefreshToken)
+	if errors.Is(err, database.ErrLinkRequired) {
+		utils.Logger.Warn("Refused to auto-link identity",
+			zap.String("provider", providerName),
+			zap.String("user_email", identity.Email),
+			zap.Bool("email_verified", identity.EmailVerified),
+		)
+		http.Error(w, "An account with this email already exists. Sign in with your original provider and link this one from your account.", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		utils.Logger.Error("Error storing user in DB", zap.Error(err))
 		http.Error(w, "Failed to store user info", http.StatusInternalServerError)
 		return
 	}
 
-	// set session cookie with access token
-	http.SetCookie(w, &http.Cookie{
-		Name:     "access_token",
-		Value:    token.AccessToken,
-		Expires:  time.Now().Add(time.Until(token.Expiry)),
-		HttpOnly: true,
-		Secure:   true, // Set to true if using HTTPS
-		Path:     "/",
-	})
-	utils.Logger.Info("Session cookie set", zap.String("user_email", userInfo.Email))
+	// every login gets its own server-side session so it can be revoked
+	sessionID, err := database.CreateSession(userID, r.UserAgent(), utils.ClientIP(r))
+	if err != nil {
+		utils.Logger.Error("Error creating session", zap.Error(err))
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	// mint our own session token so requests don't need to go back to the provider
+	sessionToken, claims, err := IssueSessionToken(userID, sessionID, identity.Email)
+	if err != nil {
+		utils.Logger.Error("Error issuing session token", zap.Error(err))
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	// start a new refresh token family for this login
+	refreshToken, refreshExpiry, err := issueRefreshToken(userID, sessionID)
+	if err != nil {
+		utils.Logger.Error("Error issuing refresh token", zap.Error(err))
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	// set session cookie with session token
+	setSessionCookie(w, sessionToken, claims)
+	setRefreshCookie(w, refreshToken, refreshExpiry)
+	utils.Logger.Info("Session cookie set",
+		zap.String("provider", providerName),
+		zap.String("user_email", identity.Email),
+		zap.Int("user_id", userID),
+	)
 
 	http.Redirect(w, r, getFrontendURL(), http.StatusSeeOther)
 }
 
-// TODO: create refresh token
-func RefreshAccessToken(refreshToken string) (*oauth2.Token, error) {
-	ensureConfig()
-
-	token := &oauth2.Token{
-		RefreshToken: refreshToken,
-		Expiry:       time.Now().Add(-time.Hour), //force token to expired
+// exchanges a stored provider refresh token for a new provider access token
+func RefreshAccessToken(providerName, refreshToken string) (*oauth2.Token, error) {
+	provider, ok := GetProvider(providerName)
+	if !ok {
+		return nil, fmt.Errorf("unknown identity provider %q", providerName)
 	}
 
-	utils.Logger.Debug("Attempting to refresh access token", zap.String("refresh_token", refreshToken))
+	utils.Logger.Debug("Attempting to refresh access token",
+		zap.String("provider", providerName),
+		zap.String("refresh_token", refreshToken),
+	)
 
-	newToken, err := GoogleOauthConfig.TokenSource(context.Background(), token).Token()
+	newToken, err := provider.Refresh(context.Background(), refreshToken)
 	if err != nil {
-		utils.Logger.Error("Failed to refresh access token", zap.Error(err))
+		utils.Logger.Error("Failed to refresh access token", zap.String("provider", providerName), zap.Error(err))
 		return nil, err
 	}
 
 	utils.Logger.Info("Access token refreshed successfully",
+		zap.String("provider", providerName),
 		zap.String("new_access_token", newToken.AccessToken),
 		zap.String("new_refresh_token", newToken.RefreshToken),
 		zap.Time("expiry", newToken.Expiry),
@@ -181,88 +258,34 @@ func UpdateAccessToken(email, accessToken string) error {
 	return err
 }
 
-// retrieves the user's info from Google
-func fetchUserInfo(ctx context.Context, token *oauth2.Token) (models.GoogleUser, error) {
-	client := GoogleOauthConfig.Client(ctx, token)
-	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
-	if err != nil {
-		return models.GoogleUser{}, fmt.Errorf("error fetching user info: %v", err)
-	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			utils.Logger.Error("Failed to close response body", zap.Error(err))
-		}
-	}()
-
-	if resp.StatusCode != http.StatusOK {
-		return models.GoogleUser{}, fmt.Errorf("error validating token: status code %v", resp.StatusCode)
-	}
-
-	var userInfo models.GoogleUser
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		return models.GoogleUser{}, fmt.Errorf("error decoding user info: %v", err)
+// ValidateToken verifies a Gymbara session token locally and checks that its
+// session has not been revoked
+func ValidateToken(accessToken string) (*SessionClaims, error) {
+	if sessionKeyring == nil {
+		return nil, errors.New("session keyring is not initialized")
 	}
 
-	utils.Logger.Debug("Fetched user info from Google", zap.String("user_email", userInfo.Email))
-	return userInfo, nil
-}
-
-func ValidateToken(accessToken string) (int, error) {
-	token := &oauth2.Token{AccessToken: accessToken}
-	userInfo, err := fetchUserInfo(context.Background(), token)
+	claims, err := sessionKeyring.Verify(accessToken, time.Now())
 	if err != nil {
-		utils.Logger.Error("Token validation failed", zap.Error(err))
-		return 0, err
+		utils.Logger.Warn("Session token validation failed", zap.Error(err))
+		return nil, err
 	}
 
-	//check if token expired
-	if token.Expiry.Before(time.Now()) {
-		// fetch refresh token from db
-		var refreshToken sql.NullString
-		err = database.DB.QueryRow("SELECT refresh_token FROM users WHERE email = $1", userInfo.Email).Scan(&refreshToken)
-		if err != nil {
-			utils.Logger.Error("Failed to fetch refresh token", zap.Error(err))
-			return 0, err
-		}
-
-		if !refreshToken.Valid || refreshToken.String == "" {
-			utils.Logger.Error("Refresh token is missing or invalid",
-				zap.String("user_email", userInfo.Email),
-				zap.String("refresh_token", refreshToken.String),
-			)
-			return 0, fmt.Errorf("refresh token is missing or invalid")
-		}
-
-		//refresh the access token
-		newToken, err := RefreshAccessToken(refreshToken.String)
-		if err != nil {
-			utils.Logger.Error("Failed to refresh access token", zap.Error(err))
-			return 0, err
-		}
-
-		//update access token in db
-		err = UpdateAccessToken(userInfo.Email, newToken.AccessToken)
-		if err != nil {
-			utils.Logger.Error("Failed to update access token in the database", zap.Error(err))
-			return 0, err
-		}
+	active, err := database.IsSessionActive(claims.SessionID, claims.UserID)
+	if err != nil && err != sql.ErrNoRows {
+		utils.Logger.Error("Failed to check session status", zap.Error(err))
+		return nil, err
 	}
-
-	//fetch user ID from db using email
-	var userID int
-	err = database.DB.QueryRow("SELECT id FROM users WHERE email = $1", userInfo.Email).Scan(&userID)
-	if err != nil {
-		utils.Logger.Error("User not found",
-			zap.String("user_email", userInfo.Email),
-			zap.Error(err))
-		return 0, err
+	if !active {
+		utils.Logger.Warn("Session revoked", zap.Int("session_id", claims.SessionID))
+		return nil, ErrSessionRevoked
 	}
 
-	utils.Logger.Info("Token validated successfully",
-		zap.String("user_email", userInfo.Email),
-		zap.Int("user_id", userID))
+	utils.Logger.Debug("Token validated successfully",
+		zap.String("user_email", claims.Email),
+		zap.Int("user_id", claims.UserID))
 
-	return userID, nil
+	return claims, nil
 }
 
 // Helper function to validate OAuth state for CSRF protection
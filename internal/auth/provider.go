@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+// Provider is an OAuth2 identity provider users can sign in with
+type Provider interface {
+	// Name is the path segment used in /oauth/{provider}/login
+	Name() string
+	AuthCodeURL(state string) string
+	Exchange(ctx context.Context, code string) (*oauth2.Token, error)
+	Refresh(ctx context.Context, refreshToken string) (*oauth2.Token, error)
+	FetchIdentity(ctx context.Context, token *oauth2.Token) (models.OAuthIdentity, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Provider)
+)
+
+// RegisterProvider makes a provider available under its name
+func RegisterProvider(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name()] = p
+}
+
+// GetProvider looks up a registered provider by name
+func GetProvider(name string) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
+
+// ProviderNames lists the registered providers, sorted
+func ProviderNames() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InitializeProviders registers Google plus every optional provider that has
+// credentials in the environment
+func InitializeProviders(ctx context.Context) error {
+	if err := InitializeOAuthConfig(); err != nil {
+		return err
+	}
+	backendBaseURL := os.Getenv("BACKEND_BASE_URL")
+
+	if clientID := os.Getenv("GITHUB_CLIENT_ID"); clientID != "" {
+		RegisterProvider(NewGitHubProvider(clientID, os.Getenv("GITHUB_CLIENT_SECRET"), backendBaseURL+"/oauth/github/callback"))
+	}
+
+	if issuerURL := os.Getenv("OIDC_ISSUER_URL"); issuerURL != "" {
+		name := os.Getenv("OIDC_PROVIDER_NAME")
+		if name == "" {
+			name = "oidc"
+		}
+		provider, err := NewOIDCProvider(ctx, OIDCConfig{
+			Name:         name,
+			IssuerURL:    issuerURL,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  backendBaseURL + "/oauth/" + name + "/callback",
+		})
+		if err != nil {
+			// a broken optional provider should not take down Google login
+			utils.Logger.Error("Failed to initialize OIDC provider", zap.String("issuer", issuerURL), zap.Error(err))
+		} else {
+			RegisterProvider(provider)
+		}
+	}
+
+	utils.Logger.Info("Identity providers registered", zap.Strings("providers", ProviderNames()))
+	return nil
+}
+
+// oauth2Provider implements the OAuth2 plumbing shared by every provider
+type oauth2Provider struct {
+	name        string
+	config      *oauth2.Config
+	authOptions []oauth2.AuthCodeOption
+}
+
+func (p *oauth2Provider) Name() string {
+	return p.name
+}
+
+func (p *oauth2Provider) AuthCodeURL(state string) string {
+	return p.config.AuthCodeURL(state, p.authOptions...)
+}
+
+func (p *oauth2Provider) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	return p.config.Exchange(ctx, code)
+}
+
+func (p *oauth2Provider) Refresh(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	token := &oauth2.Token{RefreshToken: refreshToken}
+	return p.config.TokenSource(ctx, token).Token()
+}
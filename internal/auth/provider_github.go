@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+const githubAPIBaseURL = "https://api.github.com"
+
+// githubProvider signs users in with GitHub
+type githubProvider struct {
+	oauth2Provider
+	apiBaseURL string
+}
+
+type githubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+}
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+func NewGitHubProvider(clientID, clientSecret, redirectURL string) Provider {
+	return &githubProvider{
+		oauth2Provider: oauth2Provider{
+			name: "github",
+			config: &oauth2.Config{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				RedirectURL:  redirectURL,
+				Scopes:       []string{"read:user", "user:email"},
+				Endpoint:     github.Endpoint,
+			},
+		},
+		apiBaseURL: githubAPIBaseURL,
+	}
+}
+
+func (p *githubProvider) FetchIdentity(ctx context.Context, token *oauth2.Token) (models.OAuthIdentity, error) {
+	client := p.config.Client(ctx, token)
+
+	var user githubUser
+	if err := p.getJSON(client, "/user", &user); err != nil {
+		return models.OAuthIdentity{}, err
+	}
+
+	// the profile email may be hidden, the emails endpoint tells us the
+	// primary address and whether GitHub verified it
+	var emails []githubEmail
+	if err := p.getJSON(client, "/user/emails", &emails); err != nil {
+		return models.OAuthIdentity{}, err
+	}
+
+	identity := models.OAuthIdentity{
+		Provider: p.name,
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+		Picture:  user.AvatarURL,
+	}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+			break
+		}
+	}
+	if identity.Email == "" {
+		return models.OAuthIdentity{}, fmt.Errorf("github account %d has no primary email", user.ID)
+	}
+
+	utils.Logger.Debug("Fetched user info from GitHub", zap.String("user_email", identity.Email))
+	return identity, nil
+}
+
+func (p *githubProvider) getJSON(client *http.Client, path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, p.apiBaseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching %s: %v", path, err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			utils.Logger.Error("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error fetching %s: status code %v", path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error decoding %s: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+const googleUserInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
+
+// googleProvider signs users in with Google
+type googleProvider struct {
+	oauth2Provider
+}
+
+func newGoogleProvider(config *oauth2.Config) *googleProvider {
+	return &googleProvider{oauth2Provider{
+		name:   "google",
+		config: config,
+		// offline access + forced consent so Google always hands back a refresh token
+		authOptions: []oauth2.AuthCodeOption{oauth2.AccessTypeOffline, oauth2.ApprovalForce},
+	}}
+}
+
+func (p *googleProvider) FetchIdentity(ctx context.Context, token *oauth2.Token) (models.OAuthIdentity, error) {
+	userInfo, err := p.fetchUserInfo(ctx, token)
+	if err != nil {
+		return models.OAuthIdentity{}, err
+	}
+	return models.OAuthIdentity{
+		Provider:      p.name,
+		Subject:       userInfo.ID,
+		Email:         userInfo.Email,
+		EmailVerified: userInfo.VerifiedEmail,
+		Name:          userInfo.Name,
+		Picture:       userInfo.Picture,
+	}, nil
+}
+
+// retrieves the user's info from Google
+func (p *googleProvider) fetchUserInfo(ctx context.Context, token *oauth2.Token) (models.GoogleUser, error) {
+	client := p.config.Client(ctx, token)
+	resp, err := client.Get(googleUserInfoURL)
+	if err != nil {
+		return models.GoogleUser{}, fmt.Errorf("error fetching user info: %v", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			utils.Logger.Error("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return models.GoogleUser{}, fmt.Errorf("error validating token: status code %v", resp.StatusCode)
+	}
+
+	var userInfo models.GoogleUser
+	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
+		return models.GoogleUser{}, fmt.Errorf("error decoding user info: %v", err)
+	}
+
+	utils.Logger.Debug("Fetched user info from Google", zap.String("user_email", userInfo.Email))
+	return userInfo, nil
+}
@@ -0,0 +1,350 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+// allowed clock drift between us and the identity provider
+const idTokenLeeway = time.Minute
+
+// OIDCConfig configures a generic OpenID Connect provider
+type OIDCConfig struct {
+	Name         string
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// HTTPClient is used for discovery, JWKS and token calls, defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// oidcProvider signs users in with any OpenID Connect provider, using the
+// discovery document for endpoints and verifying ID tokens against its JWKS
+type oidcProvider struct {
+	oauth2Provider
+	issuer      string
+	jwksURL     string
+	userinfoURL string
+	httpClient  *http.Client
+
+	keysMu sync.RWMutex
+	keys   map[string]*rsa.PublicKey
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+type idTokenClaims struct {
+	Issuer        string       `json:"iss"`
+	Subject       string       `json:"sub"`
+	Audience      audience     `json:"aud"`
+	ExpiresAt     int64        `json:"exp"`
+	IssuedAt      int64        `json:"iat"`
+	Email         string       `json:"email"`
+	EmailVerified flexibleBool `json:"email_verified"`
+	Name          string       `json:"name"`
+	Picture       string       `json:"picture"`
+}
+
+// audience accepts both the single string and the array form of "aud"
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// flexibleBool accepts true as well as "true", some providers send strings
+type flexibleBool bool
+
+func (f *flexibleBool) UnmarshalJSON(b []byte) error {
+	var value bool
+	if err := json.Unmarshal(b, &value); err == nil {
+		*f = flexibleBool(value)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(b, &text); err != nil {
+		return err
+	}
+	*f = flexibleBool(text == "true")
+	return nil
+}
+
+// NewOIDCProvider loads the issuer's discovery document and returns a provider for it
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig) (Provider, error) {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	issuer := strings.TrimSuffix(cfg.IssuerURL, "/")
+
+	var discovery oidcDiscovery
+	if err := getJSONWithClient(ctx, httpClient, issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, fmt.Errorf("failed to load OIDC discovery document: %w", err)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OIDC issuer mismatch: configured %q, discovered %q", issuer, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("OIDC discovery document is missing required endpoints")
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &oidcProvider{
+		oauth2Provider: oauth2Provider{
+			name: cfg.Name,
+			config: &oauth2.Config{
+				ClientID:     cfg.ClientID,
+				ClientSecret: cfg.ClientSecret,
+				RedirectURL:  cfg.RedirectURL,
+				Scopes:       scopes,
+				Endpoint: oauth2.Endpoint{
+					AuthURL:  discovery.AuthorizationEndpoint,
+					TokenURL: discovery.TokenEndpoint,
+				},
+			},
+			authOptions: []oauth2.AuthCodeOption{oauth2.AccessTypeOffline},
+		},
+		issuer:      discovery.Issuer,
+		jwksURL:     discovery.JWKSURI,
+		userinfoURL: discovery.UserinfoEndpoint,
+		httpClient:  httpClient,
+		keys:        make(map[string]*rsa.PublicKey),
+	}, nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, code string) (*oauth2.Token, error) {
+	return p.config.Exchange(context.WithValue(ctx, oauth2.HTTPClient, p.httpClient), code)
+}
+
+func (p *oidcProvider) Refresh(ctx context.Context, refreshToken string) (*oauth2.Token, error) {
+	return p.oauth2Provider.Refresh(context.WithValue(ctx, oauth2.HTTPClient, p.httpClient), refreshToken)
+}
+
+// FetchIdentity reads the user from the verified ID token returned with the
+// access token. Stored access tokens have no ID token, those go through the
+// userinfo endpoint instead.
+func (p *oidcProvider) FetchIdentity(ctx context.Context, token *oauth2.Token) (models.OAuthIdentity, error) {
+	var claims *idTokenClaims
+	if rawIDToken, ok := token.Extra("id_token").(string); ok && rawIDToken != "" {
+		verified, err := p.verifyIDToken(ctx, rawIDToken, time.Now())
+		if err != nil {
+			return models.OAuthIdentity{}, err
+		}
+		utils.Logger.Debug("Verified OIDC ID token",
+			zap.String("provider", p.name),
+			zap.String("subject", verified.Subject),
+		)
+		claims = verified
+	} else {
+		if p.userinfoURL == "" {
+			return models.OAuthIdentity{}, errors.New("token response has no id_token")
+		}
+		client := oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, p.httpClient), oauth2.StaticTokenSource(token))
+		claims = &idTokenClaims{}
+		if err := getJSONWithClient(ctx, client, p.userinfoURL, claims); err != nil {
+			return models.OAuthIdentity{}, fmt.Errorf("error fetching user info: %w", err)
+		}
+		if claims.Subject == "" {
+			return models.OAuthIdentity{}, errors.New("userinfo response has no subject")
+		}
+	}
+
+	return models.OAuthIdentity{
+		Provider:      p.name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: bool(claims.EmailVerified),
+		Name:          claims.Name,
+		Picture:       claims.Picture,
+	}, nil
+}
+
+// verifyIDToken checks the RS256 signature against the JWKS and validates
+// issuer, audience and expiry
+func (p *oidcProvider) verifyIDToken(ctx context.Context, rawIDToken string, now time.Time) (*idTokenClaims, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed id_token")
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed id_token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported id_token algorithm %q", header.Alg)
+	}
+
+	key, err := p.publicKey(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed id_token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, errors.New("invalid id_token signature")
+	}
+
+	var claims idTokenClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed id_token claims: %w", err)
+	}
+	if claims.Issuer != p.issuer {
+		return nil, fmt.Errorf("unexpected id_token issuer %q", claims.Issuer)
+	}
+	if !claims.Audience.contains(p.config.ClientID) {
+		return nil, errors.New("id_token was not issued for this client")
+	}
+	if now.Add(-idTokenLeeway).Unix() >= claims.ExpiresAt {
+		return nil, errors.New("id_token has expired")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("id_token has no subject")
+	}
+	return &claims, nil
+}
+
+// publicKey returns the signing key for kid, reloading the JWKS once when the
+// key is unknown so provider-side key rotation is picked up
+func (p *oidcProvider) publicKey(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.keysMu.RLock()
+	key, ok := p.keys[kid]
+	p.keysMu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := p.loadKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	p.keysMu.RLock()
+	defer p.keysMu.RUnlock()
+	key, ok = p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown id_token signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (p *oidcProvider) loadKeys(ctx context.Context) error {
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSONWithClient(ctx, p.httpClient, p.jwksURL, &jwks); err != nil {
+		return fmt.Errorf("failed to load JWKS: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		key, err := jwk.rsaPublicKey()
+		if err != nil {
+			utils.Logger.Warn("Skipping malformed JWKS key", zap.String("kid", jwk.Kid), zap.Error(err))
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	p.keysMu.Lock()
+	p.keys = keys
+	p.keysMu.Unlock()
+	return nil
+}
+
+func (k jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("invalid RSA exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+}
+
+func getJSONWithClient(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			utils.Logger.Error("Failed to close response body", zap.Error(err))
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status code %v", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
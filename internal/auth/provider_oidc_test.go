@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const testOIDCClientID = "gymbara-test"
+
+// fakeOIDCServer is a minimal OpenID Connect provider serving discovery, JWKS
+// and a token endpoint that hands back whatever ID token the test sets
+type fakeOIDCServer struct {
+	*httptest.Server
+	key     *rsa.PrivateKey
+	kid     string
+	idToken string
+}
+
+func newFakeOIDCServer(t *testing.T) *fakeOIDCServer {
+	t.Helper()
+	utils.Logger = zap.NewNop()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	fake := &fakeOIDCServer{key: key, kid: "test-key"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]string{
+			"issuer":                 fake.URL,
+			"authorization_endpoint": fake.URL + "/authorize",
+			"token_endpoint":         fake.URL + "/token",
+			"jwks_uri":               fake.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": fake.kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]interface{}{
+			"access_token": "access-123",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     fake.idToken,
+		})
+	})
+	fake.Server = httptest.NewServer(mux)
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+// signs an RS256 ID token with the server key, claims override the defaults
+func (f *fakeOIDCServer) sign(t *testing.T, overrides map[string]interface{}) string {
+	t.Helper()
+	claims := map[string]interface{}{
+		"iss":            f.URL,
+		"sub":            "user-1",
+		"aud":            testOIDCClientID,
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"iat":            time.Now().Unix(),
+		"email":          "lifter@example.com",
+		"email_verified": true,
+		"name":           "Test Lifter",
+	}
+	for k, v := range overrides {
+		claims[k] = v
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": f.kid})
+	payload, _ := json.Marshal(claims)
+	signingInput := encodeSegment(header) + "." + encodeSegment(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, f.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatalf("SignPKCS1v15: %v", err)
+	}
+	return signingInput + "." + encodeSegment(signature)
+}
+
+func (f *fakeOIDCServer) provider(t *testing.T) Provider {
+	t.Helper()
+	provider, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		Name:        "fake",
+		IssuerURL:   f.URL,
+		ClientID:    testOIDCClientID,
+		RedirectURL: "http://localhost/oauth/fake/callback",
+	})
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+	return provider
+}
+
+func writeTestJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestOIDCProviderLogin(t *testing.T) {
+	fake := newFakeOIDCServer(t)
+	provider := fake.provider(t)
+
+	if authURL := provider.AuthCodeURL("state-1"); !strings.HasPrefix(authURL, fake.URL+"/authorize?") {
+		t.Fatalf("unexpected auth URL %q", authURL)
+	}
+
+	fake.idToken = fake.sign(t, nil)
+	token, err := provider.Exchange(context.Background(), "code-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+
+	identity, err := provider.FetchIdentity(context.Background(), token)
+	if err != nil {
+		t.Fatalf("FetchIdentity: %v", err)
+	}
+	if identity.Provider != "fake" || identity.Subject != "user-1" || identity.Email != "lifter@example.com" || !identity.EmailVerified {
+		t.Fatalf("unexpected identity: %+v", identity)
+	}
+}
+
+func TestOIDCProviderRejectsBadIDTokens(t *testing.T) {
+	fake := newFakeOIDCServer(t)
+	provider := fake.provider(t)
+
+	tests := map[string]map[string]interface{}{
+		"wrong audience": {"aud": "someone-else"},
+		"wrong issuer":   {"iss": "https://evil.example.com"},
+		"expired":        {"exp": time.Now().Add(-time.Hour).Unix()},
+	}
+	for name, overrides := range tests {
+		t.Run(name, func(t *testing.T) {
+			fake.idToken = fake.sign(t, overrides)
+			token, err := provider.Exchange(context.Background(), "code-1")
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if _, err := provider.FetchIdentity(context.Background(), token); err == nil {
+				t.Fatal("expected ID token to be rejected")
+			}
+		})
+	}
+
+	t.Run("unknown signing key", func(t *testing.T) {
+		other, _ := rsa.GenerateKey(rand.Reader, 2048)
+		fake.idToken = (&fakeOIDCServer{Server: fake.Server, key: other, kid: "other-key"}).sign(t, nil)
+		token, err := provider.Exchange(context.Background(), "code-1")
+		if err != nil {
+			t.Fatalf("Exchange: %v", err)
+		}
+		if _, err := provider.FetchIdentity(context.Background(), token); err == nil {
+			t.Fatal("expected ID token signed with an unknown key to be rejected")
+		}
+	})
+}
+
+func TestNewOIDCProviderRejectsIssuerMismatch(t *testing.T) {
+	fake := newFakeOIDCServer(t)
+	_, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		Name:      "fake",
+		IssuerURL: fake.URL + "/tenant",
+		ClientID:  testOIDCClientID,
+	})
+	if err == nil {
+		t.Fatal("expected discovery at the wrong issuer to fail")
+	}
+}
@@ -0,0 +1,282 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const refreshCookieName = "refresh_token"
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// RefreshHandler rotates the refresh token and issues a new session token.
+// Presenting an already rotated refresh token revokes the whole token family.
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	refreshCookie, err := r.Cookie(refreshCookieName)
+	if err != nil || refreshCookie.Value == "" {
+		utils.WriteStandardResponse(w, http.StatusUnauthorized, "Refresh token not found", nil)
+		return
+	}
+
+	rotated, err := rotateRefreshToken(refreshCookie.Value)
+	if err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) || errors.Is(err, ErrRefreshTokenInvalid) {
+			utils.Logger.Warn("Refresh rejected", zap.Error(err))
+			clearSessionCookies(w)
+			utils.WriteStandardResponse(w, http.StatusUnauthorized, "Invalid refresh token", nil)
+			return
+		}
+		utils.Logger.Error("Failed to rotate refresh token", zap.Error(err))
+		utils.WriteStandardResponse(w, http.StatusInternalServerError, "Failed to refresh session", nil)
+		return
+	}
+
+	sessionToken, claims, err := IssueSessionToken(rotated.UserID, rotated.SessionID, rotated.Email)
+	if err != nil {
+		utils.Logger.Error("Error issuing session token", zap.Error(err))
+		utils.WriteStandardResponse(w, http.StatusInternalServerError, "Failed to refresh session", nil)
+		return
+	}
+
+	setSessionCookie(w, sessionToken, claims)
+	setRefreshCookie(w, rotated.Token, rotated.ExpiresAt)
+
+	// keep the stored provider access token fresh without blocking the response
+	go refreshProviderAccessToken(rotated.UserID, rotated.Email)
+
+	utils.Logger.Info("Session refreshed",
+		zap.Int("user_id", rotated.UserID),
+		zap.Int("session_id", rotated.SessionID),
+	)
+	utils.WriteStandardResponse(w, http.StatusOK, "Session refreshed", map[string]interface{}{
+		"expires_at": claims.ExpiresAtTime().Format(time.RFC3339),
+	})
+}
+
+// rotatedRefreshToken is the outcome of a successful refresh token rotation
+type rotatedRefreshToken struct {
+	UserID    int
+	SessionID int
+	Email     string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// issueRefreshToken starts a new refresh token family for a fresh login
+func issueRefreshToken(userID, sessionID int) (string, time.Time, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expiresAt := time.Now().Add(refreshTokenTTL)
+	_, err = database.DB.Exec(`
+		INSERT INTO RefreshTokens (user_id, session_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, sessionID, familyID, hashToken(token), expiresAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// rotateRefreshToken marks the presented token as used and issues its successor
+// in the same family. A token that was already rotated is treated as stolen, so
+// the whole family and its session are revoked.
+func rotateRefreshToken(presented string) (*rotatedRefreshToken, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	committed := false
+	defer func() {
+		if committed {
+			return
+		}
+		if rbErr := tx.Rollback(); rbErr != nil {
+			utils.Logger.Error("Transaction rollback failed", zap.Error(rbErr))
+		}
+	}()
+
+	var (
+		tokenID   int
+		familyID  string
+		expiry    time.Time
+		rotatedAt sql.NullTime
+		revokedAt sql.NullTime
+		result    rotatedRefreshToken
+	)
+	err = tx.QueryRow(`
+		SELECT rt.id, rt.user_id, rt.session_id, rt.family_id, rt.expires_at, rt.rotated_at,
+			COALESCE(rt.revoked_at, us.revoked_at)
+		FROM RefreshTokens rt
+		JOIN user_sessions us ON us.id = rt.session_id
+		WHERE rt.token_hash = $1
+		FOR UPDATE OF rt
+	`, hashToken(presented)).Scan(&tokenID, &result.UserID, &result.SessionID, &familyID, &expiry, &rotatedAt, &revokedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	if rotatedAt.Valid {
+		// reuse of a rotated token, revoke every token in the family and its session
+		if _, err = tx.Exec(`
+			UPDATE RefreshTokens
+			SET revoked_at = NOW()
+			WHERE family_id = $1 AND revoked_at IS NULL
+		`, familyID); err != nil {
+			return nil, err
+		}
+		if _, err = tx.Exec(`
+			UPDATE user_sessions
+			SET revoked_at = NOW()
+			WHERE id = $1 AND revoked_at IS NULL
+		`, result.SessionID); err != nil {
+			return nil, err
+		}
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+		committed = true
+		utils.Logger.Warn("Refresh token reuse detected, token family revoked",
+			zap.Int("user_id", result.UserID),
+			zap.Int("session_id", result.SessionID),
+			zap.String("family_id", familyID),
+		)
+		return nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(expiry) {
+		return nil, ErrRefreshTokenInvalid
+	}
+
+	if _, err = tx.Exec(`UPDATE RefreshTokens SET rotated_at = NOW() WHERE id = $1`, tokenID); err != nil {
+		return nil, err
+	}
+
+	result.Token, err = randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	result.ExpiresAt = time.Now().Add(refreshTokenTTL)
+	if _, err = tx.Exec(`
+		INSERT INTO RefreshTokens (user_id, session_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, result.UserID, result.SessionID, familyID, hashToken(result.Token), result.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	if err = tx.QueryRow(`SELECT email FROM Users WHERE id = $1`, result.UserID).Scan(&result.Email); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	committed = true
+
+	return &result, nil
+}
+
+// looks up the session a refresh token belongs to, used by logout when the
+// session cookie is already gone
+func sessionForRefreshToken(token string) (userID, sessionID int, err error) {
+	err = database.DB.QueryRow(`
+		SELECT user_id, session_id
+		FROM RefreshTokens
+		WHERE token_hash = $1
+	`, hashToken(token)).Scan(&userID, &sessionID)
+	return userID, sessionID, err
+}
+
+// refreshes the provider access token using the stored Users.refresh_token
+func refreshProviderAccessToken(userID int, email string) {
+	var providerName, providerRefreshToken sql.NullString
+	err := database.DB.QueryRowContext(context.Background(),
+		"SELECT oauth_provider, refresh_token FROM Users WHERE id = $1", userID,
+	).Scan(&providerName, &providerRefreshToken)
+	if err != nil {
+		utils.Logger.Error("Failed to fetch refresh token", zap.Error(err))
+		return
+	}
+	if !providerRefreshToken.Valid || providerRefreshToken.String == "" {
+		utils.Logger.Debug("No provider refresh token stored", zap.Int("user_id", userID))
+		return
+	}
+
+	newToken, err := RefreshAccessToken(providerName.String, providerRefreshToken.String)
+	if err != nil {
+		utils.Logger.Warn("Failed to refresh provider access token", zap.Int("user_id", userID), zap.Error(err))
+		return
+	}
+
+	if err := UpdateAccessToken(email, newToken.AccessToken); err != nil {
+		utils.Logger.Error("Failed to update access token in the database", zap.Error(err))
+	}
+}
+
+// sets the refresh_token cookie, scoped to the oauth routes
+func setRefreshCookie(w http.ResponseWriter, token string, expiresAt time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    token,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/oauth",
+	})
+}
+
+func clearRefreshCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/oauth",
+	})
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
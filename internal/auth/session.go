@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/config"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// minimum secret length for HS256 signing keys
+const minSigningKeyLength = 32
+
+var (
+	ErrInvalidToken   = errors.New("invalid session token")
+	ErrExpiredToken   = errors.New("session token expired")
+	ErrUnknownKey     = errors.New("session token signed with unknown key")
+	ErrSessionRevoked = errors.New("session has been revoked")
+)
+
+// SessionClaims is the payload of a Gymbara session token
+type SessionClaims struct {
+	UserID    int    `json:"uid"`
+	SessionID int    `json:"sid"`
+	Email     string `json:"email"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// SessionKeyring signs session tokens with the active key and verifies them
+// with any known key, so rotating keys does not log everyone out.
+type SessionKeyring struct {
+	activeKeyID string
+	keys        map[string][]byte
+	ttl         time.Duration
+}
+
+var (
+	// sessionKeyring is the keyring used by the HTTP handlers and middleware
+	sessionKeyring *SessionKeyring
+	// refreshTokenTTL is how long a refresh token stays valid after issue
+	refreshTokenTTL = 30 * 24 * time.Hour
+)
+
+func NewSessionKeyring(activeKeyID string, keys map[string]string, ttl time.Duration) (*SessionKeyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no session signing keys configured")
+	}
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active session key %q is not configured", activeKeyID)
+	}
+	if ttl <= 0 {
+		return nil, errors.New("session TTL must be positive")
+	}
+
+	keyring := &SessionKeyring{
+		activeKeyID: activeKeyID,
+		keys:        make(map[string][]byte, len(keys)),
+		ttl:         ttl,
+	}
+	for kid, secret := range keys {
+		if len(secret) < minSigningKeyLength {
+			return nil, fmt.Errorf("session key %q must be at least %d bytes", kid, minSigningKeyLength)
+		}
+		keyring.keys[kid] = []byte(secret)
+	}
+	return keyring, nil
+}
+
+// initializes the session keyring from config
+func InitializeSessionKeys(cfg *config.Config) error {
+	keyring, err := NewSessionKeyring(cfg.SessionActiveKeyID, cfg.SessionSigningKeys, cfg.SessionTTL)
+	if err != nil {
+		return err
+	}
+	sessionKeyring = keyring
+	if cfg.RefreshTokenTTL > 0 {
+		refreshTokenTTL = cfg.RefreshTokenTTL
+	}
+
+	utils.Logger.Info("Session keyring initialized",
+		zap.String("active_key_id", cfg.SessionActiveKeyID),
+		zap.Int("key_count", len(cfg.SessionSigningKeys)),
+		zap.Duration("ttl", cfg.SessionTTL),
+		zap.Duration("refresh_ttl", refreshTokenTTL),
+	)
+	return nil
+}
+
+// Issue signs a new session token for the user with the active key
+func (k *SessionKeyring) Issue(userID, sessionID int, email string, now time.Time) (string, *SessionClaims, error) {
+	claims := &SessionClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Email:     email,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(k.ttl).Unix(),
+	}
+
+	header, err := json.Marshal(tokenHeader{Alg: "HS256", Typ: "JWT", Kid: k.activeKeyID})
+	if err != nil {
+		return "", nil, err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	signingInput := encodeSegment(header) + "." + encodeSegment(payload)
+	signature := sign(k.keys[k.activeKeyID], signingInput)
+	return signingInput + "." + encodeSegment(signature), claims, nil
+}
+
+// Verify checks the signature and expiry of a session token. Expired tokens
+// still return their claims alongside ErrExpiredToken, so logout can identify
+// the session; every other caller must treat a non-nil error as a rejection.
+func (k *SessionKeyring) Verify(token string, now time.Time) (*SessionClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if header.Alg != "HS256" {
+		return nil, ErrInvalidToken
+	}
+
+	key, ok := k.keys[header.Kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !hmac.Equal(signature, sign(key, parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims SessionClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if claims.UserID == 0 || claims.SessionID == 0 {
+		return nil, ErrInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return &claims, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+// ExpiresAtTime returns the expiry as a time.Time, handy for cookies
+func (c *SessionClaims) ExpiresAtTime() time.Time {
+	return time.Unix(c.ExpiresAt, 0)
+}
+
+// IssueSessionToken signs a session token with the configured keyring
+func IssueSessionToken(userID, sessionID int, email string) (string, *SessionClaims, error) {
+	if sessionKeyring == nil {
+		return "", nil, errors.New("session keyring is not initialized")
+	}
+	return sessionKeyring.Issue(userID, sessionID, email, time.Now())
+}
+
+// sets the access_token cookie holding the session token
+func setSessionCookie(w http.ResponseWriter, token string, claims *SessionClaims) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "access_token",
+		Value:    token,
+		Expires:  claims.ExpiresAtTime(),
+		HttpOnly: true,
+		Secure:   true, // Set to true if using HTTPS
+		Path:     "/",
+	})
+}
+
+// expires both session cookies on the client
+func clearSessionCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "access_token",
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		Path:     "/",
+	})
+	clearRefreshCookie(w)
+}
+
+func sign(key []byte, signingInput string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signingInput))
+	return mac.Sum(nil)
+}
+
+func encodeSegment(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
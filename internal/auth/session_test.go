@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testSecretOld = "old-secret-old-secret-old-secret-00"
+	testSecretNew = "new-secret-new-secret-new-secret-00"
+)
+
+func TestSessionTokenRoundTrip(t *testing.T) {
+	keyring, err := NewSessionKeyring("k1", map[string]string{"k1": testSecretOld}, time.Hour)
+	if err != nil {
+		t.Fatalf("NewSessionKeyring: %v", err)
+	}
+
+	now := time.Now()
+	token, _, err := keyring.Issue(42, 3, "lifter@example.com", now)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	claims, err := keyring.Verify(token, now)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.UserID != 42 || claims.SessionID != 3 || claims.Email != "lifter@example.com" {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestSessionTokenRotation(t *testing.T) {
+	oldKeyring, _ := NewSessionKeyring("k1", map[string]string{"k1": testSecretOld}, time.Hour)
+	token, _, err := oldKeyring.Issue(1, 1, "a@example.com", time.Now())
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	// k2 is now active but k1 is still trusted
+	rotated, _ := NewSessionKeyring("k2", map[string]string{"k1": testSecretOld, "k2": testSecretNew}, time.Hour)
+	if _, err := rotated.Verify(token, time.Now()); err != nil {
+		t.Fatalf("token signed with retired key should still verify: %v", err)
+	}
+
+	// once k1 is removed, its tokens are rejected
+	retired, _ := NewSessionKeyring("k2", map[string]string{"k2": testSecretNew}, time.Hour)
+	if _, err := retired.Verify(token, time.Now()); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestSessionTokenRejectsTamperingAndExpiry(t *testing.T) {
+	keyring, _ := NewSessionKeyring("k1", map[string]string{"k1": testSecretOld}, time.Minute)
+	now := time.Now()
+	token, _, _ := keyring.Issue(7, 1, "b@example.com", now)
+
+	parts := strings.Split(token, ".")
+	forged, _, _ := keyring.Issue(8, 1, "c@example.com", now)
+	tampered := parts[0] + "." + strings.Split(forged, ".")[1] + "." + parts[2]
+	if _, err := keyring.Verify(tampered, now); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("expected ErrInvalidToken for tampered payload, got %v", err)
+	}
+
+	claims, err := keyring.Verify(token, now.Add(2*time.Minute))
+	if !errors.Is(err, ErrExpiredToken) {
+		t.Fatalf("expected ErrExpiredToken, got %v", err)
+	}
+	if claims == nil || claims.SessionID != 1 {
+		t.Fatalf("expired token should still expose its claims, got %+v", claims)
+	}
+}
+
+func TestNewSessionKeyringValidation(t *testing.T) {
+	if _, err := NewSessionKeyring("k1", map[string]string{"k1": "short"}, time.Hour); err == nil {
+		t.Fatal("expected error for short secret")
+	}
+	if _, err := NewSessionKeyring("missing", map[string]string{"k1": testSecretOld}, time.Hour); err == nil {
+		t.Fatal("expected error for unknown active key")
+	}
+}
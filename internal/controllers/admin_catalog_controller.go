@@ -0,0 +1,549 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// routes are used as frontend paths, e.g. "upper-body"
+var sectionRoutePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// drops every cached catalog response that may include the given sections
+func invalidateCatalogCache(sectionIDs ...int) {
+	workoutCache.Delete("workout_sections")
+	for _, sectionID := range sectionIDs {
+		// one entry per program week, see exerciseListCacheKey
+		workoutCache.DeletePrefix(fmt.Sprintf("exercise_list_%d_", sectionID))
+		workoutCache.DeletePrefix(fmt.Sprintf("exercise_details_%d_", sectionID))
+	}
+	// keyed by any combination of section IDs, so drop them all
+	workoutCache.DeletePrefix("workout_sections_with_exercises_")
+}
+
+// POST /admin/workout-sections creates a workout section
+func AdminWorkoutSections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input models.SectionInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if input.Name == nil || input.Route == nil {
+		utils.HandleError(w, "name and route are required", http.StatusBadRequest, nil)
+		return
+	}
+	if msg := validateSectionInput(input); msg != "" {
+		utils.HandleError(w, msg, http.StatusBadRequest, nil)
+		return
+	}
+
+	section, err := database.CreateSection(input)
+	if err != nil {
+		utils.HandleError(w, "Unable to create workout section", http.StatusInternalServerError, err)
+		return
+	}
+	invalidateCatalogCache(section.ID)
+
+	utils.Logger.Info("Workout section created", zap.Int("section_id", section.ID))
+	utils.WriteStandardResponse(w, http.StatusCreated, "Workout section created", section)
+}
+
+// PATCH /admin/workout-sections/{id} updates a workout section, DELETE removes it
+func AdminWorkoutSection(w http.ResponseWriter, r *http.Request) {
+	sectionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid workout section ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		var input models.SectionInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+			return
+		}
+		if msg := validateSectionInput(input); msg != "" {
+			utils.HandleError(w, msg, http.StatusBadRequest, nil)
+			return
+		}
+
+		section, err := database.UpdateSection(sectionID, input)
+		if err != nil {
+			writeCatalogError(w, "Unable to update workout section", err)
+			return
+		}
+		invalidateCatalogCache(sectionID)
+
+		utils.Logger.Info("Workout section updated", zap.Int("section_id", sectionID))
+		utils.WriteStandardResponse(w, http.StatusOK, "Workout section updated", section)
+
+	case http.MethodDelete:
+		if err := database.DeleteSection(sectionID); err != nil {
+			writeCatalogError(w, "Unable to delete workout section", err)
+			return
+		}
+		invalidateCatalogCache(sectionID)
+
+		utils.Logger.Info("Workout section deleted", zap.Int("section_id", sectionID))
+		utils.WriteStandardResponse(w, http.StatusOK, "Workout section deleted", nil)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// PUT /admin/workout-sections/order sets the order of all workout sections
+func ReorderWorkoutSections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ReorderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+
+	if err := database.ReorderSections(req.IDs); err != nil {
+		writeCatalogError(w, "Unable to reorder workout sections", err)
+		return
+	}
+	invalidateCatalogCache()
+
+	utils.Logger.Info("Workout sections reordered", zap.Ints("ids", req.IDs))
+	utils.WriteStandardResponse(w, http.StatusOK, "Workout sections reordered", nil)
+}
+
+// PUT /admin/workout-sections/{id}/exercises/order sets the order of the
+// exercises in a section
+func ReorderSectionExercises(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sectionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid workout section ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	var req models.ReorderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+
+	if err := database.ReorderExercises(sectionID, req.IDs); err != nil {
+		writeCatalogError(w, "Unable to reorder exercises", err)
+		return
+	}
+	invalidateCatalogCache(sectionID)
+
+	utils.Logger.Info("Exercises reordered", zap.Int("section_id", sectionID), zap.Ints("ids", req.IDs))
+	utils.WriteStandardResponse(w, http.StatusOK, "Exercises reordered", nil)
+}
+
+// POST /admin/exercises creates an exercise
+func AdminExercises(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input models.ExerciseInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if input.WorkoutSectionID == nil || input.Name == nil {
+		utils.HandleError(w, "workout_section_id and name are required", http.StatusBadRequest, nil)
+		return
+	}
+	if msg := validateExerciseInput(input); msg != "" {
+		utils.HandleError(w, msg, http.StatusBadRequest, nil)
+		return
+	}
+
+	exercise, err := database.CreateExercise(input)
+	if err != nil {
+		writeCatalogError(w, "Unable to create exercise", err)
+		return
+	}
+	invalidateCatalogCache(exercise.WorkoutSectionID)
+
+	utils.Logger.Info("Exercise created", zap.Int("exercise_id", exercise.ID))
+	utils.WriteStandardResponse(w, http.StatusCreated, "Exercise created", exercise)
+}
+
+// PATCH /admin/exercises/{id} updates an exercise, DELETE removes it
+func AdminExercise(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid exercise ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		var input models.ExerciseInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+			return
+		}
+		if msg := validateExerciseInput(input); msg != "" {
+			utils.HandleError(w, msg, http.StatusBadRequest, nil)
+			return
+		}
+
+		exercise, previousSectionID, err := database.UpdateExercise(exerciseID, input)
+		if err != nil {
+			writeCatalogError(w, "Unable to update exercise", err)
+			return
+		}
+		invalidateCatalogCache(previousSectionID, exercise.WorkoutSectionID)
+
+		utils.Logger.Info("Exercise updated", zap.Int("exercise_id", exerciseID))
+		utils.WriteStandardResponse(w, http.StatusOK, "Exercise updated", exercise)
+
+	case http.MethodDelete:
+		sectionID, err := database.DeleteExercise(exerciseID)
+		if err != nil {
+			writeCatalogError(w, "Unable to delete exercise", err)
+			return
+		}
+		invalidateCatalogCache(sectionID)
+
+		utils.Logger.Info("Exercise deleted", zap.Int("exercise_id", exerciseID))
+		utils.WriteStandardResponse(w, http.StatusOK, "Exercise deleted", nil)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /admin/exercises/{id}/prescriptions lists an exercise's prescriptions,
+// POST adds one
+func AdminExercisePrescriptions(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid exercise ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		prescriptions, err := database.ListPrescriptions(exerciseID)
+		if err != nil {
+			writeCatalogError(w, "Unable to retrieve prescriptions", err)
+			return
+		}
+		utils.WriteStandardResponse(w, http.StatusOK, "Prescriptions retrieved successfully", prescriptions)
+
+	case http.MethodPost:
+		var input models.PrescriptionInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+			return
+		}
+		if input.WeekStart == nil || input.WeekEnd == nil {
+			utils.HandleError(w, "week_start and week_end are required", http.StatusBadRequest, nil)
+			return
+		}
+		if msg := validatePrescriptionInput(input); msg != "" {
+			utils.HandleError(w, msg, http.StatusBadRequest, nil)
+			return
+		}
+
+		prescription, sectionID, err := database.CreatePrescription(exerciseID, input)
+		if err != nil {
+			writeCatalogError(w, "Unable to create prescription", err)
+			return
+		}
+		invalidateCatalogCache(sectionID)
+
+		utils.Logger.Info("Prescription created", zap.Int("exercise_id", exerciseID), zap.Int("prescription_id", prescription.ID))
+		utils.WriteStandardResponse(w, http.StatusCreated, "Prescription created", prescription)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// PATCH /admin/prescriptions/{id} updates a prescription, DELETE removes it
+func AdminPrescription(w http.ResponseWriter, r *http.Request) {
+	prescriptionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid prescription ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		var input models.PrescriptionInput
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+			return
+		}
+		if msg := validatePrescriptionInput(input); msg != "" {
+			utils.HandleError(w, msg, http.StatusBadRequest, nil)
+			return
+		}
+
+		prescription, sectionID, err := database.UpdatePrescription(prescriptionID, input)
+		if err != nil {
+			writeCatalogError(w, "Unable to update prescription", err)
+			return
+		}
+		invalidateCatalogCache(sectionID)
+
+		utils.Logger.Info("Prescription updated", zap.Int("prescription_id", prescriptionID))
+		utils.WriteStandardResponse(w, http.StatusOK, "Prescription updated", prescription)
+
+	case http.MethodDelete:
+		sectionID, err := database.DeletePrescription(prescriptionID)
+		if err != nil {
+			writeCatalogError(w, "Unable to delete prescription", err)
+			return
+		}
+		invalidateCatalogCache(sectionID)
+
+		utils.Logger.Info("Prescription deleted", zap.Int("prescription_id", prescriptionID))
+		utils.WriteStandardResponse(w, http.StatusOK, "Prescription deleted", nil)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /admin/exercises/{id}/instructions returns an exercise's instructions,
+// PUT replaces all of them
+func AdminExerciseInstructions(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid exercise ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		instructions, err := database.GetInstructions(exerciseID)
+		if err != nil {
+			utils.HandleError(w, "Unable to retrieve instructions", http.StatusInternalServerError, err)
+			return
+		}
+		utils.WriteStandardResponse(w, http.StatusOK, "Instructions retrieved successfully", instructions)
+
+	case http.MethodPut:
+		var input models.ExerciseInstructions
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+			return
+		}
+		for _, texts := range [][]string{input.Steps, input.Cues, input.Mistakes} {
+			if msg := validateInstructionTexts(texts); msg != "" {
+				utils.HandleError(w, msg, http.StatusBadRequest, nil)
+				return
+			}
+		}
+
+		if err := database.ReplaceInstructions(exerciseID, input); err != nil {
+			writeCatalogError(w, "Unable to update instructions", err)
+			return
+		}
+
+		utils.Logger.Info("Exercise instructions updated",
+			zap.Int("exercise_id", exerciseID),
+			zap.Int("steps", len(input.Steps)),
+			zap.Int("cues", len(input.Cues)),
+			zap.Int("mistakes", len(input.Mistakes)),
+		)
+		instructions, err := database.GetInstructions(exerciseID)
+		if err != nil {
+			utils.HandleError(w, "Unable to retrieve instructions", http.StatusInternalServerError, err)
+			return
+		}
+		utils.WriteStandardResponse(w, http.StatusOK, "Instructions updated", instructions)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// GET /admin/exercises/{id}/muscles returns the muscles an exercise trains,
+// PUT replaces all of them
+func AdminExerciseMuscles(w http.ResponseWriter, r *http.Request) {
+	exerciseID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid exercise ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		muscles, err := database.GetExerciseMuscles(exerciseID)
+		if err != nil {
+			utils.HandleError(w, "Unable to retrieve muscles", http.StatusInternalServerError, err)
+			return
+		}
+		utils.WriteStandardResponse(w, http.StatusOK, "Muscles retrieved successfully", muscles)
+
+	case http.MethodPut:
+		var input models.ExerciseMuscles
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+			return
+		}
+		if msg := validateExerciseMuscles(input); msg != "" {
+			utils.HandleError(w, msg, http.StatusBadRequest, nil)
+			return
+		}
+
+		if err := database.ReplaceExerciseMuscles(exerciseID, input); err != nil {
+			writeCatalogError(w, "Unable to update muscles", err)
+			return
+		}
+
+		utils.Logger.Info("Exercise muscles updated",
+			zap.Int("exercise_id", exerciseID),
+			zap.Strings("primary", input.Primary),
+			zap.Strings("secondary", input.Secondary),
+		)
+		muscles, err := database.GetExerciseMuscles(exerciseID)
+		if err != nil {
+			utils.HandleError(w, "Unable to retrieve muscles", http.StatusInternalServerError, err)
+			return
+		}
+		utils.WriteStandardResponse(w, http.StatusOK, "Muscles updated", muscles)
+
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// maps catalog errors from the database package to responses
+func writeCatalogError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, database.ErrSectionNotFound),
+		errors.Is(err, database.ErrExerciseNotFound),
+		errors.Is(err, database.ErrPrescriptionNotFound),
+		errors.Is(err, database.ErrProgramNotFound):
+		utils.HandleError(w, err.Error(), http.StatusNotFound, nil)
+	case errors.Is(err, database.ErrCatalogInUse):
+		utils.HandleError(w, "Users have logged workouts against this, it can't be deleted", http.StatusConflict, nil)
+	case errors.Is(err, database.ErrInvalidPrescription),
+		errors.Is(err, database.ErrReorderMismatch):
+		utils.HandleError(w, err.Error(), http.StatusBadRequest, nil)
+	default:
+		utils.HandleError(w, msg, http.StatusInternalServerError, err)
+	}
+}
+
+func validateSectionInput(input models.SectionInput) string {
+	if input.Name != nil {
+		*input.Name = strings.TrimSpace(*input.Name)
+		if *input.Name == "" || len(*input.Name) > 50 {
+			return "name must be 1 to 50 characters"
+		}
+	}
+	if input.Route != nil && (len(*input.Route) > 50 || !sectionRoutePattern.MatchString(*input.Route)) {
+		return "route must be lowercase words separated by dashes, e.g. upper-body"
+	}
+	return ""
+}
+
+func validateExerciseInput(input models.ExerciseInput) string {
+	if input.Name != nil {
+		*input.Name = strings.TrimSpace(*input.Name)
+		if *input.Name == "" || len(*input.Name) > 100 {
+			return "name must be 1 to 100 characters"
+		}
+	}
+	if (input.Substitution1 != nil && len(*input.Substitution1) > 100) ||
+		(input.Substitution2 != nil && len(*input.Substitution2) > 100) {
+		return "substitutions must be at most 100 characters"
+	}
+	if input.MeasurementType != nil && !measurementTypes[*input.MeasurementType] {
+		return "measurement_type must be weighted, bodyweight, bodyweight_added, assisted, duration or distance"
+	}
+	return ""
+}
+
+func validatePrescriptionInput(input models.PrescriptionInput) string {
+	if input.WeekStart != nil && *input.WeekStart < 1 {
+		return "week_start must be at least 1"
+	}
+	if input.WeekStart != nil && input.WeekEnd != nil && *input.WeekEnd < *input.WeekStart {
+		return "week_end must not be before week_start"
+	}
+	if (input.WarmupSets != nil && *input.WarmupSets < 0) || (input.WorkingSets != nil && *input.WorkingSets < 0) {
+		return "sets must not be negative"
+	}
+	if input.Load != nil && *input.Load < 0 {
+		return "load must not be negative"
+	}
+	if (input.Reps != nil && len(*input.Reps) > 20) || (input.RestTime != nil && len(*input.RestTime) > 20) {
+		return "reps and rest_time must be at most 20 characters"
+	}
+	return ""
+}
+
+// each list holds at most 30 entries of 1 to 500 characters
+func validateInstructionTexts(texts []string) string {
+	if len(texts) > 30 {
+		return "at most 30 steps, cues or mistakes are allowed"
+	}
+	for i := range texts {
+		texts[i] = strings.TrimSpace(texts[i])
+		if texts[i] == "" || len(texts[i]) > 500 {
+			return "instructions must be 1 to 500 characters"
+		}
+	}
+	return ""
+}
+
+// the muscles exercises can be tagged with
+var muscleGroups = map[string]bool{
+	models.MuscleChest:      true,
+	models.MuscleBack:       true,
+	models.MuscleShoulders:  true,
+	models.MuscleBiceps:     true,
+	models.MuscleTriceps:    true,
+	models.MuscleForearms:   true,
+	models.MuscleAbs:        true,
+	models.MuscleQuads:      true,
+	models.MuscleHamstrings: true,
+	models.MuscleGlutes:     true,
+	models.MuscleCalves:     true,
+}
+
+// every muscle is known and listed once, as primary or secondary
+func validateExerciseMuscles(input models.ExerciseMuscles) string {
+	seen := map[string]bool{}
+	for _, muscle := range append(append([]string{}, input.Primary...), input.Secondary...) {
+		if !muscleGroups[muscle] {
+			return fmt.Sprintf("unknown muscle %q", muscle)
+		}
+		if seen[muscle] {
+			return fmt.Sprintf("muscle %q is listed more than once", muscle)
+		}
+		seen[muscle] = true
+	}
+	return ""
+}
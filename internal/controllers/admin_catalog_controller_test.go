@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+func TestWriteCatalogError(t *testing.T) {
+	utils.Logger = zap.NewNop()
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"section in use", database.ErrCatalogInUse, http.StatusConflict},
+		{"wrapped in use", fmt.Errorf("delete: %w", database.ErrCatalogInUse), http.StatusConflict},
+		{"missing section", database.ErrSectionNotFound, http.StatusNotFound},
+		{"missing exercise", database.ErrExerciseNotFound, http.StatusNotFound},
+		{"unexpected", fmt.Errorf("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			writeCatalogError(w, "Unable to delete", tt.err)
+			if w.Code != tt.want {
+				t.Errorf("writeCatalogError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/training"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// PUT /admin/users/{id}/role changes a user's role
+func SetUserRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	adminID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid user ID format", http.StatusBadRequest, err)
+		return
+	}
+	// an admin demoting themselves could leave nobody able to manage roles
+	if userID == adminID {
+		utils.HandleError(w, "You cannot change your own role", http.StatusForbidden, nil)
+		return
+	}
+
+	var req struct {
+		Role models.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if !req.Role.Valid() {
+		utils.HandleError(w, "Role must be one of user, coach or admin", http.StatusBadRequest, nil)
+		return
+	}
+
+	if err := database.SetUserRole(userID, req.Role); err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			utils.HandleError(w, "User not found", http.StatusNotFound, nil)
+		} else {
+			utils.HandleError(w, "Unable to update role", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	utils.Logger.Info("User role changed",
+		zap.Int("admin_id", adminID),
+		zap.Int("user_id", userID),
+		zap.String("role", string(req.Role)),
+	)
+	utils.WriteStandardResponse(w, http.StatusOK, "Role updated", map[string]interface{}{
+		"user_id": userID,
+		"role":    req.Role,
+	})
+}
+
+// PUT /admin/users/{id}/progression-rule sets how a user's next-session
+// recommendations progress
+func SetUserProgressionRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	adminID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid user ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	setProgressionRule(w, r, userID, zap.Int("admin_id", adminID))
+}
+
+// PUT /admin/coaches/{id}/athletes/{athlete_id} links a coach to a user they coach
+// DELETE /admin/coaches/{id}/athletes/{athlete_id} unlinks them
+func CoachAthleteLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	adminID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	coachID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid coach ID format", http.StatusBadRequest, err)
+		return
+	}
+	athleteID, err := strconv.Atoi(r.PathValue("athlete_id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid athlete ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if err := database.UnlinkCoachAthlete(coachID, athleteID); err != nil {
+			utils.HandleError(w, "Unable to unlink coach", http.StatusInternalServerError, err)
+			return
+		}
+		utils.Logger.Info("Coach unlinked", zap.Int("admin_id", adminID), zap.Int("coach_id", coachID), zap.Int("athlete_id", athleteID))
+		utils.WriteStandardResponse(w, http.StatusOK, "Coach unlinked", nil)
+		return
+	}
+
+	if coachID == athleteID {
+		utils.HandleError(w, "A coach cannot coach themselves", http.StatusBadRequest, nil)
+		return
+	}
+	role, err := database.GetUserRole(coachID)
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			utils.HandleError(w, "User not found", http.StatusNotFound, nil)
+		} else {
+			utils.HandleError(w, "Unable to link coach", http.StatusInternalServerError, err)
+		}
+		return
+	}
+	if !role.Includes(models.RoleCoach) {
+		utils.HandleError(w, "User is not a coach", http.StatusBadRequest, nil)
+		return
+	}
+
+	if err := database.LinkCoachAthlete(coachID, athleteID); err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			utils.HandleError(w, "User not found", http.StatusNotFound, nil)
+		} else {
+			utils.HandleError(w, "Unable to link coach", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	utils.Logger.Info("Coach linked", zap.Int("admin_id", adminID), zap.Int("coach_id", coachID), zap.Int("athlete_id", athleteID))
+	utils.WriteStandardResponse(w, http.StatusOK, "Coach linked", map[string]interface{}{
+		"coach_id":   coachID,
+		"athlete_id": athleteID,
+	})
+}
+
+// sets the progression rule in the request body on the user, changedBy
+// identifies who changed it in the log
+func setProgressionRule(w http.ResponseWriter, r *http.Request, userID int, changedBy zap.Field) {
+	var req struct {
+		ProgressionRule string `json:"progression_rule"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if _, ok := training.ProgressionRuleFor(req.ProgressionRule); !ok {
+		utils.HandleError(w, "progression_rule must be double_progression or rpe", http.StatusBadRequest, nil)
+		return
+	}
+
+	preferences, err := saveUserPreferences(userID, models.UpdatePreferencesRequest{ProgressionRule: &req.ProgressionRule})
+	if err != nil {
+		if errors.Is(err, database.ErrUserNotFound) {
+			utils.HandleError(w, "User not found", http.StatusNotFound, nil)
+		} else {
+			utils.HandleError(w, "Unable to update progression rule", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	utils.Logger.Info("User progression rule changed",
+		changedBy,
+		zap.Int("user_id", userID),
+		zap.String("progression_rule", preferences.ProgressionRule),
+	)
+	utils.WriteStandardResponse(w, http.StatusOK, "Progression rule updated", map[string]interface{}{
+		"user_id":          userID,
+		"progression_rule": preferences.ProgressionRule,
+	})
+}
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/haikali3/gymbara-backend/internal/auth"
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// keeps a leaked account from minting keys without bound
+const maxActiveAPIKeys = 20
+
+// GET /user/api-keys lists the user's API keys, POST creates one
+func UserAPIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getUserAPIKeys(w, r)
+	case http.MethodPost:
+		createUserAPIKey(w, r)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func getUserAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	keys, err := database.ListAPIKeys(userID)
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve API keys", http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.Logger.Info("User API keys retrieved", zap.Int("user_id", userID), zap.Int("count", len(keys)))
+	utils.WriteStandardResponse(w, http.StatusOK, "API keys retrieved successfully", keys)
+}
+
+func createUserAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		utils.HandleError(w, "Name is required and must be at most 100 characters", http.StatusBadRequest, nil)
+		return
+	}
+	if len(req.Scopes) == 0 {
+		utils.HandleError(w, "At least one scope is required", http.StatusBadRequest, nil)
+		return
+	}
+	seen := make(map[string]bool, len(req.Scopes))
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !auth.IsValidAPIKeyScope(scope) {
+			utils.HandleError(w, "Unknown scope: "+scope, http.StatusBadRequest, nil)
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	count, err := database.CountActiveAPIKeys(userID)
+	if err != nil {
+		utils.HandleError(w, "Unable to create API key", http.StatusInternalServerError, err)
+		return
+	}
+	if count >= maxActiveAPIKeys {
+		utils.HandleError(w, "API key limit reached, revoke an unused key first", http.StatusConflict, nil)
+		return
+	}
+
+	key, prefix, keyHash, err := auth.GenerateAPIKey()
+	if err != nil {
+		utils.HandleError(w, "Unable to create API key", http.StatusInternalServerError, err)
+		return
+	}
+
+	apiKey, err := database.CreateAPIKey(userID, req.Name, prefix, keyHash, scopes)
+	if err != nil {
+		utils.HandleError(w, "Unable to create API key", http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.Logger.Info("User API key created", zap.Int("user_id", userID), zap.Int("api_key_id", apiKey.ID))
+	// the full key is only ever returned here
+	utils.WriteStandardResponse(w, http.StatusCreated, "API key created, copy it now as it will not be shown again", models.CreatedAPIKey{
+		APIKey: apiKey,
+		Key:    key,
+	})
+}
+
+// DELETE /user/api-keys/{id} revokes an API key
+func RevokeUserAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	keyID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid API key ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	if err := database.RevokeAPIKey(userID, keyID); err != nil {
+		if errors.Is(err, database.ErrAPIKeyNotFound) {
+			utils.HandleError(w, "API key not found", http.StatusNotFound, nil)
+		} else {
+			utils.HandleError(w, "Unable to revoke API key", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	utils.Logger.Info("User API key revoked", zap.Int("user_id", userID), zap.Int("api_key_id", keyID))
+	utils.WriteStandardResponse(w, http.StatusOK, "API key revoked", nil)
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// swapped out in tests, they run without a database
+var (
+	isCoachOf           = database.IsCoachOf
+	saveUserPreferences = database.UpdateUserPreferences
+)
+
+// PUT /coach/athletes/{id}/progression-rule sets how the next-session
+// recommendations of one of the coach's athletes progress
+func SetAthleteProgressionRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	coachID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	athleteID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid athlete ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	coaches, err := isCoachOf(coachID, athleteID)
+	if err != nil {
+		utils.HandleError(w, "Unable to update progression rule", http.StatusInternalServerError, err)
+		return
+	}
+	if !coaches {
+		utils.Logger.Warn("Coach denied on a user they don't coach", zap.Int("coach_id", coachID), zap.Int("user_id", athleteID))
+		utils.HandleError(w, "You don't coach this user", http.StatusForbidden, nil)
+		return
+	}
+
+	setProgressionRule(w, r, athleteID, zap.Int("coach_id", coachID))
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+func TestSetAthleteProgressionRule(t *testing.T) {
+	utils.Logger = zap.NewNop()
+
+	coachOf, save := isCoachOf, saveUserPreferences
+	defer func() { isCoachOf, saveUserPreferences = coachOf, save }()
+
+	// coach 10 coaches user 1 only
+	isCoachOf = func(coachID, athleteID int) (bool, error) {
+		return coachID == 10 && athleteID == 1, nil
+	}
+
+	tests := []struct {
+		name       string
+		coachID    int
+		athleteID  string
+		body       string
+		wantStatus int
+		wantSaved  int
+	}{
+		{"coach sets their athlete's rule", 10, "1", `{"progression_rule": "rpe"}`, http.StatusOK, 1},
+		{"coach on a user they don't coach", 10, "2", `{"progression_rule": "rpe"}`, http.StatusForbidden, 0},
+		{"another coach on the athlete", 11, "1", `{"progression_rule": "rpe"}`, http.StatusForbidden, 0},
+		{"unknown rule", 10, "1", `{"progression_rule": "linear"}`, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := 0
+			saveUserPreferences = func(userID int, req models.UpdatePreferencesRequest) (models.UserPreferences, error) {
+				saved = userID
+				return models.UserPreferences{ProgressionRule: *req.ProgressionRule}, nil
+			}
+
+			r := httptest.NewRequest(http.MethodPut, "/coach/athletes/"+tt.athleteID+"/progression-rule", strings.NewReader(tt.body))
+			r.SetPathValue("id", tt.athleteID)
+			r = r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, tt.coachID))
+			w := httptest.NewRecorder()
+			SetAthleteProgressionRule(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if saved != tt.wantSaved {
+				t.Errorf("saved the rule of user %d, want %d", saved, tt.wantSaved)
+			}
+		})
+	}
+}
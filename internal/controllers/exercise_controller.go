@@ -3,6 +3,7 @@ package controllers
 
 import (
 	"database/sql"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
@@ -20,6 +21,18 @@ type ExerciseGuideDTO struct {
 	Name          string   `json:"name"`
 	Notes         string   `json:"notes"`
 	Substitutions []string `json:"substitutions"`
+	Steps         []string `json:"steps"`
+	Cues          []string `json:"cues"`
+	Mistakes      []string `json:"mistakes"`
+}
+
+// cache keys are per program week, invalidateCatalogCache drops all weeks of a section
+func exerciseListCacheKey(sectionID, week int) string {
+	return fmt.Sprintf("exercise_list_%d_week_%d", sectionID, week)
+}
+
+func exerciseDetailsCacheKey(sectionID, week int) string {
+	return fmt.Sprintf("exercise_details_%d_week_%d", sectionID, week)
 }
 
 // Get exercises for initial load
@@ -29,18 +42,37 @@ func GetExercisesList(w http.ResponseWriter, r *http.Request) {
 		utils.HandleError(w, "Missing workout_section_id parameter", http.StatusBadRequest, nil)
 		return
 	}
+	sectionID, err := strconv.Atoi(workoutSectionID)
+	if err != nil {
+		utils.HandleError(w, "Invalid workout_section_id parameter", http.StatusBadRequest, err)
+		return
+	}
+
+	week, ok := programWeek(w, r, sectionID)
+	if !ok {
+		return
+	}
+
+	// the cache holds kg loads, they are converted for every user
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
 
-	cacheKey := "exercise_list_" + workoutSectionID
+	cacheKey := exerciseListCacheKey(sectionID, week)
 
 	// check if response is in the cache, if no, query db
 	if cachedData, found := workoutCache.Get(cacheKey); found {
-		utils.Logger.Info("Returning cached exercise list", zap.String("sectionID", workoutSectionID))
-		utils.WriteStandardResponse(w, http.StatusOK, "Exercise list retrieved successfully (from cache)", cachedData)
-		return
+		if exerciseList, ok := cachedData.([]models.ExerciseDetails); ok {
+			utils.Logger.Info("Returning cached exercise list", zap.String("sectionID", workoutSectionID))
+			utils.WriteStandardResponse(w, http.StatusOK, "Exercise list retrieved successfully (from cache)",
+				prescriptionsInUnit(exerciseList, preferences))
+			return
+		}
 	}
 
 	// use the pre-prepared statement directly
-	rows, err := database.StmtGetExercisesBySectionID.Query(workoutSectionID)
+	rows, err := database.StmtGetExercisesBySectionID.Query(sectionID, week)
 	if err != nil {
 		utils.HandleError(w, "Unable to query exercises for workout_section_id: "+workoutSectionID, http.StatusInternalServerError, err)
 		return
@@ -63,12 +95,13 @@ func GetExercisesList(w http.ResponseWriter, r *http.Request) {
 	utils.Logger.Info("Retrieved exercises",
 		zap.Int("count", len(exerciseList)),
 		zap.String("workout_section_id", workoutSectionID),
+		zap.Int("week", week),
 	)
 
 	// store cache for 3 hours
 	workoutCache.Set(cacheKey, exerciseList, 3*time.Hour)
 
-	utils.WriteStandardResponse(w, http.StatusOK, "Exercise list retrieved successfully", exerciseList)
+	utils.WriteStandardResponse(w, http.StatusOK, "Exercise list retrieved successfully", prescriptionsInUnit(exerciseList, preferences))
 }
 
 // Get detailed exercise information
@@ -79,17 +112,36 @@ func GetExerciseDetails(w http.ResponseWriter, r *http.Request) {
 		utils.HandleError(w, "Missing workout_section_id parameter", http.StatusBadRequest, nil)
 		return
 	}
+	sectionID, err := strconv.Atoi(workoutSectionID)
+	if err != nil {
+		utils.HandleError(w, "Invalid workout_section_id parameter", http.StatusBadRequest, err)
+		return
+	}
+
+	week, ok := programWeek(w, r, sectionID)
+	if !ok {
+		return
+	}
+
+	// the cache holds kg loads, they are converted for every user
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
 
-	cacheKey := "exercise_details_" + workoutSectionID
+	cacheKey := exerciseDetailsCacheKey(sectionID, week)
 
 	// Check cache first
 	if cachedData, found := workoutCache.Get(cacheKey); found {
-		utils.Logger.Info("Returning cached exercise details", zap.String("workout_section_id", workoutSectionID))
-		utils.WriteStandardResponse(w, http.StatusOK, "Exercise details retrieved successfully (from cache)", cachedData)
-		return
+		if exerciseDetails, ok := cachedData.([]models.ExerciseDetails); ok {
+			utils.Logger.Info("Returning cached exercise details", zap.String("workout_section_id", workoutSectionID))
+			utils.WriteStandardResponse(w, http.StatusOK, "Exercise details retrieved successfully (from cache)",
+				prescriptionsInUnit(exerciseDetails, preferences))
+			return
+		}
 	}
 
-	rows, err := database.StmtGetExerciseDetails.Query(workoutSectionID)
+	rows, err := database.StmtGetExerciseDetails.Query(sectionID, week)
 	if err != nil {
 		utils.HandleError(w, "Unable to query exercise details", http.StatusInternalServerError, err)
 		utils.Logger.Error("Failed to query exercise details",
@@ -110,6 +162,7 @@ func GetExerciseDetails(w http.ResponseWriter, r *http.Request) {
 		if err := rows.Scan(
 			&detail.ID,
 			&detail.Name,
+			&detail.MeasurementType,
 			&detail.WarmupSets,
 			&detail.WorkSets,
 			&detail.Reps,
@@ -125,11 +178,12 @@ func GetExerciseDetails(w http.ResponseWriter, r *http.Request) {
 	utils.Logger.Info("Retrieved exercise details",
 		zap.Int("count", len(exerciseDetails)),
 		zap.String("workout_section_id", workoutSectionID),
+		zap.Int("week", week),
 	)
 
 	workoutCache.Set(cacheKey, exerciseDetails, 3*time.Hour)
 
-	utils.WriteStandardResponse(w, http.StatusOK, "Exercise details retrieved successfully", exerciseDetails)
+	utils.WriteStandardResponse(w, http.StatusOK, "Exercise details retrieved successfully", prescriptionsInUnit(exerciseDetails, preferences))
 }
 
 // GET /workout-sections/exercises/42/guide
@@ -190,12 +244,21 @@ func GetExerciseGuide(w http.ResponseWriter, r *http.Request) {
 		subs = append(subs, sub2.String)
 	}
 
+	instructions, err := database.GetInstructions(id)
+	if err != nil {
+		utils.HandleError(w, "Error querying exercise instructions", http.StatusInternalServerError, err)
+		return
+	}
+
 	// Assemble the DTO
 	dto := ExerciseGuideDTO{
 		ID:            id,
 		Name:          name,
 		Notes:         notes.String, // empty string if null
 		Substitutions: subs,
+		Steps:         instructions.Steps,
+		Cues:          instructions.Cues,
+		Mistakes:      instructions.Mistakes,
 	}
 
 	utils.Logger.Info("Fetched exercise guide",
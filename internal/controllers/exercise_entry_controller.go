@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/training"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// swapped out in tests, they run without a database
+var (
+	getExerciseEntry            = database.GetExerciseEntry
+	updateExerciseEntrySets     = database.UpdateExerciseEntry
+	softDeleteExerciseEntry     = database.DeleteExerciseEntry
+	restoreDeletedExerciseEntry = database.RestoreExerciseEntry
+)
+
+// PATCH /user/exercise-entries/{id} corrects a logged entry
+// DELETE /user/exercise-entries/{id} deletes it, it can be restored for a while
+func ExerciseEntry(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPatch:
+		updateExerciseEntry(w, r)
+	case http.MethodDelete:
+		deleteExerciseEntry(w, r)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func updateExerciseEntry(w http.ResponseWriter, r *http.Request) {
+	userID, entryID, ok := exerciseEntryParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.UpdateEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if req.Sets == nil && req.Reps == nil && req.Load == nil {
+		utils.HandleError(w, "sets, custom_reps or custom_load is required", http.StatusBadRequest, nil)
+		return
+	}
+
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+	unit, msg := requestUnit(req.Unit, preferences)
+	if msg != "" {
+		utils.HandleError(w, msg, http.StatusBadRequest, nil)
+		return
+	}
+
+	entry, err := getExerciseEntry(userID, entryID)
+	if err != nil {
+		writeExerciseEntryError(w, "Unable to retrieve exercise entry", err)
+		return
+	}
+
+	sets := req.Sets
+	setsToKg(sets, unit)
+	if sets == nil {
+		if len(entry.Sets) > 1 {
+			utils.HandleError(w, "Entry has more than one set, send sets to edit it", http.StatusBadRequest, nil)
+			return
+		}
+
+		set := models.UserExerciseSet{SetIndex: 1, SetType: models.SetTypeWorking, Reps: entry.CustomReps, Load: entry.CustomLoad}
+		if len(entry.Sets) == 1 {
+			set = entry.Sets[0]
+		}
+		if req.Reps != nil {
+			set.Reps = *req.Reps
+		}
+		if req.Load != nil {
+			set.Load = training.ToKg(*req.Load, unit)
+			set.LoadUnit = unit
+		}
+		sets = []models.UserExerciseSet{set}
+	}
+	if len(sets) == 0 {
+		utils.HandleError(w, "An entry needs at least one set, delete it instead", http.StatusBadRequest, nil)
+		return
+	}
+	if msg := normalizeSets(sets, entry.MeasurementType); msg != "" {
+		utils.HandleError(w, msg, http.StatusBadRequest, nil)
+		return
+	}
+
+	entry, err = updateExerciseEntrySets(userID, entryID, sets)
+	if err != nil {
+		writeExerciseEntryError(w, "Unable to update exercise entry", err)
+		return
+	}
+	entryInUnit(&entry, preferences.Unit)
+
+	utils.Logger.Info("Exercise entry updated", zap.Int("user_id", userID), zap.Int("entry_id", entryID))
+	utils.WriteStandardResponse(w, http.StatusOK, "Exercise entry updated", entry)
+}
+
+func deleteExerciseEntry(w http.ResponseWriter, r *http.Request) {
+	userID, entryID, ok := exerciseEntryParams(w, r)
+	if !ok {
+		return
+	}
+
+	restorableUntil, err := softDeleteExerciseEntry(userID, entryID)
+	if err != nil {
+		writeExerciseEntryError(w, "Unable to delete exercise entry", err)
+		return
+	}
+
+	utils.Logger.Info("Exercise entry deleted", zap.Int("user_id", userID), zap.Int("entry_id", entryID))
+	utils.WriteStandardResponse(w, http.StatusOK, "Exercise entry deleted", models.DeletedEntry{
+		ID:              entryID,
+		RestorableUntil: restorableUntil,
+	})
+}
+
+// POST /user/exercise-entries/{id}/restore undoes a recent delete
+func RestoreExerciseEntry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, entryID, ok := exerciseEntryParams(w, r)
+	if !ok {
+		return
+	}
+
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+
+	entry, err := restoreDeletedExerciseEntry(userID, entryID)
+	if err != nil {
+		writeExerciseEntryError(w, "Unable to restore exercise entry", err)
+		return
+	}
+	entryInUnit(&entry, preferences.Unit)
+
+	utils.Logger.Info("Exercise entry restored", zap.Int("user_id", userID), zap.Int("entry_id", entryID))
+	utils.WriteStandardResponse(w, http.StatusOK, "Exercise entry restored", entry)
+}
+
+// reads the user ID from the context and the entry ID from the path. It
+// writes the error response when it returns false.
+func exerciseEntryParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return 0, 0, false
+	}
+
+	entryID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid exercise entry ID format", http.StatusBadRequest, err)
+		return 0, 0, false
+	}
+	return userID, entryID, true
+}
+
+// maps exercise entry errors from the database package to responses
+func writeExerciseEntryError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, database.ErrEntryNotFound):
+		utils.HandleError(w, err.Error(), http.StatusNotFound, nil)
+	case errors.Is(err, database.ErrEntryNotRestorable):
+		utils.HandleError(w, err.Error(), http.StatusGone, nil)
+	default:
+		utils.HandleError(w, msg, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// fakeEntries stands in for UserExercisesDetails, scoped to the user owning
+// the workout like the queries
+type fakeEntries struct {
+	entries map[int]*fakeEntry
+	updated bool
+}
+
+type fakeEntry struct {
+	owner      int
+	entry      models.UserProgressResponse
+	deletedFor *time.Duration
+}
+
+func (f *fakeEntries) owned(userID, entryID int) *fakeEntry {
+	if e, ok := f.entries[entryID]; ok && e.owner == userID {
+		return e
+	}
+	return nil
+}
+
+func (f *fakeEntries) get(userID, entryID int) (models.UserProgressResponse, error) {
+	e := f.owned(userID, entryID)
+	if e == nil || e.deletedFor != nil {
+		return models.UserProgressResponse{}, database.ErrEntryNotFound
+	}
+	return e.entry, nil
+}
+
+func (f *fakeEntries) update(userID, entryID int, sets []models.UserExerciseSet) (models.UserProgressResponse, error) {
+	if _, err := f.get(userID, entryID); err != nil {
+		return models.UserProgressResponse{}, err
+	}
+	f.updated = true
+	f.entries[entryID].entry.Sets = sets
+	return f.entries[entryID].entry, nil
+}
+
+func (f *fakeEntries) delete(userID, entryID int) (time.Time, error) {
+	if _, err := f.get(userID, entryID); err != nil {
+		return time.Time{}, err
+	}
+	deletedFor := time.Duration(0)
+	f.entries[entryID].deletedFor = &deletedFor
+	return time.Now().Add(24 * time.Hour), nil
+}
+
+func (f *fakeEntries) restore(userID, entryID int) (models.UserProgressResponse, error) {
+	e := f.owned(userID, entryID)
+	if e == nil {
+		return models.UserProgressResponse{}, database.ErrEntryNotFound
+	}
+	if e.deletedFor != nil {
+		if !database.EntryRestorable(*e.deletedFor) {
+			return models.UserProgressResponse{}, database.ErrEntryNotRestorable
+		}
+		e.deletedFor = nil
+	}
+	return e.entry, nil
+}
+
+func TestExerciseEntry(t *testing.T) {
+	utils.Logger = zap.NewNop()
+
+	get, update, del, restore, preferences := getExerciseEntry, updateExerciseEntrySets, softDeleteExerciseEntry, restoreDeletedExerciseEntry, userPreferences
+	defer func() {
+		getExerciseEntry, updateExerciseEntrySets, softDeleteExerciseEntry, restoreDeletedExerciseEntry, userPreferences = get, update, del, restore, preferences
+	}()
+	userPreferences = func(int) (models.UserPreferences, error) {
+		return models.UserPreferences{Unit: models.UnitKg, PlateIncrement: 2.5}, nil
+	}
+
+	const owner, otherUser = 1, 2
+	set := func(index, reps int, load float64) models.UserExerciseSet {
+		return models.UserExerciseSet{SetIndex: index, SetType: models.SetTypeWorking, Reps: reps, Load: load}
+	}
+	deletedFor := func(d time.Duration) *time.Duration { return &d }
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		userID      int
+		body        string
+		deletedFor  *time.Duration
+		wantStatus  int
+		wantUpdated bool
+	}{
+		{"reps on a single set entry", http.MethodPatch, "/user/exercise-entries/1", owner, `{"custom_reps": 6}`, nil, http.StatusOK, true},
+		{"load on a single set entry", http.MethodPatch, "/user/exercise-entries/1", owner, `{"custom_load": 65}`, nil, http.StatusOK, true},
+		{"reps on a multi set entry", http.MethodPatch, "/user/exercise-entries/2", owner, `{"custom_reps": 6}`, nil, http.StatusBadRequest, false},
+		{"load on a multi set entry", http.MethodPatch, "/user/exercise-entries/2", owner, `{"custom_load": 65}`, nil, http.StatusBadRequest, false},
+		{"sets on a multi set entry", http.MethodPatch, "/user/exercise-entries/2", owner, `{"sets": [{"reps": 6, "load": 65}]}`, nil, http.StatusOK, true},
+		{"another user's entry", http.MethodPatch, "/user/exercise-entries/1", otherUser, `{"custom_reps": 6}`, nil, http.StatusNotFound, false},
+		{"deleted entry", http.MethodPatch, "/user/exercise-entries/1", owner, `{"custom_reps": 6}`, deletedFor(time.Minute), http.StatusNotFound, false},
+		{"delete", http.MethodDelete, "/user/exercise-entries/1", owner, "", nil, http.StatusOK, false},
+		{"delete another user's entry", http.MethodDelete, "/user/exercise-entries/1", otherUser, "", nil, http.StatusNotFound, false},
+		{"restore within the window", http.MethodPost, "/user/exercise-entries/1/restore", owner, "", deletedFor(23 * time.Hour), http.StatusOK, false},
+		{"restore after the window", http.MethodPost, "/user/exercise-entries/1/restore", owner, "", deletedFor(25 * time.Hour), http.StatusGone, false},
+		{"restore another user's entry", http.MethodPost, "/user/exercise-entries/1/restore", otherUser, "", deletedFor(time.Minute), http.StatusNotFound, false},
+		{"restore an entry that is not deleted", http.MethodPost, "/user/exercise-entries/1/restore", owner, "", nil, http.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := &fakeEntries{entries: map[int]*fakeEntry{
+				1: {owner: owner, deletedFor: tt.deletedFor, entry: models.UserProgressResponse{
+					ID: 1, MeasurementType: models.MeasurementWeighted, CustomReps: 5, CustomLoad: 60,
+					Sets: []models.UserExerciseSet{set(1, 5, 60)},
+				}},
+				2: {owner: owner, entry: models.UserProgressResponse{
+					ID: 2, MeasurementType: models.MeasurementWeighted, CustomReps: 5, CustomLoad: 60,
+					Sets: []models.UserExerciseSet{set(1, 5, 60), set(2, 5, 60)},
+				}},
+			}}
+			getExerciseEntry, updateExerciseEntrySets, softDeleteExerciseEntry, restoreDeletedExerciseEntry = entries.get, entries.update, entries.delete, entries.restore
+
+			r := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			r.SetPathValue("id", strings.Split(strings.TrimPrefix(tt.path, "/user/exercise-entries/"), "/")[0])
+			r = r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, tt.userID))
+			w := httptest.NewRecorder()
+			if tt.method == http.MethodPost {
+				RestoreExerciseEntry(w, r)
+			} else {
+				ExerciseEntry(w, r)
+			}
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if entries.updated != tt.wantUpdated {
+				t.Errorf("updated = %v, want %v", entries.updated, tt.wantUpdated)
+			}
+		})
+	}
+}
+
+func TestExerciseEntryUpdatesTheSingleSet(t *testing.T) {
+	utils.Logger = zap.NewNop()
+
+	get, update, preferences := getExerciseEntry, updateExerciseEntrySets, userPreferences
+	defer func() {
+		getExerciseEntry, updateExerciseEntrySets, userPreferences = get, update, preferences
+	}()
+	userPreferences = func(int) (models.UserPreferences, error) {
+		return models.UserPreferences{Unit: models.UnitKg, PlateIncrement: 2.5}, nil
+	}
+	entries := &fakeEntries{entries: map[int]*fakeEntry{
+		7: {owner: 1, entry: models.UserProgressResponse{
+			ID: 7, MeasurementType: models.MeasurementWeighted, CustomReps: 5, CustomLoad: 60,
+			Sets: []models.UserExerciseSet{{SetIndex: 1, SetType: models.SetTypeWorking, Reps: 5, Load: 60}},
+		}},
+	}}
+	getExerciseEntry, updateExerciseEntrySets = entries.get, entries.update
+
+	r := httptest.NewRequest(http.MethodPatch, "/user/exercise-entries/7", strings.NewReader(`{"custom_reps": 8}`))
+	r.SetPathValue("id", "7")
+	r = r.WithContext(context.WithValue(r.Context(), middleware.UserIDKey, 1))
+	w := httptest.NewRecorder()
+	ExerciseEntry(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	var response struct {
+		Data models.UserProgressResponse `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	sets := response.Data.Sets
+	if len(sets) != 1 || sets[0].Reps != 8 || sets[0].Load != 60 {
+		t.Errorf("sets = %+v, want one set of 8 reps at 60", sets)
+	}
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// GET /user/identities lists the provider accounts the user can sign in with
+func GetUserIdentities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	identities, err := database.ListIdentities(userID)
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve identities", http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.Logger.Info("User identities retrieved", zap.Int("user_id", userID), zap.Int("count", len(identities)))
+	utils.WriteStandardResponse(w, http.StatusOK, "Identities retrieved successfully", identities)
+}
+
+// DELETE /user/identities/{id} unlinks a provider account
+func UnlinkUserIdentity(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	identityID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid identity ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	if err := database.UnlinkIdentity(userID, identityID); err != nil {
+		switch {
+		case errors.Is(err, database.ErrIdentityNotFound):
+			utils.HandleError(w, "Identity not found", http.StatusNotFound, nil)
+		case errors.Is(err, database.ErrLastIdentity):
+			utils.HandleError(w, "Cannot unlink your only sign-in method", http.StatusConflict, nil)
+		default:
+			utils.HandleError(w, "Unable to unlink identity", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	utils.Logger.Info("User identity unlinked", zap.Int("user_id", userID), zap.Int("identity_id", identityID))
+	utils.WriteStandardResponse(w, http.StatusOK, "Identity unlinked", nil)
+}
@@ -0,0 +1,303 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// upper bound for ?week= previews and program lengths
+const maxProgramWeeks = 52
+
+// GET /programs lists the available programs
+func GetPrograms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	programs, err := database.ListPrograms()
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve programs", http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteStandardResponse(w, http.StatusOK, "Programs retrieved successfully", programs)
+}
+
+// GET /user/program returns the user's current enrollment, week, day and the
+// sessions due today
+func GetUserProgram(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	userProgram, err := database.GetUserProgram(userID)
+	if err != nil {
+		writeProgramError(w, "Unable to retrieve program", err)
+		return
+	}
+	utils.WriteStandardResponse(w, http.StatusOK, "Program retrieved successfully", userProgram)
+}
+
+// GET /user/program/history lists all of the user's enrollments, newest first
+func GetUserProgramHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	history, err := database.ListUserPrograms(userID)
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve program history", http.StatusInternalServerError, err)
+		return
+	}
+	utils.WriteStandardResponse(w, http.StatusOK, "Program history retrieved successfully", history)
+}
+
+// POST /user/program/start enrolls the user in a program
+func StartUserProgram(w http.ResponseWriter, r *http.Request) {
+	enrollUserProgram(w, r, "Program started", database.StartProgram)
+}
+
+// POST /user/program/switch ends the current enrollment and starts another program
+func SwitchUserProgram(w http.ResponseWriter, r *http.Request) {
+	enrollUserProgram(w, r, "Program switched", database.SwitchProgram)
+}
+
+func enrollUserProgram(w http.ResponseWriter, r *http.Request, msg string,
+	enroll func(userID, programID int, startDate time.Time, trainingDays []int) (models.UserProgram, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	var req models.EnrollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if req.ProgramID == 0 {
+		utils.HandleError(w, "program_id is required", http.StatusBadRequest, nil)
+		return
+	}
+	startDate, trainingDays, errMsg := parseEnrollment(req.StartDate, req.TrainingDays)
+	if errMsg != "" {
+		utils.HandleError(w, errMsg, http.StatusBadRequest, nil)
+		return
+	}
+
+	userProgram, err := enroll(userID, req.ProgramID, startDate, trainingDays)
+	if err != nil {
+		writeProgramError(w, "Unable to start program", err)
+		return
+	}
+
+	utils.Logger.Info(msg,
+		zap.Int("user_id", userID),
+		zap.Int("program_id", req.ProgramID),
+		zap.String("start_date", userProgram.StartDate),
+	)
+	utils.WriteStandardResponse(w, http.StatusOK, msg, userProgram)
+}
+
+// POST /user/program/restart starts the current program again from week 1
+func RestartUserProgram(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	// the body is optional
+	var req models.RestartRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	startDate, trainingDays, errMsg := parseEnrollment(req.StartDate, req.TrainingDays)
+	if errMsg != "" {
+		utils.HandleError(w, errMsg, http.StatusBadRequest, nil)
+		return
+	}
+
+	userProgram, err := database.RestartProgram(userID, startDate, trainingDays)
+	if err != nil {
+		writeProgramError(w, "Unable to restart program", err)
+		return
+	}
+
+	utils.Logger.Info("Program restarted", zap.Int("user_id", userID), zap.Int("program_id", userProgram.Program.ID))
+	utils.WriteStandardResponse(w, http.StatusOK, "Program restarted", userProgram)
+}
+
+// POST /user/program/pause pauses the current program
+func PauseUserProgram(w http.ResponseWriter, r *http.Request) {
+	transitionUserProgram(w, r, "Program paused", database.PauseProgram)
+}
+
+// POST /user/program/resume resumes a paused program where it was left
+func ResumeUserProgram(w http.ResponseWriter, r *http.Request) {
+	transitionUserProgram(w, r, "Program resumed", database.ResumeProgram)
+}
+
+func transitionUserProgram(w http.ResponseWriter, r *http.Request, msg string,
+	transition func(userID int) (models.UserProgram, error)) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	userProgram, err := transition(userID)
+	if err != nil {
+		writeProgramError(w, "Unable to update program", err)
+		return
+	}
+
+	utils.Logger.Info(msg, zap.Int("user_id", userID), zap.Int("program_id", userProgram.Program.ID))
+	utils.WriteStandardResponse(w, http.StatusOK, msg, userProgram)
+}
+
+// parses an optional start date, defaulting to today, and checks training
+// days are distinct days of the week. Returns an error message for the client.
+func parseEnrollment(startDateParam string, trainingDays []int) (time.Time, []int, string) {
+	startDate := time.Now().UTC()
+	if startDateParam != "" {
+		parsed, err := time.Parse("2006-01-02", startDateParam)
+		if err != nil {
+			return time.Time{}, nil, "start_date must be formatted as YYYY-MM-DD"
+		}
+		startDate = parsed
+	}
+
+	days := append([]int(nil), trainingDays...)
+	sort.Ints(days)
+	for i, day := range days {
+		if day < 1 || day > 7 {
+			return time.Time{}, nil, "training_days must be between 1 and 7"
+		}
+		if i > 0 && days[i-1] == day {
+			return time.Time{}, nil, "training_days must not repeat"
+		}
+	}
+	return startDate, days, ""
+}
+
+// maps enrollment errors from the database package to responses
+func writeProgramError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, database.ErrNotEnrolled):
+		utils.HandleError(w, "You are not enrolled in a program", http.StatusNotFound, nil)
+	case errors.Is(err, database.ErrProgramNotFound):
+		utils.HandleError(w, "Program not found", http.StatusNotFound, nil)
+	case errors.Is(err, database.ErrAlreadyEnrolled):
+		utils.HandleError(w, "You are already enrolled in a program, switch or restart it instead", http.StatusConflict, nil)
+	case errors.Is(err, database.ErrProgramNotActive),
+		errors.Is(err, database.ErrProgramNotPaused):
+		utils.HandleError(w, err.Error(), http.StatusConflict, nil)
+	default:
+		utils.HandleError(w, msg, http.StatusInternalServerError, err)
+	}
+}
+
+// POST /admin/programs creates a program
+func AdminPrograms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input models.ProgramInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if input.Name == nil || input.LengthWeeks == nil {
+		utils.HandleError(w, "name and length_weeks are required", http.StatusBadRequest, nil)
+		return
+	}
+	*input.Name = strings.TrimSpace(*input.Name)
+	if *input.Name == "" || len(*input.Name) > 100 {
+		utils.HandleError(w, "name must be 1 to 100 characters", http.StatusBadRequest, nil)
+		return
+	}
+	if *input.LengthWeeks < 1 || *input.LengthWeeks > maxProgramWeeks {
+		utils.HandleError(w, "length_weeks must be between 1 and 52", http.StatusBadRequest, nil)
+		return
+	}
+
+	program, err := database.CreateProgram(input)
+	if err != nil {
+		utils.HandleError(w, "Unable to create program", http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.Logger.Info("Program created", zap.Int("program_id", program.ID))
+	utils.WriteStandardResponse(w, http.StatusCreated, "Program created", program)
+}
+
+// programWeek returns the ?week= override or else the user's current week in
+// the section's program. It writes the error response when it returns false.
+func programWeek(w http.ResponseWriter, r *http.Request, sectionID int) (int, bool) {
+	if weekParam := r.URL.Query().Get("week"); weekParam != "" {
+		week, err := strconv.Atoi(weekParam)
+		if err != nil || week < 1 || week > maxProgramWeeks {
+			utils.HandleError(w, "week must be a number between 1 and 52", http.StatusBadRequest, err)
+			return 0, false
+		}
+		return week, true
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return 0, false
+	}
+
+	week, err := database.GetSectionWeek(userID, sectionID)
+	if err != nil {
+		utils.HandleError(w, "Unable to determine program week", http.StatusInternalServerError, err)
+		return 0, false
+	}
+	return week, true
+}
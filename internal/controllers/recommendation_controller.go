@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/training"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// GET /user/recommendations?workout_section_id=1 suggests the load and reps
+// of each exercise of a section for the user's next session, from the
+// prescription of their current program week and their last logged entry.
+// rule overrides the user's progression rule.
+func GetSessionRecommendations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("workout_section_id") == "" {
+		utils.HandleError(w, "Missing workout_section_id parameter", http.StatusBadRequest, nil)
+		return
+	}
+	sectionID, err := strconv.Atoi(query.Get("workout_section_id"))
+	if err != nil || sectionID <= 0 {
+		utils.HandleError(w, "Invalid workout_section_id parameter", http.StatusBadRequest, err)
+		return
+	}
+
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+	ruleName := preferences.ProgressionRule
+	if query.Get("rule") != "" {
+		ruleName = query.Get("rule")
+	}
+	rule, ok := training.ProgressionRuleFor(ruleName)
+	if !ok {
+		utils.HandleError(w, "Invalid rule parameter: must be double_progression or rpe", http.StatusBadRequest, nil)
+		return
+	}
+
+	week, err := database.GetSectionWeek(userID, sectionID)
+	if err != nil {
+		utils.HandleError(w, "Unable to determine program week", http.StatusInternalServerError, err)
+		return
+	}
+	details, err := database.ListSectionPrescriptions(sectionID, week)
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve recommendations", http.StatusInternalServerError, err)
+		return
+	}
+	if len(details) == 0 {
+		utils.HandleError(w, "No exercises found for workout section", http.StatusNotFound, nil)
+		return
+	}
+
+	// durations and distances have no load or reps to progress
+	var exerciseIDs []int
+	for _, detail := range details {
+		if detail.MeasurementType != models.MeasurementDuration && detail.MeasurementType != models.MeasurementDistance {
+			exerciseIDs = append(exerciseIDs, detail.ID)
+		}
+	}
+	last, err := database.LastEntries(userID, exerciseIDs)
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve recommendations", http.StatusInternalServerError, err)
+		return
+	}
+
+	increment := training.ToKg(preferences.PlateIncrement, preferences.Unit)
+	recommendations := models.SessionRecommendations{
+		SectionID: sectionID,
+		Week:      week,
+		Rule:      ruleName,
+		Exercises: []models.Recommendation{},
+	}
+	for _, detail := range details {
+		if detail.MeasurementType == models.MeasurementDuration || detail.MeasurementType == models.MeasurementDistance {
+			continue
+		}
+		var lastEntry *models.UserProgressResponse
+		if entry, ok := last[detail.ID]; ok {
+			lastEntry = &entry
+		}
+		rec := rule.Recommend(training.Prescription{
+			MeasurementType: detail.MeasurementType,
+			Sets:            detail.WorkSets,
+			Reps:            detail.Reps,
+			RPE:             detail.RPE,
+			Load:            detail.Load,
+		}, lastEntry, increment)
+		rec.ExerciseID, rec.ExerciseName, rec.Unit = detail.ID, detail.Name, preferences.Unit
+		rec.Load = training.RoundToPlates(training.FromKg(rec.Load, preferences.Unit), preferences.PlateIncrement)
+		if lastEntry != nil {
+			rec.LastEntryID = &lastEntry.ID
+		}
+		recommendations.Exercises = append(recommendations.Exercises, rec)
+	}
+
+	utils.Logger.Info("Session recommendations retrieved",
+		zap.Int("user_id", userID),
+		zap.Int("workout_section_id", sectionID),
+		zap.Int("week", week),
+		zap.String("rule", ruleName),
+	)
+	utils.WriteStandardResponse(w, http.StatusOK, "Recommendations retrieved successfully", recommendations)
+}
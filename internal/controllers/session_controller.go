@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// GET /user/sessions lists the devices the user is signed in on
+func GetUserSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+	currentSessionID, _ := r.Context().Value(middleware.SessionIDKey).(int)
+
+	sessions, err := database.ListActiveSessions(userID)
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve sessions", http.StatusInternalServerError, err)
+		return
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	utils.Logger.Info("User sessions retrieved", zap.Int("user_id", userID), zap.Int("count", len(sessions)))
+	utils.WriteStandardResponse(w, http.StatusOK, "Sessions retrieved successfully", sessions)
+}
+
+// DELETE /user/sessions/{id} signs one device out
+func RevokeUserSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	sessionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid session ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	if err := database.RevokeSession(userID, sessionID); err != nil {
+		if errors.Is(err, database.ErrSessionNotFound) {
+			utils.HandleError(w, "Session not found", http.StatusNotFound, nil)
+		} else {
+			utils.HandleError(w, "Unable to revoke session", http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	utils.Logger.Info("User session revoked", zap.Int("user_id", userID), zap.Int("session_id", sessionID))
+	utils.WriteStandardResponse(w, http.StatusOK, "Session revoked", nil)
+}
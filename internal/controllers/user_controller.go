@@ -1,40 +1,44 @@
 package controllers
 
 import (
+	"database/sql"
 	"encoding/json"
-	"io"
 	"log"
 	"net/http"
+
+	"github.com/haikali3/gymbara-backend/internal/auth"
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"golang.org/x/oauth2"
 )
 
 func GetUserInfoHandler(w http.ResponseWriter, r *http.Request) {
-	authCookie, err := r.Cookie("access_token")
-	if err != nil {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	accessToken := authCookie.Value
-	client := &http.Client{}
-	req, _ := http.NewRequest("GET", "https://www.googleapis.com/oauth2/v2/userinfo", nil)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	// the cookie holds our own session token, so use the provider token stored at login
+	var providerName, accessToken sql.NullString
+	err := database.DB.QueryRow("SELECT oauth_provider, access_token FROM Users WHERE id = $1", userID).Scan(&providerName, &accessToken)
+	if err != nil || !accessToken.Valid || accessToken.String == "" {
+		log.Printf("Error fetching provider access token for user %d: %v\n", userID, err)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
 
-	resp, err := client.Do(req)
-	if err != nil || resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("Error fetching user details: status %d, response body: %s\n", resp.StatusCode, string(body))
-		http.Error(w, "Failed to fetch user details from Google. Ensure your access token is valid and has the necessary scopes. If this issue persists, verify that your token is not expired.", http.StatusInternalServerError)
+	provider, ok := auth.GetProvider(providerName.String)
+	if !ok {
+		log.Printf("Identity provider %q of user %d is not configured\n", providerName.String, userID)
+		http.Error(w, "Identity provider is not available", http.StatusInternalServerError)
 		return
 	}
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			log.Printf("Error closing response body: %v\n", err)
-		}
-	}()
 
-	var userInfo map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		http.Error(w, "Error decoding user info", http.StatusInternalServerError)
+	userInfo, err := provider.FetchIdentity(r.Context(), &oauth2.Token{AccessToken: accessToken.String})
+	if err != nil {
+		log.Printf("Error fetching user details: %v\n", err)
+		http.Error(w, "Failed to fetch user details from the identity provider. Ensure your access token is valid and has the necessary scopes. If this issue persists, verify that your token is not expired.", http.StatusInternalServerError)
 		return
 	}
 
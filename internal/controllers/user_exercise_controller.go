@@ -1,8 +1,10 @@
 package controllers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -11,12 +13,16 @@ import (
 	"github.com/haikali3/gymbara-backend/internal/database"
 	"github.com/haikali3/gymbara-backend/internal/middleware"
 	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/training"
 	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
 // Case 1: First-time submission (New row inserted)
-// Case 2: Duplicate submission on the same day (Row updated)
+// Case 2: Duplicate submission on the same day (Row updated, restored if it was deleted)
+// Case 3: Submission with an Idempotency-Key (New rows inserted, replays are
+// answered by the Idempotent middleware)
 func SubmitUserExerciseDetails(w http.ResponseWriter, r *http.Request) {
 	var request models.UserExerciseRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -47,6 +53,24 @@ func SubmitUserExerciseDetails(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// loads are stored in kg and returned in the user's unit
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+	unit, msg := requestUnit(request.Unit, preferences)
+	if msg != "" {
+		utils.HandleError(w, msg, http.StatusBadRequest, nil)
+		return
+	}
+
+	// entries keep the prescription of the user's program week
+	week, err := database.GetSectionWeek(userID, request.SectionID)
+	if err != nil {
+		utils.HandleError(w, "Unable to determine program week", http.StatusInternalServerError, err)
+		return
+	}
+
 	// begin db transaction
 	tx, err := database.DB.Begin()
 	if err != nil {
@@ -113,7 +137,7 @@ func SubmitUserExerciseDetails(w http.ResponseWriter, r *http.Request) {
 		queryValues[i] = id
 	}
 
-	query := fmt.Sprintf(`SELECT id FROM Exercises WHERE id IN (%s)`, strings.Join(queryPlaceholders, ","))
+	query := fmt.Sprintf(`SELECT id, measurement_type FROM Exercises WHERE id IN (%s)`, strings.Join(queryPlaceholders, ","))
 	rows, txErr := tx.Query(query, queryValues...)
 	if txErr != nil {
 		utils.HandleError(w, "Failed to validate exercise IDs", http.StatusInternalServerError, txErr)
@@ -125,11 +149,13 @@ func SubmitUserExerciseDetails(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	validExerciseIDs := make(map[int]bool)
+	// how each exercise is measured, missing for exercises that don't exist
+	measurementTypes := make(map[int]string)
 	for rows.Next() {
 		var id int
-		if err := rows.Scan(&id); err == nil {
-			validExerciseIDs[id] = true
+		var measurementType string
+		if err := rows.Scan(&id, &measurementType); err == nil {
+			measurementTypes[id] = measurementType
 		}
 	}
 
@@ -139,23 +165,57 @@ func SubmitUserExerciseDetails(w http.ResponseWriter, r *http.Request) {
 	var values []interface{}
 	var insertedExercises []models.UserExerciseInput
 
-	// use the same time for batch
+	// use the same receipt time for batch, exercises without submitted_at were performed now
 	currentTime := time.Now()
+	idempotencyKey, _ := r.Context().Value(middleware.IdempotencyKeyKey).(string)
 
+	seenExercises := make(map[detailKey]bool, len(request.Exercises))
 	for i, exercise := range request.Exercises {
 		//check if exercise exist in db
-		if !validExerciseIDs[exercise.ExerciseID] {
+		measurementType, ok := measurementTypes[exercise.ExerciseID]
+		if !ok {
 			utils.Logger.Warn("Attempt to insert invalid exercise ID", zap.Int("exercise_id", exercise.ExerciseID))
 			utils.HandleError(w, fmt.Sprintf("Invalid exercise_id: %d doesn't exist", exercise.ExerciseID), http.StatusBadRequest, nil)
 			return
 		}
 
-		if exercise.Reps <= 0 || exercise.Load <= 0 {
-			invalidExercises = append(invalidExercises,
-				fmt.Sprintf("Exercise ID %d: Reps=%d, Load=%.2f",
-					exercise.ExerciseID, exercise.Reps, exercise.Load))
+		performed, tzOffset, msg := performedAt(exercise, currentTime)
+		if msg != "" {
+			utils.HandleError(w, fmt.Sprintf("Exercise ID %d: %s", exercise.ExerciseID, msg), http.StatusBadRequest, nil)
+			return
+		}
+		// the local day the exercise was performed, kept in submitted_at
+		key := detailKey{exerciseID: exercise.ExerciseID, day: performed.Format("2006-01-02")}
+		if seenExercises[key] {
+			utils.HandleError(w, fmt.Sprintf("exercise_id %d is listed more than once for %s, send all its sets in one entry", exercise.ExerciseID, key.day), http.StatusBadRequest, nil)
+			return
+		}
+		seenExercises[key] = true
+
+		if len(exercise.Sets) == 0 {
+			// single reps/load submissions are logged as one working set, only
+			// weighted exercises need a load
+			if exercise.Reps <= 0 || exercise.Load < 0 || (exercise.Load == 0 && measurementType == models.MeasurementWeighted) {
+				invalidExercises = append(invalidExercises,
+					fmt.Sprintf("Exercise ID %d: Reps=%d, Load=%.2f",
+						exercise.ExerciseID, exercise.Reps, exercise.Load))
+				continue
+			}
+			exercise.Sets = []models.UserExerciseSet{{
+				SetIndex: 1,
+				SetType:  models.SetTypeWorking,
+				Reps:     exercise.Reps,
+				Load:     exercise.Load,
+				RPE:      exercise.RPE,
+				RIR:      exercise.RIR,
+			}}
+		}
+		setsToKg(exercise.Sets, unit)
+		if msg := normalizeSets(exercise.Sets, measurementType); msg != "" {
+			invalidExercises = append(invalidExercises, fmt.Sprintf("Exercise ID %d: %s", exercise.ExerciseID, msg))
 			continue
 		}
+		exercise.Reps, exercise.Load = topSet(exercise.Sets, measurementType)
 
 		utils.Logger.Info("Adding exercise to batch",
 			zap.Int("user_workout_id", userWorkoutID),
@@ -164,15 +224,18 @@ func SubmitUserExerciseDetails(w http.ResponseWriter, r *http.Request) {
 			zap.Float64("load", exercise.Load),
 		)
 		// use placeholders batch insert with timestamp
-		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d)", i*5+1, i*5+2, i*5+3, i*5+4, i*5+5))
-		values = append(values, userWorkoutID, exercise.ExerciseID, exercise.Reps, exercise.Load, currentTime)
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, NULLIF($%d, ''))",
+			i*10+1, i*10+2, i*10+3, i*10+4, i*10+5, i*10+6, i*10+7, i*10+8, i*10+9, i*10+10))
+		values = append(values, userWorkoutID, userID, exercise.ExerciseID, exercise.Reps, exercise.Load,
+			key.day, performed, tzOffset, currentTime, idempotencyKey)
 
 		//return value for response
 		insertedExercises = append(insertedExercises, models.UserExerciseInput{
 			ExerciseID:  exercise.ExerciseID,
 			Reps:        exercise.Reps,
 			Load:        exercise.Load,
-			SubmittedAt: currentTime,
+			Sets:        exercise.Sets,
+			SubmittedAt: performed,
 		})
 	}
 
@@ -189,19 +252,27 @@ func SubmitUserExerciseDetails(w http.ResponseWriter, r *http.Request) {
 	utils.Logger.Info("Executing batch insert for user exercises", zap.Int("exercise_count", len(request.Exercises)))
 
 	// batch insert
+	newRecords := []models.PersonalRecord{}
 	if len(placeholders) > 0 {
 		query := fmt.Sprintf(`
-		INSERT INTO UserExercisesDetails (user_workout_id, exercise_id, custom_reps, custom_load, submitted_at)
+		INSERT INTO UserExercisesDetails (user_workout_id, user_id, exercise_id, custom_reps, custom_load,
+			submitted_at, performed_at, performed_tz_offset, received_at, idempotency_key)
 		VALUES %s
-		ON CONFLICT ON CONSTRAINT unique_user_exercise_submission
+		ON CONFLICT (user_workout_id, exercise_id, submitted_at) WHERE session_id IS NULL AND idempotency_key IS NULL
 		DO UPDATE
 		SET custom_reps = EXCLUDED.custom_reps, 
 				custom_load = EXCLUDED.custom_load,
-				submitted_at = EXCLUDED.submitted_at
+				submitted_at = EXCLUDED.submitted_at,
+				performed_at = EXCLUDED.performed_at,
+				performed_tz_offset = EXCLUDED.performed_tz_offset,
+				received_at = EXCLUDED.received_at,
+				deleted_at = NULL
+		RETURNING id, exercise_id, submitted_at
 		`, strings.Join(placeholders, ", "))
 
-		_, txErr = tx.Exec(query, values...)
-		if txErr != nil {
+		detailIDs, err := scanDetailIDs(tx, query, values)
+		if err != nil {
+			txErr = err
 			exerciseID := 0
 			if len(insertedExercises) > 0 {
 				exerciseID = insertedExercises[len(insertedExercises)-1].ExerciseID
@@ -216,25 +287,262 @@ func SubmitUserExerciseDetails(w http.ResponseWriter, r *http.Request) {
 		}
 
 		utils.Logger.Debug("Batch insert query generated", zap.String("query", query))
+
+		// a resubmission on the same day replaces the sets logged before
+		if txErr = replaceExerciseSets(tx, detailIDs, insertedExercises); txErr != nil {
+			utils.HandleError(w, "Failed to insert user exercise sets", http.StatusInternalServerError, txErr)
+			return
+		}
+
+		ids := make([]int, 0, len(detailIDs))
+		for _, id := range detailIDs {
+			ids = append(ids, id)
+		}
+		if txErr = database.SnapshotPrescriptions(tx, ids, week); txErr != nil {
+			utils.HandleError(w, "Failed to store prescriptions", http.StatusInternalServerError, txErr)
+			return
+		}
+
+		// the UserWorkouts upsert above locks the section, so records are
+		// compared against a stable history
+		var candidates []models.PersonalRecord
+		for _, exercise := range insertedExercises {
+			detailID := detailIDs[detailKey{exerciseID: exercise.ExerciseID, day: exercise.SubmittedAt.Format("2006-01-02")}]
+			for _, candidate := range training.RecordCandidates(measurementTypes[exercise.ExerciseID], exercise.Sets) {
+				candidate.ExerciseID, candidate.EntryID, candidate.AchievedAt = exercise.ExerciseID, detailID, exercise.SubmittedAt
+				candidates = append(candidates, candidate)
+			}
+		}
+		if newRecords, txErr = database.RecordPersonalRecords(tx, userID, ids, candidates); txErr != nil {
+			utils.HandleError(w, "Failed to store personal records", http.StatusInternalServerError, txErr)
+			return
+		}
 	}
 
 	// ✅ Invalidate cache when exercises are updated
-	sectionIDStr := strconv.Itoa(request.SectionID)
 	if request.SectionID > 0 {
-		workoutCache.Delete("exercise_list_" + sectionIDStr)
-		workoutCache.Delete("exercise_details_" + sectionIDStr)
+		sectionIDStr := strconv.Itoa(request.SectionID)
+		workoutCache.DeletePrefix("exercise_list_" + sectionIDStr + "_")
+		workoutCache.DeletePrefix("exercise_details_" + sectionIDStr + "_")
+		utils.Logger.Info("Cache invalidated", zap.String("cachePrefix", "exercise_list_"+sectionIDStr+"_"))
+		utils.Logger.Info("Cache invalidated", zap.String("cachePrefix", "exercise_details_"+sectionIDStr+"_"))
 	}
 
-	utils.Logger.Info("Cache invalidated for updated workout sections and exercises")
-	utils.Logger.Info("Cache invalidated", zap.String("cacheKey", "workout_sections"))
-	utils.Logger.Info("Cache invalidated", zap.String("cacheKey", "exercise_list_"+sectionIDStr))
-	utils.Logger.Info("Cache invalidated", zap.String("cacheKey", "exercise_details_"+sectionIDStr))
-
-	// return success response
+	// return success response in the user's unit
+	for i := range insertedExercises {
+		exercise := &insertedExercises[i]
+		exercise.Load = training.FromKg(exercise.Load, preferences.Unit)
+		exercise.Sets = append([]models.UserExerciseSet(nil), exercise.Sets...)
+		setsInUnit(exercise.Sets, preferences.Unit)
+	}
+	recordsInUnit(newRecords, preferences.Unit)
 	utils.WriteStandardResponse(w, http.StatusCreated, "User exercise details submitted successfully", map[string]interface{}{
 		"user_workout_id":    userWorkoutID,
+		"unit":               preferences.Unit,
 		"inserted_exercises": insertedExercises,
+		"new_records":        newRecords,
 	})
 
 	utils.Logger.Info("User exercise details submitted successfully", zap.Int("user_workout_id", userWorkoutID))
 }
+
+// how far a client submitted_at may be behind or ahead of the server clock
+const (
+	maxBackdate  = 90 * 24 * time.Hour
+	maxClockSkew = 5 * time.Minute
+	// the furthest a local date runs ahead of UTC (UTC+14)
+	maxDateLead = 14 * time.Hour
+)
+
+// resolves when an exercise was performed and the client's UTC offset in
+// minutes, if it sent one. Without submitted_at it was performed now.
+// Returns a message for the client when submitted_at is out of range.
+func performedAt(exercise models.UserExerciseInput, now time.Time) (time.Time, *int, string) {
+	submitted := exercise.SubmittedAt
+	if submitted.IsZero() {
+		return now, nil, ""
+	}
+
+	earliest := now.Add(-maxBackdate)
+	if exercise.SubmittedAtDateOnly {
+		// midnight UTC of the client's day
+		if submitted.After(now.Add(maxDateLead)) {
+			return time.Time{}, nil, "submitted_at is in the future"
+		}
+		if submitted.Before(earliest.Truncate(24 * time.Hour)) {
+			return time.Time{}, nil, "submitted_at is more than 90 days ago"
+		}
+		return submitted, nil, ""
+	}
+
+	return performedTimestamp("submitted_at", submitted, now)
+}
+
+// checks a client timestamp of when something was performed against the
+// server clock and returns its UTC offset in minutes. Returns a message for
+// the client, naming field, when it is out of range.
+func performedTimestamp(field string, performed, now time.Time) (time.Time, *int, string) {
+	if performed.After(now.Add(maxClockSkew)) {
+		return time.Time{}, nil, field + " is in the future"
+	}
+	if performed.Before(now.Add(-maxBackdate)) {
+		return time.Time{}, nil, field + " is more than 90 days ago"
+	}
+	_, offsetSeconds := performed.Zone()
+	offsetMinutes := offsetSeconds / 60
+	return performed, &offsetMinutes, ""
+}
+
+// upper bound of sets logged per exercise
+const maxSetsPerExercise = 30
+
+// the measurement types an exercise can declare
+var measurementTypes = map[string]bool{
+	models.MeasurementWeighted:        true,
+	models.MeasurementBodyweight:      true,
+	models.MeasurementBodyweightAdded: true,
+	models.MeasurementAssisted:        true,
+	models.MeasurementDuration:        true,
+	models.MeasurementDistance:        true,
+}
+
+// fills in default set indexes and types and checks the sets are plausible
+// for the exercise's measurement type. Returns a message for the client when
+// they are not.
+func normalizeSets(sets []models.UserExerciseSet, measurementType string) string {
+	if len(sets) > maxSetsPerExercise {
+		return fmt.Sprintf("at most %d sets are allowed", maxSetsPerExercise)
+	}
+
+	// timed and distance sets don't need reps
+	minReps := 1
+	if measurementType == models.MeasurementDuration || measurementType == models.MeasurementDistance {
+		minReps = 0
+	}
+
+	seenIndexes := make(map[int]bool, len(sets))
+	for i := range sets {
+		set := &sets[i]
+		if set.SetIndex == 0 {
+			set.SetIndex = i + 1
+		}
+		if set.SetType == "" {
+			set.SetType = models.SetTypeWorking
+		}
+		set.EffectiveLoad = nil
+
+		switch {
+		case set.SetIndex < 1 || seenIndexes[set.SetIndex]:
+			return fmt.Sprintf("set_index %d must be unique and at least 1", set.SetIndex)
+		case set.SetType != models.SetTypeWarmup && set.SetType != models.SetTypeWorking && set.SetType != models.SetTypeDrop:
+			return fmt.Sprintf("set_type must be warmup, working or drop, got %q", set.SetType)
+		case set.Reps < minReps || set.Reps > 1000:
+			return fmt.Sprintf("set %d: reps must be between %d and 1000", set.SetIndex, minReps)
+		case set.Load < 0 || set.Load > 2000:
+			return fmt.Sprintf("set %d: load must be between 0 and 2000", set.SetIndex)
+		case measurementType == models.MeasurementBodyweight && set.Load != 0:
+			return fmt.Sprintf("set %d: load must be 0 for a bodyweight exercise", set.SetIndex)
+		case measurementType == models.MeasurementDuration && set.DurationSeconds == nil:
+			return fmt.Sprintf("set %d: duration_seconds is required", set.SetIndex)
+		case measurementType == models.MeasurementDistance && set.DistanceMeters == nil:
+			return fmt.Sprintf("set %d: distance_meters is required", set.SetIndex)
+		case set.DurationSeconds != nil && (*set.DurationSeconds <= 0 || *set.DurationSeconds > 86400):
+			return fmt.Sprintf("set %d: duration_seconds must be between 1 and 86400", set.SetIndex)
+		case set.DistanceMeters != nil && (*set.DistanceMeters <= 0 || *set.DistanceMeters > 100000):
+			return fmt.Sprintf("set %d: distance_meters must be between 0 and 100000", set.SetIndex)
+		case set.Bodyweight != nil && (*set.Bodyweight <= 0 || *set.Bodyweight > 500):
+			return fmt.Sprintf("set %d: bodyweight must be between 0 and 500", set.SetIndex)
+		case set.RPE != nil && (*set.RPE < 1 || *set.RPE > 10):
+			return fmt.Sprintf("set %d: rpe must be between 1 and 10", set.SetIndex)
+		case set.RIR != nil && (*set.RIR < 0 || *set.RIR > 9):
+			return fmt.Sprintf("set %d: rir must be between 0 and 9", set.SetIndex)
+		case set.RPE != nil && set.RIR != nil && math.Abs(*set.RPE-training.RPEFromRIR(*set.RIR)) > 0.5:
+			return fmt.Sprintf("set %d: rpe and rir disagree, rpe is 10 minus rir", set.SetIndex)
+		case set.RestSeconds != nil && (*set.RestSeconds < 0 || *set.RestSeconds > 3600):
+			return fmt.Sprintf("set %d: rest_seconds must be between 0 and 3600", set.SetIndex)
+		}
+		seenIndexes[set.SetIndex] = true
+
+		// a lifter may log either, both are stored
+		if set.RPE == nil && set.RIR != nil {
+			rpe := training.RPEFromRIR(*set.RIR)
+			set.RPE = &rpe
+		}
+		if set.RIR == nil && set.RPE != nil {
+			rir := training.RIRFromRPE(*set.RPE)
+			set.RIR = &rir
+		}
+	}
+	return ""
+}
+
+// the best working set, or the best set when none is a working set, see
+// training.TopSet. Kept on UserExercisesDetails as custom_reps and custom_load.
+func topSet(sets []models.UserExerciseSet, measurementType string) (int, float64) {
+	top, _ := training.TopSet(measurementType, sets)
+	return top.Reps, top.Load
+}
+
+// identifies a loose UserExercisesDetails row of a submission, one per exercise per day
+type detailKey struct {
+	exerciseID int
+	day        string
+}
+
+// runs the UserExercisesDetails insert and maps exercises to the row IDs it returned
+func scanDetailIDs(tx *sql.Tx, query string, values []interface{}) (map[detailKey]int, error) {
+	rows, err := tx.Query(query, values...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	detailIDs := make(map[detailKey]int)
+	for rows.Next() {
+		var detailID, exerciseID int
+		var day time.Time
+		if err := rows.Scan(&detailID, &exerciseID, &day); err != nil {
+			return nil, err
+		}
+		detailIDs[detailKey{exerciseID: exerciseID, day: day.Format("2006-01-02")}] = detailID
+	}
+	return detailIDs, rows.Err()
+}
+
+// replaces the sets of the inserted UserExercisesDetails rows
+func replaceExerciseSets(tx *sql.Tx, detailIDs map[detailKey]int, exercises []models.UserExerciseInput) error {
+	ids := make([]int, 0, len(detailIDs))
+	for _, id := range detailIDs {
+		ids = append(ids, id)
+	}
+	if _, err := tx.Exec(`DELETE FROM UserExerciseSets WHERE user_exercise_detail_id = ANY($1)`, pq.Array(ids)); err != nil {
+		return err
+	}
+
+	var placeholders []string
+	var values []interface{}
+	for _, exercise := range exercises {
+		detailID := detailIDs[detailKey{exerciseID: exercise.ExerciseID, day: exercise.SubmittedAt.Format("2006-01-02")}]
+		for _, set := range exercise.Sets {
+			n := len(values)
+			placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, COALESCE(NULLIF($%d, ''), 'kg'))",
+				n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12))
+			values = append(values, detailID, set.SetIndex, set.SetType, set.Reps, set.Load, set.RPE, set.RIR, set.RestSeconds,
+				set.DurationSeconds, set.DistanceMeters, set.Bodyweight, set.LoadUnit)
+		}
+	}
+	if len(placeholders) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO UserExerciseSets (user_exercise_detail_id, set_index, set_type, reps, load, rpe, rir, rest_seconds,
+			duration_seconds, distance_meters, bodyweight, load_unit)
+		VALUES %s
+	`, strings.Join(placeholders, ", ")), values...)
+	return err
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+)
+
+func TestNormalizeSets(t *testing.T) {
+	sets := []models.UserExerciseSet{
+		{SetType: models.SetTypeWarmup, Reps: 10, Load: 40},
+		{Reps: 8, Load: 80},
+	}
+	if msg := normalizeSets(sets, models.MeasurementWeighted); msg != "" {
+		t.Fatalf("normalizeSets() = %q, want no error", msg)
+	}
+	if sets[0].SetIndex != 1 || sets[1].SetIndex != 2 || sets[1].SetType != models.SetTypeWorking {
+		t.Fatalf("defaults not filled in: %+v", sets)
+	}
+
+	rir, rpe8 := 2.0, 8.0
+	effort := []models.UserExerciseSet{{Reps: 5, Load: 50, RIR: &rir}, {Reps: 5, Load: 50, RPE: &rpe8}}
+	if msg := normalizeSets(effort, models.MeasurementWeighted); msg != "" {
+		t.Fatalf("normalizeSets() = %q, want no error", msg)
+	}
+	if effort[0].RPE == nil || *effort[0].RPE != 8 || effort[1].RIR == nil || *effort[1].RIR != 2 {
+		t.Fatalf("rpe and rir not derived: %+v", effort)
+	}
+
+	rpe := 11.0
+	invalid := map[string][]models.UserExerciseSet{
+		"rpe and rir disagree": {{Reps: 5, Load: 50, RPE: &rpe8, RIR: &rpe8}},
+		"duplicate index":      {{SetIndex: 1, Reps: 5, Load: 50}, {SetIndex: 1, Reps: 5, Load: 50}},
+		"unknown type":         {{SetType: "amrap", Reps: 5, Load: 50}},
+		"no reps":              {{Reps: 0, Load: 50}},
+		"negative load":        {{Reps: 5, Load: -1}},
+		"rpe above 10":         {{Reps: 5, Load: 50, RPE: &rpe}},
+	}
+	for name, sets := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if msg := normalizeSets(sets, models.MeasurementWeighted); msg == "" {
+				t.Fatal("expected sets to be rejected")
+			}
+		})
+	}
+}
+
+func TestTopSet(t *testing.T) {
+	sets := []models.UserExerciseSet{
+		{SetType: models.SetTypeWarmup, Reps: 5, Load: 120},
+		{SetType: models.SetTypeWorking, Reps: 6, Load: 100},
+		{SetType: models.SetTypeWorking, Reps: 8, Load: 100},
+		{SetType: models.SetTypeDrop, Reps: 12, Load: 70},
+	}
+	if reps, load := topSet(sets, models.MeasurementWeighted); reps != 8 || load != 100 {
+		t.Fatalf("topSet() = (%d, %v), want (8, 100)", reps, load)
+	}
+
+	warmupsOnly := []models.UserExerciseSet{{SetType: models.SetTypeWarmup, Reps: 10, Load: 40}}
+	if reps, load := topSet(warmupsOnly, models.MeasurementWeighted); reps != 10 || load != 40 {
+		t.Fatalf("topSet() = (%d, %v), want (10, 40)", reps, load)
+	}
+}
+
+func TestPerformedAt(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	kualaLumpur := time.FixedZone("MYT", 8*60*60)
+
+	var input models.UserExerciseInput
+	if err := json.Unmarshal([]byte(`{"exercise_id": 1, "submitted_at": "2026-10-17T19:30:00+08:00"}`), &input); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	performed, offset, msg := performedAt(input, now)
+	if msg != "" || offset == nil || *offset != 480 || !performed.Equal(time.Date(2026, 10, 17, 11, 30, 0, 0, time.UTC)) {
+		t.Fatalf("performedAt() = (%v, %v, %q)", performed, offset, msg)
+	}
+
+	if err := json.Unmarshal([]byte(`{"exercise_id": 1, "submitted_at": "2026-10-15"}`), &input); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !input.SubmittedAtDateOnly {
+		t.Fatal("expected a date-only submitted_at")
+	}
+	if performed, offset, msg := performedAt(input, now); msg != "" || offset != nil || performed.Format("2006-01-02") != "2026-10-15" {
+		t.Fatalf("performedAt() = (%v, %v, %q)", performed, offset, msg)
+	}
+
+	if err := json.Unmarshal([]byte(`{"exercise_id": 1, "submitted_at": "17/10/2026"}`), &input); err == nil {
+		t.Fatal("expected an unparseable submitted_at to be rejected")
+	}
+
+	outOfRange := map[string]models.UserExerciseInput{
+		"in the future":       {SubmittedAt: now.Add(time.Hour).In(kualaLumpur)},
+		"more than 90 days":   {SubmittedAt: now.AddDate(0, 0, -91)},
+		"date after tomorrow": {SubmittedAt: time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), SubmittedAtDateOnly: true},
+	}
+	for name, input := range outOfRange {
+		t.Run(name, func(t *testing.T) {
+			if _, _, msg := performedAt(input, now); msg == "" {
+				t.Fatal("expected submitted_at to be rejected")
+			}
+		})
+	}
+
+	if performed, _, msg := performedAt(models.UserExerciseInput{}, now); msg != "" || !performed.Equal(now) {
+		t.Fatalf("performedAt() without submitted_at = (%v, %q), want now", performed, msg)
+	}
+}
+
+func TestSessionPerformedAt(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+	// synced offline, performed early on the 17th in Kuala Lumpur, still the
+	// 16th in UTC
+	var req models.AddSetsRequest
+	if err := json.Unmarshal([]byte(`{"exercise_id": 1, "performed_at": "2026-10-17T01:30:00+08:00"}`), &req); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	performed, offset, msg := performedTimestamp("performed_at", *req.PerformedAt, now)
+	if msg != "" || offset == nil || *offset != 480 || performed.Format("2006-01-02") != "2026-10-17" {
+		t.Fatalf("performedTimestamp() = (%v, %v, %q)", performed, offset, msg)
+	}
+
+	if _, _, msg := performedTimestamp("performed_at", now.Add(time.Hour), now); msg != "performed_at is in the future" {
+		t.Errorf("performedTimestamp() in the future = %q", msg)
+	}
+}
@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/training"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const maxPlateIncrement = 50
+
+// GET /user/preferences returns the user's unit, plate increment and
+// progression rule
+// PATCH /user/preferences updates them
+func UserPreferences(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		preferences, ok := requestPreferences(w, r)
+		if !ok {
+			return
+		}
+		utils.WriteStandardResponse(w, http.StatusOK, "Preferences retrieved successfully", preferences)
+	case http.MethodPatch:
+		updateUserPreferences(w, r)
+	default:
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func updateUserPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	var req models.UpdatePreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if req.Unit != nil && *req.Unit != models.UnitKg && *req.Unit != models.UnitLb {
+		utils.HandleError(w, "unit must be kg or lb", http.StatusBadRequest, nil)
+		return
+	}
+	if req.PlateIncrement != nil && (*req.PlateIncrement <= 0 || *req.PlateIncrement > maxPlateIncrement) {
+		utils.HandleError(w, "plate_increment must be greater than 0 and at most 50", http.StatusBadRequest, nil)
+		return
+	}
+	if req.ProgressionRule != nil {
+		if _, ok := training.ProgressionRuleFor(*req.ProgressionRule); !ok {
+			utils.HandleError(w, "progression_rule must be double_progression or rpe", http.StatusBadRequest, nil)
+			return
+		}
+	}
+
+	preferences, err := database.UpdateUserPreferences(userID, req)
+	if errors.Is(err, database.ErrUserNotFound) {
+		utils.HandleError(w, err.Error(), http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		utils.HandleError(w, "Unable to update preferences", http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.Logger.Info("User preferences updated",
+		zap.Int("user_id", userID),
+		zap.String("unit", preferences.Unit),
+		zap.Float64("plate_increment", preferences.PlateIncrement),
+		zap.String("progression_rule", preferences.ProgressionRule),
+	)
+	utils.WriteStandardResponse(w, http.StatusOK, "Preferences updated", preferences)
+}
+
+// loads the preferences of the user in the request context. It writes the
+// error response when it returns false.
+func requestPreferences(w http.ResponseWriter, r *http.Request) (models.UserPreferences, bool) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return models.UserPreferences{}, false
+	}
+
+	preferences, err := userPreferences(userID)
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve preferences", http.StatusInternalServerError, err)
+		return models.UserPreferences{}, false
+	}
+	return preferences, true
+}
+
+// the unit the loads of a request are in, the user's preferred unit when the
+// request doesn't say. Returns a message for the client when it is unknown.
+func requestUnit(unit string, preferences models.UserPreferences) (string, string) {
+	switch unit {
+	case "":
+		return preferences.Unit, ""
+	case models.UnitKg, models.UnitLb:
+		return unit, ""
+	default:
+		return "", "unit must be kg or lb"
+	}
+}
+
+// converts the loads and bodyweights of sets entered in unit to kg
+func setsToKg(sets []models.UserExerciseSet, unit string) {
+	for i := range sets {
+		set := &sets[i]
+		set.Load = training.ToKg(set.Load, unit)
+		if set.Bodyweight != nil {
+			bodyweight := training.ToKg(*set.Bodyweight, unit)
+			set.Bodyweight = &bodyweight
+		}
+		set.LoadUnit = unit
+	}
+}
+
+// converts the loads and bodyweights of sets from kg to unit
+func setsInUnit(sets []models.UserExerciseSet, unit string) {
+	convert := func(kg *float64) *float64 {
+		if kg == nil {
+			return nil
+		}
+		load := training.FromKg(*kg, unit)
+		return &load
+	}
+	for i := range sets {
+		set := &sets[i]
+		set.Load = training.FromKg(set.Load, unit)
+		set.Bodyweight = convert(set.Bodyweight)
+		set.EffectiveLoad = convert(set.EffectiveLoad)
+	}
+}
+
+// converts the loads of an entry from kg to unit
+func entryInUnit(entry *models.UserProgressResponse, unit string) {
+	entry.Unit = unit
+	entry.CustomLoad = training.FromKg(entry.CustomLoad, unit)
+	if entry.E1RM != nil {
+		e1rm := training.FromKg(*entry.E1RM, unit)
+		entry.E1RM = &e1rm
+	}
+	setsInUnit(entry.Sets, unit)
+}
+
+func entriesInUnit(entries []models.UserProgressResponse, unit string) {
+	for i := range entries {
+		entryInUnit(&entries[i], unit)
+	}
+}
+
+// converts record values from kg to unit, volumes scale the same way
+func recordsInUnit(records []models.PersonalRecord, unit string) {
+	for i := range records {
+		records[i].Value = training.FromKg(records[i].Value, unit)
+		if records[i].PreviousValue != nil {
+			previous := training.FromKg(*records[i].PreviousValue, unit)
+			records[i].PreviousValue = &previous
+		}
+	}
+}
+
+// converts prescribed loads from kg to the user's unit, rounded to their
+// plate increment. Returns a copy, details may be cached.
+func prescriptionsInUnit(details []models.ExerciseDetails, preferences models.UserPreferences) []models.ExerciseDetails {
+	converted := make([]models.ExerciseDetails, len(details))
+	for i, detail := range details {
+		detail.Load = training.RoundToPlates(training.FromKg(detail.Load, preferences.Unit), preferences.PlateIncrement)
+		converted[i] = detail
+	}
+	return converted
+}
+
+// swapped out in tests, they run without a database
+var userPreferences = database.GetUserPreferences
@@ -1,13 +1,18 @@
 package controllers
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/haikali3/gymbara-backend/internal/database"
 	"github.com/haikali3/gymbara-backend/internal/middleware"
 	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/training"
 	"github.com/haikali3/gymbara-backend/pkg/utils"
 	"go.uber.org/zap"
 )
@@ -55,48 +60,431 @@ func GetUserProgress(w http.ResponseWriter, r *http.Request) {
 		limit = parsedLimit
 	}
 
-	rows, err := database.StmtGetUserProgress.Query(userID)
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+
+	// ?group_by=session groups entries by workout session, limit counts sessions
+	switch r.URL.Query().Get("group_by") {
+	case "":
+	case "session":
+		listProgressSessions(w, r, userID, limit, preferences)
+		return
+	default:
+		utils.HandleError(w, "Invalid group_by parameter: must be session", http.StatusBadRequest, nil)
+		return
+	}
+
+	filter, msg := progressFilter(r.URL.Query())
+	if msg != "" {
+		utils.HandleError(w, msg, http.StatusBadRequest, nil)
+		return
+	}
+	filter.Limit = limit
+
+	entries, more, err := database.ListProgress(userID, filter)
 	if err != nil {
 		utils.HandleError(w, "Unable to retrieve user progress", http.StatusInternalServerError, err)
 		return
 	}
-	defer func() {
-		if err := rows.Close(); err != nil {
-			utils.Logger.Error("Failed to close rows", zap.Error(err))
+	entriesInUnit(entries, preferences.Unit)
+
+	page := models.ProgressPage{Items: entries}
+	if more {
+		last := entries[len(entries)-1]
+		cursor := encodeProgressCursor(models.ProgressCursor{
+			PerformedAt: *last.PerformedAt,
+			ID:          last.ID,
+			Descending:  filter.Descending,
+		})
+		page.NextCursor = &cursor
+	}
+
+	utils.Logger.Info("User progress retrieved successfully",
+		zap.Int("user_id", userID),
+		zap.Int("records", len(entries)),
+		zap.Int("limit", limit),
+		zap.Bool("more", more))
+	utils.WriteStandardResponse(w, http.StatusOK, "User progress retrieved successfully", page)
+}
+
+// lists progress grouped by session, newest first unless ?order=asc, paged
+// with the cursor of the last group like the flat list
+func listProgressSessions(w http.ResponseWriter, r *http.Request, userID, limit int, preferences models.UserPreferences) {
+	query := r.URL.Query()
+	for _, param := range sessionUnsupportedParams {
+		if query.Has(param) {
+			utils.HandleError(w, "Invalid "+param+" parameter: not supported with group_by=session", http.StatusBadRequest, nil)
+			return
 		}
-	}()
+	}
+	filter, msg := progressFilter(query)
+	if msg != "" {
+		utils.HandleError(w, msg, http.StatusBadRequest, nil)
+		return
+	}
+	filter.Limit = limit
 
-	var progressData []models.UserProgressResponse
-	count := 0
-	for rows.Next() {
-		if count >= limit {
-			break
+	sessions, more, err := listProgressBySession(userID, filter)
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve user progress", http.StatusInternalServerError, err)
+		return
+	}
+	for i := range sessions {
+		entriesInUnit(sessions[i].Entries, preferences.Unit)
+	}
+
+	page := models.ProgressSessionPage{Items: sessions}
+	if more {
+		cursor := encodeProgressCursor(sessions[len(sessions)-1].Key)
+		page.NextCursor = &cursor
+	}
+
+	utils.Logger.Info("User progress by session retrieved successfully",
+		zap.Int("user_id", userID),
+		zap.Int("sessions", len(sessions)),
+		zap.Int("limit", limit),
+		zap.Bool("more", more))
+	utils.WriteStandardResponse(w, http.StatusOK, "User progress retrieved successfully", page)
+}
+
+// swapped out in tests, they run without a database
+var listProgressBySession = database.ListProgressBySession
+
+// filters of the flat progress list that don't apply to session groups
+var sessionUnsupportedParams = []string{"exercise_id", "section_id", "from", "to"}
+
+// reads the filters, order and cursor of GET /user/progress. It returns a
+// message for the response when a parameter is invalid.
+func progressFilter(query url.Values) (models.ProgressFilter, string) {
+	var filter models.ProgressFilter
+
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"exercise_id", &filter.ExerciseID}, {"section_id", &filter.SectionID}} {
+		if query.Get(param.name) == "" {
+			continue
+		}
+		id, err := strconv.Atoi(query.Get(param.name))
+		if err != nil || id <= 0 {
+			return filter, "Invalid " + param.name + " parameter: must be a positive number"
 		}
+		*param.value = id
+	}
 
-		var exerciseID, customReps int
-		var exerciseName string
-		var customLoad float64
-		var submittedAt time.Time
+	from, to, msg := dateRange(query)
+	if msg != "" {
+		return filter, msg
+	}
+	filter.From, filter.To = from, to
 
-		if err := rows.Scan(&exerciseID, &exerciseName, &customLoad, &customReps, &submittedAt); err != nil {
-			utils.HandleError(w, "Error scanning user progress data", http.StatusInternalServerError, err)
+	// newest first unless asked otherwise
+	switch query.Get("order") {
+	case "", "desc":
+		filter.Descending = true
+	case "asc":
+	default:
+		return filter, "Invalid order parameter: must be asc or desc"
+	}
+
+	if query.Get("cursor") != "" {
+		cursor, err := decodeProgressCursor(query.Get("cursor"))
+		if err != nil {
+			return filter, "Invalid cursor parameter"
+		}
+		if cursor.Descending != filter.Descending {
+			return filter, "Invalid cursor parameter: it belongs to the other order"
+		}
+		filter.After = &cursor
+	}
+	return filter, ""
+}
+
+// cursors are opaque to clients, base64url of the JSON sort key
+func encodeProgressCursor(cursor models.ProgressCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeProgressCursor(s string) (models.ProgressCursor, error) {
+	var cursor models.ProgressCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, err
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, err
+	}
+	if cursor.ID <= 0 || cursor.PerformedAt.IsZero() {
+		return cursor, errors.New("incomplete cursor")
+	}
+	return cursor, nil
+}
+
+// reads the inclusive from and to days of query, nil when not given. It
+// returns a message for the response when they are invalid.
+func dateRange(query url.Values) (from, to *time.Time, msg string) {
+	for _, param := range []struct {
+		name  string
+		value **time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if query.Get(param.name) == "" {
+			continue
+		}
+		date, err := time.Parse("2006-01-02", query.Get(param.name))
+		if err != nil {
+			return nil, nil, "Invalid " + param.name + " parameter: must be a date (YYYY-MM-DD)"
+		}
+		*param.value = &date
+	}
+	if from != nil && to != nil && to.Before(*from) {
+		return nil, nil, "Invalid date range: from must not be after to"
+	}
+	return from, to, ""
+}
+
+const (
+	defaultTrendWindow = 4
+	maxTrendWindow     = 52
+)
+
+// GET /user/progress/exercises/{id}/trend returns the estimated one rep max
+// of an exercise over time, one point per day, week or month
+func GetExerciseTrend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+	exerciseID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid exercise ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	query := r.URL.Query()
+	trend := models.StrengthTrend{
+		ExerciseID: exerciseID,
+		Formula:    training.FormulaEpley,
+		Bucket:     training.BucketWeek,
+		Window:     defaultTrendWindow,
+	}
+	if formula := query.Get("formula"); formula != "" {
+		if !training.IsFormula(formula) {
+			utils.HandleError(w, "Invalid formula parameter: must be epley, brzycki or lombardi", http.StatusBadRequest, nil)
+			return
+		}
+		trend.Formula = formula
+	}
+	switch bucket := query.Get("bucket"); bucket {
+	case "":
+	case training.BucketDay, training.BucketWeek, training.BucketMonth:
+		trend.Bucket = bucket
+	default:
+		utils.HandleError(w, "Invalid bucket parameter: must be day, week or month", http.StatusBadRequest, nil)
+		return
+	}
+	if windowStr := query.Get("window"); windowStr != "" {
+		window, err := strconv.Atoi(windowStr)
+		if err != nil || window < 1 || window > maxTrendWindow {
+			utils.HandleError(w, "Invalid window parameter: must be between 1 and 52", http.StatusBadRequest, nil)
 			return
 		}
+		trend.Window = window
+	}
+	from, to, msg := dateRange(query)
+	if msg != "" {
+		utils.HandleError(w, msg, http.StatusBadRequest, nil)
+		return
+	}
 
-		// later find better way just for format time
-		progressData = append(progressData, models.UserProgressResponse{
-			ExerciseID:   exerciseID,
-			ExerciseName: exerciseName,
-			CustomLoad:   customLoad,
-			CustomReps:   customReps,
-			SubmittedAt:  submittedAt.Format("2006-01-02"),
+	measurementType, err := database.GetMeasurementType(exerciseID)
+	if errors.Is(err, database.ErrExerciseNotFound) {
+		utils.HandleError(w, err.Error(), http.StatusNotFound, nil)
+		return
+	}
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve exercise trend", http.StatusInternalServerError, err)
+		return
+	}
+	if measurementType == models.MeasurementDuration || measurementType == models.MeasurementDistance {
+		utils.HandleError(w, "Trends are only available for exercises measured by load", http.StatusBadRequest, nil)
+		return
+	}
+
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+
+	// every session in the range, oldest first
+	entries, _, err := database.ListProgress(userID, models.ProgressFilter{ExerciseID: exerciseID, From: from, To: to})
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve exercise trend", http.StatusInternalServerError, err)
+		return
+	}
+	samples := make([]training.E1RMSample, 0, len(entries))
+	for _, entry := range entries {
+		// submitted_at is the day the lifter trained on
+		date, err := time.Parse("2006-01-02", entry.SubmittedAt)
+		if err != nil {
+			utils.HandleError(w, "Unable to retrieve exercise trend", http.StatusInternalServerError, err)
+			return
+		}
+		samples = append(samples, training.E1RMSample{
+			Date: date,
+			E1RM: training.BestE1RM(trend.Formula, entry.MeasurementType, entry.Sets),
 		})
-		count++
 	}
 
-	utils.Logger.Info("User progress retrieved successfully",
+	trend.Unit = preferences.Unit
+	trend.Points = training.Trend(samples, trend.Bucket, trend.Window)
+	for i := range trend.Points {
+		trend.Points[i].E1RM = training.FromKg(trend.Points[i].E1RM, preferences.Unit)
+		trend.Points[i].MovingAverage = training.FromKg(trend.Points[i].MovingAverage, preferences.Unit)
+	}
+
+	utils.Logger.Info("Exercise trend retrieved successfully",
+		zap.Int("user_id", userID),
+		zap.Int("exercise_id", exerciseID),
+		zap.Int("sessions", len(samples)),
+		zap.Int("points", len(trend.Points)))
+	utils.WriteStandardResponse(w, http.StatusOK, "Exercise trend retrieved successfully", trend)
+}
+
+// GET /user/progress/exercises/{id}/records returns the personal records the
+// user set on an exercise, newest first
+func GetExercisePersonalRecords(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+	exerciseID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid exercise ID format", http.StatusBadRequest, err)
+		return
+	}
+
+	if _, err := database.GetMeasurementType(exerciseID); err != nil {
+		if errors.Is(err, database.ErrExerciseNotFound) {
+			utils.HandleError(w, err.Error(), http.StatusNotFound, nil)
+			return
+		}
+		utils.HandleError(w, "Unable to retrieve personal records", http.StatusInternalServerError, err)
+		return
+	}
+
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+
+	records, err := database.ListPersonalRecords(userID, exerciseID)
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve personal records", http.StatusInternalServerError, err)
+		return
+	}
+	recordsInUnit(records, preferences.Unit)
+
+	utils.WriteStandardResponse(w, http.StatusOK, "Personal records retrieved successfully", models.PersonalRecordHistory{
+		ExerciseID: exerciseID,
+		Unit:       preferences.Unit,
+		Records:    records,
+	})
+}
+
+const maxSummaryWeeks = 12
+
+// GET /user/progress/weekly-summary returns the sets, reps, tonnage and hard
+// sets per muscle of the weeks up to the week holding ?week (today by
+// default), ?weeks of them, oldest first
+func GetWeeklySummary(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	query := r.URL.Query()
+	lastWeek, err := time.Parse("2006-01-02", time.Now().Format("2006-01-02"))
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve weekly summary", http.StatusInternalServerError, err)
+		return
+	}
+	if query.Get("week") != "" {
+		lastWeek, err = time.Parse("2006-01-02", query.Get("week"))
+		if err != nil {
+			utils.HandleError(w, "Invalid week parameter: must be a date (YYYY-MM-DD)", http.StatusBadRequest, nil)
+			return
+		}
+	}
+	weeks := 1
+	if query.Get("weeks") != "" {
+		weeks, err = strconv.Atoi(query.Get("weeks"))
+		if err != nil || weeks < 1 || weeks > maxSummaryWeeks {
+			utils.HandleError(w, "Invalid weeks parameter: must be between 1 and 12", http.StatusBadRequest, nil)
+			return
+		}
+	}
+
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+
+	from := training.BucketStart(lastWeek, training.BucketWeek).AddDate(0, 0, -7*(weeks-1))
+	to := from.AddDate(0, 0, 7*weeks-1)
+	entries, _, err := database.ListProgress(userID, models.ProgressFilter{From: &from, To: &to})
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve weekly summary", http.StatusInternalServerError, err)
+		return
+	}
+
+	seen := map[int]bool{}
+	var exerciseIDs []int
+	for _, entry := range entries {
+		if !seen[entry.ExerciseID] {
+			seen[entry.ExerciseID] = true
+			exerciseIDs = append(exerciseIDs, entry.ExerciseID)
+		}
+	}
+	muscles, err := database.ListExerciseMuscles(exerciseIDs)
+	if err != nil {
+		utils.HandleError(w, "Unable to retrieve weekly summary", http.StatusInternalServerError, err)
+		return
+	}
+
+	summaries := training.WeeklySummaries(entries, muscles, from, weeks)
+	for i := range summaries {
+		summaries[i].Unit = preferences.Unit
+		summaries[i].Total.Tonnage = training.FromKg(summaries[i].Total.Tonnage, preferences.Unit)
+		for j := range summaries[i].Muscles {
+			summaries[i].Muscles[j].Tonnage = training.FromKg(summaries[i].Muscles[j].Tonnage, preferences.Unit)
+		}
+	}
+
+	utils.Logger.Info("Weekly summary retrieved successfully",
 		zap.Int("user_id", userID),
-		zap.Int("records", len(progressData)),
-		zap.Int("limit", limit))
-	utils.WriteStandardResponse(w, http.StatusOK, "User progress retrieved successfully", progressData)
+		zap.String("from", from.Format("2006-01-02")),
+		zap.Int("weeks", weeks),
+		zap.Int("entries", len(entries)))
+	utils.WriteStandardResponse(w, http.StatusOK, "Weekly summary retrieved successfully", summaries)
 }
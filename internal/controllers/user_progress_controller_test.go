@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+func TestProgressCursor(t *testing.T) {
+	want := models.ProgressCursor{PerformedAt: time.Date(2026, 10, 17, 7, 30, 0, 123456000, time.UTC), ID: 42, Descending: true}
+	got, err := decodeProgressCursor(encodeProgressCursor(want))
+	if err != nil {
+		t.Fatalf("decodeProgressCursor() error = %v", err)
+	}
+	if !got.PerformedAt.Equal(want.PerformedAt) || got.ID != want.ID || got.Descending != want.Descending {
+		t.Fatalf("decodeProgressCursor() = %+v, want %+v", got, want)
+	}
+
+	for _, cursor := range []string{"not base64!", "e30", encodeProgressCursor(models.ProgressCursor{ID: 1})} {
+		if _, err := decodeProgressCursor(cursor); err == nil {
+			t.Errorf("decodeProgressCursor(%q) succeeded, want error", cursor)
+		}
+	}
+}
+
+func TestProgressFilter(t *testing.T) {
+	query := url.Values{"exercise_id": {"3"}, "from": {"2026-10-01"}, "to": {"2026-10-17"}, "order": {"asc"}}
+	filter, msg := progressFilter(query)
+	if msg != "" {
+		t.Fatalf("progressFilter() = %q, want no error", msg)
+	}
+	if filter.ExerciseID != 3 || filter.SectionID != 0 || filter.Descending || filter.From == nil || filter.To == nil {
+		t.Fatalf("progressFilter() = %+v", filter)
+	}
+
+	if filter, _ := progressFilter(url.Values{}); !filter.Descending {
+		t.Error("progressFilter() should default to newest first")
+	}
+
+	ascCursor := encodeProgressCursor(models.ProgressCursor{PerformedAt: time.Now(), ID: 1})
+	invalid := map[string]url.Values{
+		"bad exercise":  {"exercise_id": {"abc"}},
+		"bad section":   {"section_id": {"0"}},
+		"bad date":      {"from": {"17-10-2026"}},
+		"reverse range": {"from": {"2026-10-17"}, "to": {"2026-10-01"}},
+		"bad order":     {"order": {"newest"}},
+		"bad cursor":    {"cursor": {"!!"}},
+		"other order":   {"cursor": {ascCursor}},
+	}
+	for name, query := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, msg := progressFilter(query); msg == "" {
+				t.Errorf("progressFilter(%v) succeeded, want error", query)
+			}
+		})
+	}
+}
+
+func TestListProgressSessions(t *testing.T) {
+	utils.Logger = zap.NewNop()
+
+	// five sessions logged a day apart, session i on day i
+	var logged []models.ProgressSession
+	for i := 1; i <= 5; i++ {
+		id := i
+		startedAt := time.Date(2026, 10, i, 7, 0, 0, 0, time.UTC)
+		logged = append(logged, models.ProgressSession{
+			SessionID: &id,
+			StartedAt: &startedAt,
+			Entries:   []models.UserProgressResponse{{ID: 10 * i, Sets: []models.UserExerciseSet{}}},
+			Key:       models.ProgressCursor{PerformedAt: startedAt, ID: 10 * i},
+		})
+	}
+	// pages the sessions the way the query does
+	fake := func(userID int, filter models.ProgressFilter) ([]models.ProgressSession, bool, error) {
+		sessions := slices.Clone(logged)
+		if filter.Descending {
+			slices.Reverse(sessions)
+		}
+		var page []models.ProgressSession
+		for _, session := range sessions {
+			if after := filter.After; after != nil {
+				past := session.Key.PerformedAt.After(after.PerformedAt)
+				if filter.Descending {
+					past = session.Key.PerformedAt.Before(after.PerformedAt)
+				}
+				if !past {
+					continue
+				}
+			}
+			session.Key.Descending = filter.Descending
+			page = append(page, session)
+		}
+		if len(page) > filter.Limit {
+			return page[:filter.Limit], true, nil
+		}
+		return page, false, nil
+	}
+	defer func(original func(int, models.ProgressFilter) ([]models.ProgressSession, bool, error)) {
+		listProgressBySession = original
+	}(listProgressBySession)
+	listProgressBySession = fake
+
+	get := func(query string) (int, []int, *string) {
+		r := httptest.NewRequest(http.MethodGet, "/user/progress?group_by=session&"+query, nil)
+		w := httptest.NewRecorder()
+		listProgressSessions(w, r, 1, 2, models.UserPreferences{Unit: models.UnitKg})
+		var response struct {
+			Data struct {
+				Items []struct {
+					SessionID int `json:"session_id"`
+				} `json:"items"`
+				NextCursor *string `json:"next_cursor"`
+			} `json:"data"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+			t.Fatalf("GET ?%s: decode response: %v", query, err)
+		}
+		var ids []int
+		for _, item := range response.Data.Items {
+			ids = append(ids, item.SessionID)
+		}
+		return w.Code, ids, response.Data.NextCursor
+	}
+
+	// newest first by default, following next_cursor down to the first session
+	var pages [][]int
+	query := ""
+	for {
+		code, ids, next := get(query)
+		if code != http.StatusOK {
+			t.Fatalf("GET ?%s = %d, want 200", query, code)
+		}
+		pages = append(pages, ids)
+		if next == nil {
+			break
+		}
+		query = "cursor=" + *next
+	}
+	if want := [][]int{{5, 4}, {3, 2}, {1}}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("pages = %v, want %v", pages, want)
+	}
+
+	if _, ids, _ := get("order=asc"); !reflect.DeepEqual(ids, []int{1, 2}) {
+		t.Errorf("order=asc = %v, want [1 2]", ids)
+	}
+	if code, _, _ := get("exercise_id=3"); code != http.StatusBadRequest {
+		t.Errorf("exercise_id with group_by=session = %d, want 400", code)
+	}
+}
@@ -5,7 +5,6 @@ package controllers
 import (
 	"fmt"
 	"net/http"
-	"sort"
 	"strings"
 	"time"
 
@@ -92,7 +91,7 @@ func GetWorkoutSectionsWithExercises(w http.ResponseWriter, r *http.Request) {
 		LEFT JOIN Exercises e
 			ON ws.id = e.workout_section_id
 		WHERE ws.id IN (%s)
-		ORDER BY ws.id, e.id;
+		ORDER BY ws.sort_order, ws.id, e.sort_order, e.id;
 `, placeholders)
 
 	stmt, err := database.DB.Prepare(query)
@@ -117,8 +116,9 @@ func GetWorkoutSectionsWithExercises(w http.ResponseWriter, r *http.Request) {
 		}
 	}()
 
-	//map is unordered lol
+	//map is unordered lol, sectionOrder keeps the sort_order of the query
 	sectionsMap := make(map[int]*models.WorkoutSectionWithExercises)
+	var sectionOrder []int
 	for rows.Next() {
 		var sectionID int
 		var sectionName, sectionRoute string
@@ -137,6 +137,7 @@ func GetWorkoutSectionsWithExercises(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if _, exists := sectionsMap[sectionID]; !exists {
+			sectionOrder = append(sectionOrder, sectionID)
 			sectionsMap[sectionID] = &models.WorkoutSectionWithExercises{
 				ID:        sectionID,
 				Name:      sectionName,
@@ -152,14 +153,10 @@ func GetWorkoutSectionsWithExercises(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sections := make([]models.WorkoutSectionWithExercises, 0, len(sectionsMap))
-	for _, section := range sectionsMap {
-		sections = append(sections, *section)
+	for _, sectionID := range sectionOrder {
+		sections = append(sections, *sectionsMap[sectionID])
 	}
 
-	sort.Slice(sections, func(i, j int) bool {
-		return sections[i].ID < sections[j].ID
-	})
-
 	// ✅ Store in cache for 24 hours
 	utils.Logger.Info("Storing workout sections with exercises in cache", zap.String("cacheKey", cacheKey))
 	workoutCache.Set(cacheKey, sections, 3*time.Hour)
@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/internal/middleware"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+const maxSessionNotesLength = 2000
+
+// POST /user/workout-sessions starts a workout session of a section
+func StartWorkoutSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return
+	}
+
+	var req models.StartSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if req.SectionID == 0 {
+		utils.HandleError(w, "section_id is required", http.StatusBadRequest, nil)
+		return
+	}
+	if len(req.Notes) > maxSessionNotesLength {
+		utils.HandleError(w, "notes must be at most 2000 characters", http.StatusBadRequest, nil)
+		return
+	}
+
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := database.StartWorkoutSession(userID, req.SectionID, req.Notes)
+	if err != nil {
+		writeWorkoutSessionError(w, "Unable to start workout session", err)
+		return
+	}
+	entriesInUnit(session.Exercises, preferences.Unit)
+
+	utils.Logger.Info("Workout session started",
+		zap.Int("user_id", userID),
+		zap.Int("session_id", session.ID),
+		zap.Int("section_id", req.SectionID),
+	)
+	utils.WriteStandardResponse(w, http.StatusCreated, "Workout session started", session)
+}
+
+// GET /user/workout-sessions/{id} returns a session with its exercises and sets
+func GetWorkoutSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, sessionID, ok := workoutSessionParams(w, r)
+	if !ok {
+		return
+	}
+
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := database.GetWorkoutSession(userID, sessionID)
+	if err != nil {
+		writeWorkoutSessionError(w, "Unable to retrieve workout session", err)
+		return
+	}
+	entriesInUnit(session.Exercises, preferences.Unit)
+	utils.WriteStandardResponse(w, http.StatusOK, "Workout session retrieved successfully", session)
+}
+
+// POST /user/workout-sessions/{id}/sets logs sets of an exercise in a session
+func AddWorkoutSessionSets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, sessionID, ok := workoutSessionParams(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.AddSetsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if req.ExerciseID == 0 || len(req.Sets) == 0 {
+		utils.HandleError(w, "exercise_id and sets are required", http.StatusBadRequest, nil)
+		return
+	}
+	performed, tzOffset := time.Now(), (*int)(nil)
+	if req.PerformedAt != nil {
+		var msg string
+		performed, tzOffset, msg = performedTimestamp("performed_at", *req.PerformedAt, performed)
+		if msg != "" {
+			utils.HandleError(w, msg, http.StatusBadRequest, nil)
+			return
+		}
+	}
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+	unit, msg := requestUnit(req.Unit, preferences)
+	if msg != "" {
+		utils.HandleError(w, msg, http.StatusBadRequest, nil)
+		return
+	}
+
+	measurementType, err := database.GetMeasurementType(req.ExerciseID)
+	if err != nil {
+		writeWorkoutSessionError(w, "Unable to log sets", err)
+		return
+	}
+	setsToKg(req.Sets, unit)
+	if msg := normalizeSets(req.Sets, measurementType); msg != "" {
+		utils.HandleError(w, msg, http.StatusBadRequest, nil)
+		return
+	}
+
+	entry, records, err := database.AddSessionSets(userID, sessionID, req.ExerciseID, req.Sets, performed, tzOffset)
+	if err != nil {
+		writeWorkoutSessionError(w, "Unable to log sets", err)
+		return
+	}
+	entryInUnit(&entry, preferences.Unit)
+	recordsInUnit(records, preferences.Unit)
+
+	utils.Logger.Info("Workout session sets logged",
+		zap.Int("user_id", userID),
+		zap.Int("session_id", sessionID),
+		zap.Int("exercise_id", req.ExerciseID),
+		zap.Int("sets", len(req.Sets)),
+		zap.Int("new_records", len(records)),
+	)
+	utils.WriteStandardResponse(w, http.StatusCreated, "Sets logged", models.LoggedSets{UserProgressResponse: entry, NewRecords: records})
+}
+
+// POST /user/workout-sessions/{id}/finish finishes a session with an
+// optional session RPE and notes
+func FinishWorkoutSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, sessionID, ok := workoutSessionParams(w, r)
+	if !ok {
+		return
+	}
+
+	// the body is optional
+	var req models.FinishSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+		return
+	}
+	if req.SessionRPE != nil && (*req.SessionRPE < 1 || *req.SessionRPE > 10) {
+		utils.HandleError(w, "session_rpe must be between 1 and 10", http.StatusBadRequest, nil)
+		return
+	}
+	if req.Notes != nil && len(*req.Notes) > maxSessionNotesLength {
+		utils.HandleError(w, "notes must be at most 2000 characters", http.StatusBadRequest, nil)
+		return
+	}
+
+	preferences, ok := requestPreferences(w, r)
+	if !ok {
+		return
+	}
+
+	session, err := database.FinishWorkoutSession(userID, sessionID, req.SessionRPE, req.Notes)
+	if err != nil {
+		writeWorkoutSessionError(w, "Unable to finish workout session", err)
+		return
+	}
+	entriesInUnit(session.Exercises, preferences.Unit)
+
+	utils.Logger.Info("Workout session finished", zap.Int("user_id", userID), zap.Int("session_id", sessionID))
+	utils.WriteStandardResponse(w, http.StatusOK, "Workout session finished", session)
+}
+
+// reads the user ID from the context and the session ID from the path. It
+// writes the error response when it returns false.
+func workoutSessionParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	userID, ok := r.Context().Value(middleware.UserIDKey).(int)
+	if !ok {
+		utils.HandleError(w, "Invalid user ID in context", http.StatusUnauthorized, nil)
+		return 0, 0, false
+	}
+
+	sessionID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.HandleError(w, "Invalid workout session ID format", http.StatusBadRequest, err)
+		return 0, 0, false
+	}
+	return userID, sessionID, true
+}
+
+// maps workout session errors from the database package to responses
+func writeWorkoutSessionError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, database.ErrWorkoutSessionNotFound),
+		errors.Is(err, database.ErrSectionNotFound),
+		errors.Is(err, database.ErrExerciseNotFound):
+		utils.HandleError(w, err.Error(), http.StatusNotFound, nil)
+	case errors.Is(err, database.ErrWorkoutSessionFinished):
+		utils.HandleError(w, err.Error(), http.StatusConflict, nil)
+	default:
+		utils.HandleError(w, msg, http.StatusInternalServerError, err)
+	}
+}
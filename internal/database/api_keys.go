@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// ErrAPIKeyNotFound is returned when the user has no such active API key
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// stores a new API key by its hash and returns it without the secret
+func CreateAPIKey(userID int, name, prefix, keyHash string, scopes []string) (models.APIKey, error) {
+	key := models.APIKey{Name: name, Prefix: prefix, Scopes: scopes}
+	err := DB.QueryRow(`
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at
+	`, userID, name, prefix, keyHash, pq.Array(scopes)).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return models.APIKey{}, fmt.Errorf("failed to create api key: %w", err)
+	}
+	return key, nil
+}
+
+// counts the user's API keys that are not revoked
+func CountActiveAPIKeys(userID int) (int, error) {
+	var count int
+	err := DB.QueryRow(`
+		SELECT COUNT(*) FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID).Scan(&count)
+	return count, err
+}
+
+// lists the user's API keys that are not revoked, newest first
+func ListAPIKeys(userID int) ([]models.APIKey, error) {
+	rows, err := DB.Query(`
+		SELECT id, name, prefix, scopes, created_at, last_used_at
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		var lastUsedAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.CreatedAt, &lastUsedAt); err != nil {
+			return nil, err
+		}
+		if lastUsedAt.Valid {
+			key.LastUsedAt = &lastUsedAt.Time
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// revokes one of the user's API keys, returns ErrAPIKeyNotFound when there is
+// no such active key
+func RevokeAPIKey(userID, keyID int) error {
+	result, err := DB.Exec(`
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, keyID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// looks up an active API key by hash, returns sql.ErrNoRows when there is none
+func GetActiveAPIKey(keyHash string) (keyID, userID int, email string, scopes []string, err error) {
+	err = StmtGetActiveAPIKey.QueryRow(keyHash).Scan(&keyID, &userID, &email, pq.Array(&scopes))
+	return keyID, userID, email, scopes, err
+}
+
+// bumps last_used_at, at most once a minute
+func TouchAPIKey(keyID int) error {
+	_, err := StmtTouchAPIKey.Exec(keyID)
+	return err
+}
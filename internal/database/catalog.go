@@ -0,0 +1,448 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrSectionNotFound      = errors.New("workout section not found")
+	ErrExerciseNotFound     = errors.New("exercise not found")
+	ErrPrescriptionNotFound = errors.New("prescription not found")
+	// ErrCatalogInUse is returned when deleting a catalog entry users have logged workouts against
+	ErrCatalogInUse = errors.New("catalog entry has logged workouts")
+	// ErrInvalidPrescription is returned when a prescription's week range is invalid
+	ErrInvalidPrescription = errors.New("week_start must be at least 1 and not after week_end")
+	// ErrReorderMismatch is returned when a reorder does not list every ID exactly once
+	ErrReorderMismatch = errors.New("ids must list every item exactly once")
+)
+
+// postgres error codes mapped to errors
+const (
+	pqUniqueViolation     = "23505"
+	pqForeignKeyViolation = "23503"
+	pqCheckViolation      = "23514"
+)
+
+func pqErrorCode(err error) string {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return string(pqErr.Code)
+	}
+	return ""
+}
+
+// creates a workout section at the end of the list
+func CreateSection(input models.SectionInput) (models.CatalogSection, error) {
+	var section models.CatalogSection
+	err := DB.QueryRow(`
+		INSERT INTO WorkoutSections (name, route, program_id, sort_order)
+		VALUES ($1, $2, $3, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM WorkoutSections))
+		RETURNING id, name, COALESCE(route, ''), program_id, sort_order
+	`, input.Name, input.Route, input.ProgramID).Scan(&section.ID, &section.Name, &section.Route, &section.ProgramID, &section.SortOrder)
+	if pqErrorCode(err) == pqForeignKeyViolation {
+		return models.CatalogSection{}, ErrProgramNotFound
+	}
+	if err != nil {
+		return models.CatalogSection{}, fmt.Errorf("failed to create workout section: %w", err)
+	}
+	return section, nil
+}
+
+// updates the given fields of a workout section
+func UpdateSection(sectionID int, input models.SectionInput) (models.CatalogSection, error) {
+	var section models.CatalogSection
+	err := DB.QueryRow(`
+		UPDATE WorkoutSections
+		SET name = COALESCE($2, name),
+			route = COALESCE($3, route),
+			program_id = COALESCE($4, program_id)
+		WHERE id = $1
+		RETURNING id, name, COALESCE(route, ''), program_id, sort_order
+	`, sectionID, input.Name, input.Route, input.ProgramID).Scan(&section.ID, &section.Name, &section.Route, &section.ProgramID, &section.SortOrder)
+	if err == sql.ErrNoRows {
+		return models.CatalogSection{}, ErrSectionNotFound
+	}
+	if pqErrorCode(err) == pqForeignKeyViolation {
+		return models.CatalogSection{}, ErrProgramNotFound
+	}
+	if err != nil {
+		return models.CatalogSection{}, fmt.Errorf("failed to update workout section: %w", err)
+	}
+	return section, nil
+}
+
+// deletes a workout section with its exercises, prescriptions and
+// instructions. Sections users have logged workouts for can't be deleted.
+func DeleteSection(sectionID int) error {
+	var id int
+	err := DB.QueryRow(`DELETE FROM WorkoutSections WHERE id = $1 RETURNING id`, sectionID).Scan(&id)
+	return catalogDeleteError(err, ErrSectionNotFound, "workout section")
+}
+
+// sets the order of all workout sections, ids must list every section
+func ReorderSections(ids []int) error {
+	return reorder(`SELECT id FROM WorkoutSections FOR UPDATE`, `
+		UPDATE WorkoutSections ws
+		SET sort_order = o.position
+		FROM unnest($1::int[]) WITH ORDINALITY AS o(id, position)
+		WHERE ws.id = o.id
+	`, ids)
+}
+
+// creates an exercise at the end of its section
+func CreateExercise(input models.ExerciseInput) (models.CatalogExercise, error) {
+	var exercise models.CatalogExercise
+	err := DB.QueryRow(`
+		INSERT INTO Exercises (workout_section_id, name, notes, substitution_1, substitution_2, measurement_type, sort_order)
+		VALUES ($1, $2, $3, $4, $5, COALESCE($6, 'weighted'),
+			(SELECT COALESCE(MAX(sort_order), 0) + 1 FROM Exercises WHERE workout_section_id = $1))
+		RETURNING id, workout_section_id, name, COALESCE(notes, ''),
+			COALESCE(substitution_1, ''), COALESCE(substitution_2, ''), measurement_type, sort_order
+	`, input.WorkoutSectionID, input.Name, input.Notes, input.Substitution1, input.Substitution2, input.MeasurementType).Scan(
+		&exercise.ID, &exercise.WorkoutSectionID, &exercise.Name, &exercise.Notes,
+		&exercise.Substitution1, &exercise.Substitution2, &exercise.MeasurementType, &exercise.SortOrder,
+	)
+	if pqErrorCode(err) == pqForeignKeyViolation {
+		return models.CatalogExercise{}, ErrSectionNotFound
+	}
+	if err != nil {
+		return models.CatalogExercise{}, fmt.Errorf("failed to create exercise: %w", err)
+	}
+	return exercise, nil
+}
+
+// updates the given fields of an exercise and returns it with the section it
+// was in before, so both sections' caches can be invalidated. An exercise moved
+// to another section goes to the end of it.
+func UpdateExercise(exerciseID int, input models.ExerciseInput) (models.CatalogExercise, int, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return models.CatalogExercise{}, 0, err
+	}
+
+	var previousSectionID int
+	err = tx.QueryRow(`
+		SELECT workout_section_id FROM Exercises WHERE id = $1 FOR UPDATE
+	`, exerciseID).Scan(&previousSectionID)
+	if err == sql.ErrNoRows {
+		rollback(tx)
+		return models.CatalogExercise{}, 0, ErrExerciseNotFound
+	}
+	if err != nil {
+		rollback(tx)
+		return models.CatalogExercise{}, 0, err
+	}
+
+	var exercise models.CatalogExercise
+	err = tx.QueryRow(`
+		UPDATE Exercises
+		SET workout_section_id = COALESCE($2, workout_section_id),
+			name = COALESCE($3, name),
+			notes = COALESCE($4, notes),
+			substitution_1 = COALESCE($5, substitution_1),
+			substitution_2 = COALESCE($6, substitution_2),
+			measurement_type = COALESCE($7, measurement_type),
+			sort_order = CASE
+				WHEN $2::int IS NULL OR $2::int = workout_section_id THEN sort_order
+				ELSE (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM Exercises WHERE workout_section_id = $2::int)
+			END
+		WHERE id = $1
+		RETURNING id, workout_section_id, name, COALESCE(notes, ''),
+			COALESCE(substitution_1, ''), COALESCE(substitution_2, ''), measurement_type, sort_order
+	`, exerciseID, input.WorkoutSectionID, input.Name, input.Notes, input.Substitution1, input.Substitution2,
+		input.MeasurementType).Scan(
+		&exercise.ID, &exercise.WorkoutSectionID, &exercise.Name, &exercise.Notes,
+		&exercise.Substitution1, &exercise.Substitution2, &exercise.MeasurementType, &exercise.SortOrder,
+	)
+	if pqErrorCode(err) == pqForeignKeyViolation {
+		rollback(tx)
+		return models.CatalogExercise{}, 0, ErrSectionNotFound
+	}
+	if err != nil {
+		rollback(tx)
+		return models.CatalogExercise{}, 0, fmt.Errorf("failed to update exercise: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return models.CatalogExercise{}, 0, err
+	}
+	return exercise, previousSectionID, nil
+}
+
+// returns how sets of an exercise are measured
+func GetMeasurementType(exerciseID int) (string, error) {
+	var measurementType string
+	err := DB.QueryRow(`SELECT measurement_type FROM Exercises WHERE id = $1`, exerciseID).Scan(&measurementType)
+	if err == sql.ErrNoRows {
+		return "", ErrExerciseNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query measurement type: %w", err)
+	}
+	return measurementType, nil
+}
+
+// deletes an exercise with its prescriptions and instructions and returns
+// the section it was in. Exercises users have logged can't be deleted.
+func DeleteExercise(exerciseID int) (int, error) {
+	var sectionID int
+	err := DB.QueryRow(`
+		DELETE FROM Exercises WHERE id = $1 RETURNING workout_section_id
+	`, exerciseID).Scan(&sectionID)
+	if err := catalogDeleteError(err, ErrExerciseNotFound, "exercise"); err != nil {
+		return 0, err
+	}
+	return sectionID, nil
+}
+
+// sets the order of the exercises in a section, ids must list every one of them
+func ReorderExercises(sectionID int, ids []int) error {
+	var exists bool
+	if err := DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM WorkoutSections WHERE id = $1)`, sectionID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return ErrSectionNotFound
+	}
+
+	return reorder(`SELECT id FROM Exercises WHERE workout_section_id = $1 FOR UPDATE`, `
+		UPDATE Exercises e
+		SET sort_order = o.position
+		FROM unnest($1::int[]) WITH ORDINALITY AS o(id, position)
+		WHERE e.id = o.id AND e.workout_section_id = $2
+	`, ids, sectionID)
+}
+
+// lists the prescriptions of an exercise by week
+func ListPrescriptions(exerciseID int) ([]models.Prescription, error) {
+	var exists bool
+	if err := DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM Exercises WHERE id = $1)`, exerciseID).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrExerciseNotFound
+	}
+
+	rows, err := DB.Query(`
+		SELECT `+prescriptionColumns+`
+		FROM ExerciseDetails ed
+		WHERE ed.exercise_id = $1
+		ORDER BY ed.week_start, ed.id
+	`, exerciseID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	prescriptions := []models.Prescription{}
+	for rows.Next() {
+		prescription, err := scanPrescription(rows)
+		if err != nil {
+			return nil, err
+		}
+		prescriptions = append(prescriptions, prescription)
+	}
+	return prescriptions, rows.Err()
+}
+
+// adds a prescription to an exercise and returns it with the exercise's section
+func CreatePrescription(exerciseID int, input models.PrescriptionInput) (models.Prescription, int, error) {
+	row := DB.QueryRow(`
+		WITH inserted AS (
+			INSERT INTO ExerciseDetails (exercise_id, week_start, week_end, warmup_sets, working_sets, reps, load, rpe, rest_time)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			RETURNING *
+		)
+		SELECT `+prescriptionColumns+`, e.workout_section_id
+		FROM inserted ed
+		JOIN Exercises e ON e.id = ed.exercise_id
+	`, exerciseID, input.WeekStart, input.WeekEnd, input.WarmupSets, input.WorkingSets,
+		input.Reps, input.Load, input.RPE, input.RestTime)
+	prescription, sectionID, err := scanPrescriptionWithSection(row)
+	switch pqErrorCode(err) {
+	case pqForeignKeyViolation:
+		return models.Prescription{}, 0, ErrExerciseNotFound
+	case pqCheckViolation:
+		return models.Prescription{}, 0, ErrInvalidPrescription
+	}
+	if err != nil {
+		return models.Prescription{}, 0, fmt.Errorf("failed to create prescription: %w", err)
+	}
+	return prescription, sectionID, nil
+}
+
+// updates the given fields of a prescription and returns it with the
+// exercise's section
+func UpdatePrescription(prescriptionID int, input models.PrescriptionInput) (models.Prescription, int, error) {
+	row := DB.QueryRow(`
+		WITH updated AS (
+			UPDATE ExerciseDetails
+			SET week_start = COALESCE($2, week_start),
+				week_end = COALESCE($3, week_end),
+				warmup_sets = COALESCE($4, warmup_sets),
+				working_sets = COALESCE($5, working_sets),
+				reps = COALESCE($6, reps),
+				load = COALESCE($7, load),
+				rpe = COALESCE($8, rpe),
+				rest_time = COALESCE($9, rest_time)
+			WHERE id = $1
+			RETURNING *
+		)
+		SELECT `+prescriptionColumns+`, e.workout_section_id
+		FROM updated ed
+		JOIN Exercises e ON e.id = ed.exercise_id
+	`, prescriptionID, input.WeekStart, input.WeekEnd, input.WarmupSets, input.WorkingSets,
+		input.Reps, input.Load, input.RPE, input.RestTime)
+	prescription, sectionID, err := scanPrescriptionWithSection(row)
+	if err == sql.ErrNoRows {
+		return models.Prescription{}, 0, ErrPrescriptionNotFound
+	}
+	if pqErrorCode(err) == pqCheckViolation {
+		return models.Prescription{}, 0, ErrInvalidPrescription
+	}
+	if err != nil {
+		return models.Prescription{}, 0, fmt.Errorf("failed to update prescription: %w", err)
+	}
+	return prescription, sectionID, nil
+}
+
+// deletes a prescription and returns the section of its exercise
+func DeletePrescription(prescriptionID int) (int, error) {
+	var sectionID int
+	err := DB.QueryRow(`
+		WITH deleted AS (
+			DELETE FROM ExerciseDetails WHERE id = $1 RETURNING exercise_id
+		)
+		SELECT e.workout_section_id
+		FROM deleted
+		JOIN Exercises e ON e.id = deleted.exercise_id
+	`, prescriptionID).Scan(&sectionID)
+	if err == sql.ErrNoRows {
+		return 0, ErrPrescriptionNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete prescription: %w", err)
+	}
+	return sectionID, nil
+}
+
+// columns of a prescription, selected from ExerciseDetails aliased as ed
+const prescriptionColumns = `ed.id, ed.exercise_id, ed.week_start, ed.week_end, COALESCE(ed.warmup_sets, 0),
+	COALESCE(ed.working_sets, 0), COALESCE(ed.reps, ''), ed.load, COALESCE(ed.rpe, ''), COALESCE(ed.rest_time, '')`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPrescription(row rowScanner, extra ...interface{}) (models.Prescription, error) {
+	var prescription models.Prescription
+	var load sql.NullFloat64
+	dest := []interface{}{
+		&prescription.ID, &prescription.ExerciseID, &prescription.WeekStart, &prescription.WeekEnd,
+		&prescription.WarmupSets, &prescription.WorkingSets, &prescription.Reps, &load,
+		&prescription.RPE, &prescription.RestTime,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return models.Prescription{}, err
+	}
+	if load.Valid {
+		prescription.Load = &load.Float64
+	}
+	return prescription, nil
+}
+
+func scanPrescriptionWithSection(row rowScanner) (models.Prescription, int, error) {
+	var sectionID int
+	prescription, err := scanPrescription(row, &sectionID)
+	return prescription, sectionID, err
+}
+
+// locks the rows selected by lockQuery and applies updateQuery, after checking
+// that ids is a permutation of the locked IDs. lockQuery takes args as $1...,
+// updateQuery takes the ID array as $1 followed by args.
+func reorder(lockQuery, updateQuery string, ids []int, args ...interface{}) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(lockQuery, args...)
+	if err != nil {
+		rollback(tx)
+		return err
+	}
+	current := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			if closeErr := rows.Close(); closeErr != nil {
+				utils.Logger.Error("Failed to close rows", zap.Error(closeErr))
+			}
+			rollback(tx)
+			return err
+		}
+		current[id] = true
+	}
+	if err := rows.Close(); err != nil {
+		rollback(tx)
+		return err
+	}
+
+	if len(ids) != len(current) {
+		rollback(tx)
+		return ErrReorderMismatch
+	}
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if !current[id] || seen[id] {
+			rollback(tx)
+			return ErrReorderMismatch
+		}
+		seen[id] = true
+	}
+
+	updateArgs := append([]interface{}{pq.Array(ids)}, args...)
+	if _, err := tx.Exec(updateQuery, updateArgs...); err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to reorder: %w", err)
+	}
+	return tx.Commit()
+}
+
+// maps "no row affected" to notFound
+// maps the error of deleting a catalog row by id: notFound when there was
+// none, ErrCatalogInUse when logged workouts still reference it
+func catalogDeleteError(err error, notFound error, what string) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return notFound
+	case pqErrorCode(err) == pqForeignKeyViolation:
+		return ErrCatalogInUse
+	default:
+		return fmt.Errorf("failed to delete %s: %w", what, err)
+	}
+}
+
+func expectOneRow(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestCatalogDeleteError(t *testing.T) {
+	// a user's logged entries still reference the row, directly or through
+	// the exercises a section delete cascades to
+	inUse := fmt.Errorf("exec: %w", &pq.Error{Code: pqForeignKeyViolation, Constraint: "userexercisesdetails_exercise_id_fkey"})
+	other := &pq.Error{Code: "57014"}
+
+	for _, notFound := range []error{ErrSectionNotFound, ErrExerciseNotFound} {
+		tests := []struct {
+			name string
+			err  error
+			want error
+		}{
+			{"deleted", nil, nil},
+			{"missing", sql.ErrNoRows, notFound},
+			{"logged against", inUse, ErrCatalogInUse},
+		}
+		for _, tt := range tests {
+			t.Run(notFound.Error()+"/"+tt.name, func(t *testing.T) {
+				if got := catalogDeleteError(tt.err, notFound, "row"); !errors.Is(got, tt.want) || (tt.want == nil && got != nil) {
+					t.Errorf("catalogDeleteError(%v) = %v, want %v", tt.err, got, tt.want)
+				}
+			})
+		}
+
+		got := catalogDeleteError(other, notFound, "row")
+		if errors.Is(got, ErrCatalogInUse) || errors.Is(got, notFound) || !errors.Is(got, other) {
+			t.Errorf("catalogDeleteError(%v) = %v, want the wrapped error", other, got)
+		}
+	}
+}
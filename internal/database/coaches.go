@@ -0,0 +1,40 @@
+package database
+
+import "fmt"
+
+// links a coach to a user they coach, linking them again is a no-op
+func LinkCoachAthlete(coachID, athleteID int) error {
+	_, err := DB.Exec(`
+		INSERT INTO CoachAthletes (coach_id, athlete_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, coachID, athleteID)
+	if pqErrorCode(err) == pqForeignKeyViolation {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to link coach: %w", err)
+	}
+	return nil
+}
+
+// removes the link between a coach and a user they coach
+func UnlinkCoachAthlete(coachID, athleteID int) error {
+	_, err := DB.Exec(`DELETE FROM CoachAthletes WHERE coach_id = $1 AND athlete_id = $2`, coachID, athleteID)
+	if err != nil {
+		return fmt.Errorf("failed to unlink coach: %w", err)
+	}
+	return nil
+}
+
+// reports whether coachID coaches athleteID
+func IsCoachOf(coachID, athleteID int) (bool, error) {
+	var linked bool
+	err := DB.QueryRow(`
+		SELECT EXISTS (SELECT 1 FROM CoachAthletes WHERE coach_id = $1 AND athlete_id = $2)
+	`, coachID, athleteID).Scan(&linked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check coach: %w", err)
+	}
+	return linked, nil
+}
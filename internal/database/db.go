@@ -3,7 +3,6 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
 
 	"github.com/haikali3/gymbara-backend/config"
 	"github.com/haikali3/gymbara-backend/pkg/models"
@@ -47,50 +46,52 @@ func Connect(cfg *config.Config) {
 	utils.Logger.Info("Database connected successfully.")
 }
 
-// inserts or updates a user in the database after OAuth2 login
-func StoreUserInDB(user models.GoogleUser, provider string) (int, error) {
-	var userID int
-	query := `
-			INSERT INTO Users (username, email, oauth_provider, oauth_id)
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (email) DO UPDATE
-			SET username = EXCLUDED.username
-			RETURNING id
-	`
-	err := DB.QueryRow(query, user.Name, user.Email, provider, user.ID).Scan(&userID)
-	if err != nil {
-		log.Println("Error storing user in DB:", err)
-		return 0, err
-	}
-	log.Println("User stored in DB with ID:", userID)
-	return userID, nil
-}
-
-func StoreUserWithToken(user models.GoogleUser, accessToken string, refreshToken string) error {
+// signs the identity in as its user, creating or auto-linking the account as
+// needed, and stores the provider tokens of this login. Returns ErrLinkRequired
+// when the email is taken by an account the identity can't be attached to.
+func StoreUserWithToken(identity models.OAuthIdentity, accessToken string, refreshToken string) (int, error) {
 	//TODO: is it normal for this access token will update current row and also other row for column access token?
 	utils.Logger.Debug("Updating user with access and refresh tokens",
-		zap.String("email", user.Email),
+		zap.String("email", identity.Email),
+		zap.String("provider", identity.Provider),
 		zap.String("accessToken", accessToken),
 		zap.String("refreshToken", refreshToken),
 		// zap.String("accessToken", "***"), // Mask sensitive data in logs
 		// zap.String("refreshToken", "***"),
 	)
 
-	_, err := DB.Exec(`
-			INSERT INTO Users (username, email, oauth_provider, oauth_id, access_token, refresh_token)
-			VALUES ($1, $2, $3, $4, $5, $6)
-			ON CONFLICT (email) DO UPDATE
-			SET username = EXCLUDED.username, 
-					access_token = EXCLUDED.access_token,
-					refresh_token = COALESCE(NULLIF(EXCLUDED.refresh_token, ''), Users.refresh_token)
-	`, user.Name, user.Email, "google", user.ID, accessToken, refreshToken)
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
 
+	userID, err := resolveIdentityUser(tx, identity)
 	if err != nil {
+		rollback(tx)
+		return 0, err
+	}
+
+	// the tokens belong to the provider of the latest login, an old refresh
+	// token is only kept while it belongs to that same provider
+	_, err = tx.Exec(`
+		UPDATE Users
+		SET username = COALESCE(NULLIF($2, ''), username),
+			oauth_provider = $3,
+			oauth_id = $4,
+			access_token = $5,
+			refresh_token = COALESCE(NULLIF($6::text, ''), CASE WHEN oauth_provider = $3 THEN refresh_token END)
+		WHERE id = $1
+	`, userID, identity.Name, identity.Provider, identity.Subject, accessToken, refreshToken)
+	if err != nil {
+		rollback(tx)
 		utils.Logger.Error("Failed to store user with token", zap.Error(err))
-		return fmt.Errorf("failed to store user with token: %w", err)
+		return 0, fmt.Errorf("failed to store user with token: %w", err)
 	}
 
-	return nil
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to store user with token: %w", err)
+	}
+	return userID, nil
 }
 
 func Close() {
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/training"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrEntryNotFound      = errors.New("exercise entry not found")
+	ErrEntryNotRestorable = errors.New("exercise entry was deleted too long ago to be restored")
+)
+
+// how long a deleted entry can be restored before it is purged
+const entryRestoreWindow = 24 * time.Hour
+
+// reports whether an entry deleted deletedFor ago can still be restored
+func EntryRestorable(deletedFor time.Duration) bool {
+	return deletedFor <= entryRestoreWindow
+}
+
+// returns one of the user's logged entries with its sets
+func GetExerciseEntry(userID, entryID int) (models.UserProgressResponse, error) {
+	var (
+		entry          models.UserProgressResponse
+		submittedAt    time.Time
+		performedAt    sql.NullTime
+		prescribedReps sql.NullString
+		prescribedRPE  sql.NullString
+	)
+	err := DB.QueryRow(`
+		SELECT ued.id, ued.exercise_id, e.name, e.measurement_type, COALESCE(ued.custom_load, 0), COALESCE(ued.custom_reps, 0),
+			ued.submitted_at, ued.performed_at, ued.prescribed_reps, ued.prescribed_rpe
+		FROM UserExercisesDetails ued
+		JOIN Exercises e ON e.id = ued.exercise_id
+		JOIN UserWorkouts uw ON uw.id = ued.user_workout_id
+		WHERE ued.id = $1 AND uw.user_id = $2 AND ued.deleted_at IS NULL
+	`, entryID, userID).Scan(
+		&entry.ID, &entry.ExerciseID, &entry.ExerciseName, &entry.MeasurementType, &entry.CustomLoad, &entry.CustomReps,
+		&submittedAt, &performedAt, &prescribedReps, &prescribedRPE,
+	)
+	if err == sql.ErrNoRows {
+		return models.UserProgressResponse{}, ErrEntryNotFound
+	}
+	if err != nil {
+		return models.UserProgressResponse{}, fmt.Errorf("failed to query exercise entry: %w", err)
+	}
+	entry.SubmittedAt = submittedAt.Format(dateLayout)
+	if performedAt.Valid {
+		entry.PerformedAt = &performedAt.Time
+	}
+	entry.Effort = prescribedEffort(prescribedReps, prescribedRPE)
+
+	sets, err := GetExerciseSets([]int{entry.ID})
+	if err != nil {
+		return models.UserProgressResponse{}, err
+	}
+	entries := []models.UserProgressResponse{entry}
+	attachSets(entries, sets)
+	return entries[0], nil
+}
+
+// replaces the sets of one of the user's entries and returns the entry
+func UpdateExerciseEntry(userID, entryID int, sets []models.UserExerciseSet) (models.UserProgressResponse, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return models.UserProgressResponse{}, err
+	}
+
+	// the UserWorkouts lock keeps records stable, see RecordPersonalRecords
+	var exerciseID int
+	var measurementType string
+	var performedAt time.Time
+	err = tx.QueryRow(`
+		SELECT ued.exercise_id, e.measurement_type, ued.performed_at
+		FROM UserExercisesDetails ued
+		JOIN UserWorkouts uw ON uw.id = ued.user_workout_id
+		JOIN Exercises e ON e.id = ued.exercise_id
+		WHERE ued.id = $1 AND uw.user_id = $2 AND ued.deleted_at IS NULL
+		FOR UPDATE OF ued, uw
+	`, entryID, userID).Scan(&exerciseID, &measurementType, &performedAt)
+	if err == sql.ErrNoRows {
+		rollback(tx)
+		return models.UserProgressResponse{}, ErrEntryNotFound
+	}
+	if err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, fmt.Errorf("failed to lock exercise entry: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM UserExerciseSets WHERE user_exercise_detail_id = $1`, entryID); err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, fmt.Errorf("failed to delete exercise sets: %w", err)
+	}
+	if err := insertExerciseSets(tx, entryID, sets, 0); err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, fmt.Errorf("failed to insert exercise sets: %w", err)
+	}
+	if err := updateTopSet(tx, entryID); err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, fmt.Errorf("failed to update top set: %w", err)
+	}
+
+	candidates := training.RecordCandidates(measurementType, sets)
+	for i := range candidates {
+		candidates[i].ExerciseID, candidates[i].EntryID, candidates[i].AchievedAt = exerciseID, entryID, performedAt
+	}
+	if _, err := RecordPersonalRecords(tx, userID, []int{entryID}, candidates); err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.UserProgressResponse{}, err
+	}
+	return GetExerciseEntry(userID, entryID)
+}
+
+// soft deletes one of the user's entries and returns until when it can be
+// restored. Entries of the user deleted before the restore window are purged.
+func DeleteExerciseEntry(userID, entryID int) (time.Time, error) {
+	var restorableUntil time.Time
+	err := DB.QueryRow(`
+		UPDATE UserExercisesDetails ued
+		SET deleted_at = NOW()
+		FROM UserWorkouts uw
+		WHERE ued.id = $1 AND uw.id = ued.user_workout_id AND uw.user_id = $2 AND ued.deleted_at IS NULL
+		RETURNING ued.deleted_at + make_interval(secs => $3)
+	`, entryID, userID, entryRestoreWindow.Seconds()).Scan(&restorableUntil)
+	if err == sql.ErrNoRows {
+		return time.Time{}, ErrEntryNotFound
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to delete exercise entry: %w", err)
+	}
+
+	// purging is housekeeping, the delete itself succeeded
+	_, err = DB.Exec(`
+		DELETE FROM UserExercisesDetails ued
+		USING UserWorkouts uw
+		WHERE uw.id = ued.user_workout_id AND uw.user_id = $1
+			AND ued.deleted_at < NOW() - make_interval(secs => $2)
+	`, userID, entryRestoreWindow.Seconds())
+	if err != nil {
+		utils.Logger.Error("Failed to purge deleted exercise entries", zap.Int("user_id", userID), zap.Error(err))
+	}
+	return restorableUntil, nil
+}
+
+// restores one of the user's deleted entries within the restore window.
+// Restoring an entry that is not deleted returns it unchanged.
+func RestoreExerciseEntry(userID, entryID int) (models.UserProgressResponse, error) {
+	// measured with the database clock, the one deleted_at was set with
+	var deletedFor sql.NullFloat64
+	err := DB.QueryRow(`
+		SELECT EXTRACT(EPOCH FROM NOW() - ued.deleted_at)
+		FROM UserExercisesDetails ued
+		JOIN UserWorkouts uw ON uw.id = ued.user_workout_id
+		WHERE ued.id = $1 AND uw.user_id = $2
+	`, entryID, userID).Scan(&deletedFor)
+	if err == sql.ErrNoRows {
+		return models.UserProgressResponse{}, ErrEntryNotFound
+	}
+	if err != nil {
+		return models.UserProgressResponse{}, fmt.Errorf("failed to query exercise entry: %w", err)
+	}
+	if !deletedFor.Valid {
+		return GetExerciseEntry(userID, entryID)
+	}
+	if !EntryRestorable(time.Duration(deletedFor.Float64 * float64(time.Second))) {
+		return models.UserProgressResponse{}, ErrEntryNotRestorable
+	}
+
+	// a purge in between leaves nothing to restore
+	result, err := DB.Exec(`
+		UPDATE UserExercisesDetails
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`, entryID)
+	if err != nil {
+		return models.UserProgressResponse{}, fmt.Errorf("failed to restore exercise entry: %w", err)
+	}
+	if err := expectOneRow(result, ErrEntryNotRestorable); err != nil {
+		return models.UserProgressResponse{}, err
+	}
+	return GetExerciseEntry(userID, entryID)
+}
+
+// stores the prescription of program week on the given entries, the nearest
+// week range when the week has none, like the exercise statements
+func SnapshotPrescriptions(tx *sql.Tx, detailIDs []int, week int) error {
+	_, err := tx.Exec(`
+		UPDATE UserExercisesDetails ued
+		SET prescribed_reps = p.reps, prescribed_rpe = p.rpe
+		FROM (
+			SELECT DISTINCT ON (ed.exercise_id) ed.exercise_id, ed.reps, ed.rpe
+			FROM ExerciseDetails ed
+			WHERE ed.exercise_id IN (SELECT exercise_id FROM UserExercisesDetails WHERE id = ANY($1))
+			ORDER BY ed.exercise_id, `+weekDistance+`, ed.week_start
+		) p
+		WHERE ued.id = ANY($1) AND p.exercise_id = ued.exercise_id
+	`, pq.Array(detailIDs), week)
+	return err
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// returns the sets of the given UserExercisesDetails rows, keyed by row ID
+func GetExerciseSets(detailIDs []int) (map[int][]models.UserExerciseSet, error) {
+	return queryExerciseSets(DB, detailIDs)
+}
+
+// *sql.DB or *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// GetExerciseSets through db, a transaction sees the sets it inserted
+func queryExerciseSets(db queryer, detailIDs []int) (map[int][]models.UserExerciseSet, error) {
+	sets := make(map[int][]models.UserExerciseSet, len(detailIDs))
+	if len(detailIDs) == 0 {
+		return sets, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT user_exercise_detail_id, set_index, set_type, reps, load, rpe, rir, rest_seconds,
+			duration_seconds, distance_meters, bodyweight, load_unit
+		FROM UserExerciseSets
+		WHERE user_exercise_detail_id = ANY($1)
+		ORDER BY user_exercise_detail_id, set_index
+	`, pq.Array(detailIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exercise sets: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			detailID                       int
+			set                            models.UserExerciseSet
+			rpe, rir, distance, bodyweight sql.NullFloat64
+			restSeconds, duration          sql.NullInt64
+		)
+		if err := rows.Scan(
+			&detailID, &set.SetIndex, &set.SetType, &set.Reps, &set.Load, &rpe, &rir, &restSeconds,
+			&duration, &distance, &bodyweight, &set.LoadUnit,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan exercise set: %w", err)
+		}
+		if rpe.Valid {
+			set.RPE = &rpe.Float64
+		}
+		if rir.Valid {
+			set.RIR = &rir.Float64
+		}
+		if restSeconds.Valid {
+			rest := int(restSeconds.Int64)
+			set.RestSeconds = &rest
+		}
+		if duration.Valid {
+			seconds := int(duration.Int64)
+			set.DurationSeconds = &seconds
+		}
+		if distance.Valid {
+			set.DistanceMeters = &distance.Float64
+		}
+		if bodyweight.Valid {
+			set.Bodyweight = &bodyweight.Float64
+		}
+		sets[detailID] = append(sets[detailID], set)
+	}
+	return sets, rows.Err()
+}
+
+// inserts sets of a UserExercisesDetails row, their set indexes shifted by offset
+func insertExerciseSets(tx *sql.Tx, detailID int, sets []models.UserExerciseSet, offset int) error {
+	var placeholders []string
+	var values []interface{}
+	for _, set := range sets {
+		n := len(values)
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, COALESCE(NULLIF($%d, ''), 'kg'))",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12))
+		values = append(values, detailID, offset+set.SetIndex, set.SetType, set.Reps, set.Load, set.RPE, set.RIR, set.RestSeconds,
+			set.DurationSeconds, set.DistanceMeters, set.Bodyweight, set.LoadUnit)
+	}
+	if len(placeholders) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(fmt.Sprintf(`
+		INSERT INTO UserExerciseSets (user_exercise_detail_id, set_index, set_type, reps, load, rpe, rir, rest_seconds,
+			duration_seconds, distance_meters, bodyweight, load_unit)
+		VALUES %s
+	`, strings.Join(placeholders, ", ")), values...)
+	return err
+}
+
+// keeps custom_reps and custom_load of a row in line with its sets, same top
+// set rule as loose submissions, see training.TopSet
+func updateTopSet(tx *sql.Tx, detailID int) error {
+	_, err := tx.Exec(`
+		UPDATE UserExercisesDetails ued
+		SET (custom_reps, custom_load) = (
+			SELECT s.reps, s.load
+			FROM UserExerciseSets s
+			JOIN Exercises e ON e.id = ued.exercise_id
+			WHERE s.user_exercise_detail_id = ued.id
+			ORDER BY s.set_type = 'working' DESC,
+				CASE e.measurement_type
+					WHEN 'duration' THEN COALESCE(s.duration_seconds, 0)
+					WHEN 'distance' THEN COALESCE(s.distance_meters, 0)
+					WHEN 'assisted' THEN -s.load
+					ELSE s.load
+				END DESC,
+				s.reps DESC
+			LIMIT 1
+		)
+		WHERE ued.id = $1
+	`, detailID)
+	return err
+}
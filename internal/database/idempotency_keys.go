@@ -0,0 +1,153 @@
+package database
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrIdempotencyKeyInUse is returned when a key was released while it was
+// being looked up, the client should retry
+var ErrIdempotencyKeyInUse = errors.New("idempotency key is in use")
+
+// how long a stored response is replayed before the key can be reused
+const idempotencyKeyTTL = 24 * time.Hour
+
+// how long a reservation without a response holds its key. A request that
+// crashed or timed out never completes or releases it, retries take it over
+// after this.
+const idempotencyKeyLease = time.Minute
+
+// IdempotencyRecord is a key reserved by an earlier request. Status is 0
+// while that request is still in progress. Age is how long ago it was
+// reserved, by the database clock.
+type IdempotencyRecord struct {
+	ID          int
+	RequestHash string
+	Status      int
+	Body        []byte
+	Age         time.Duration
+}
+
+// whether the key can be reserved again: its response expired, or its
+// request didn't finish within the lease
+func (r IdempotencyRecord) Reclaimable() bool {
+	if r.Status == 0 {
+		return r.Age > idempotencyKeyLease
+	}
+	return r.Age > idempotencyKeyTTL
+}
+
+// IdempotencyReservation is a request's hold on a key. Token changes when
+// another request takes the key over, so only the holder can complete or
+// release it.
+type IdempotencyReservation struct {
+	ID    int
+	Token string
+}
+
+// reserves key for a request with the given hash. Returns the reservation,
+// or the record of the earlier request when the key was already used.
+// Reclaimable keys are reserved again.
+func ReserveIdempotencyKey(userID int, key, requestHash string) (IdempotencyReservation, *IdempotencyRecord, error) {
+	token, err := newReservationToken()
+	if err != nil {
+		return IdempotencyReservation{}, nil, err
+	}
+	reservation := IdempotencyReservation{Token: token}
+	tx, err := DB.Begin()
+	if err != nil {
+		return IdempotencyReservation{}, nil, err
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO idempotency_keys (user_id, key, request_hash, reservation_token)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, key) DO NOTHING
+		RETURNING id
+	`, userID, key, requestHash, reservation.Token).Scan(&reservation.ID)
+	if err == nil {
+		return reservation, nil, tx.Commit()
+	}
+	if err != sql.ErrNoRows {
+		rollback(tx)
+		return IdempotencyReservation{}, nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	var (
+		record     IdempotencyRecord
+		status     sql.NullInt64
+		ageSeconds float64
+	)
+	err = tx.QueryRow(`
+		SELECT id, request_hash, response_status, response_body, EXTRACT(EPOCH FROM NOW() - created_at)
+		FROM idempotency_keys
+		WHERE user_id = $1 AND key = $2
+		FOR UPDATE
+	`, userID, key).Scan(&record.ID, &record.RequestHash, &status, &record.Body, &ageSeconds)
+	if err == sql.ErrNoRows {
+		// released since the insert, the client should retry
+		rollback(tx)
+		return IdempotencyReservation{}, nil, ErrIdempotencyKeyInUse
+	}
+	if err != nil {
+		rollback(tx)
+		return IdempotencyReservation{}, nil, fmt.Errorf("failed to query idempotency key: %w", err)
+	}
+	record.Status = int(status.Int64)
+	record.Age = time.Duration(ageSeconds * float64(time.Second))
+	if !record.Reclaimable() {
+		rollback(tx)
+		return IdempotencyReservation{}, &record, nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE idempotency_keys
+		SET request_hash = $2,
+			reservation_token = $3,
+			response_status = NULL,
+			response_body = NULL,
+			created_at = NOW(),
+			completed_at = NULL
+		WHERE id = $1
+	`, record.ID, requestHash, reservation.Token)
+	if err != nil {
+		rollback(tx)
+		return IdempotencyReservation{}, nil, fmt.Errorf("failed to take over idempotency key: %w", err)
+	}
+	reservation.ID = record.ID
+	return reservation, nil, tx.Commit()
+}
+
+// a random version 4 UUID
+func newReservationToken() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// stores the response of the request holding reservation, a no-op when the
+// key was taken over
+func CompleteIdempotencyKey(reservation IdempotencyReservation, status int, body []byte) error {
+	_, err := DB.Exec(`
+		UPDATE idempotency_keys
+		SET response_status = $3, response_body = $4, completed_at = NOW()
+		WHERE id = $1 AND reservation_token = $2
+	`, reservation.ID, reservation.Token, status, body)
+	return err
+}
+
+// drops a reservation whose request failed, so the key can be retried. A
+// no-op when the key was taken over.
+func ReleaseIdempotencyKey(reservation IdempotencyReservation) error {
+	_, err := DB.Exec(`
+		DELETE FROM idempotency_keys WHERE id = $1 AND reservation_token = $2 AND completed_at IS NULL
+	`, reservation.ID, reservation.Token)
+	return err
+}
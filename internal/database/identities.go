@@ -0,0 +1,189 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrLinkRequired is returned when a new identity's email belongs to an
+	// existing account but cannot be trusted enough to attach it automatically
+	ErrLinkRequired = errors.New("an account with this email already exists, sign in and link this identity instead")
+	// ErrIdentityLinked is returned when the identity already belongs to another user
+	ErrIdentityLinked = errors.New("identity is linked to another account")
+	// ErrIdentityNotFound is returned when the user has no such identity
+	ErrIdentityNotFound = errors.New("identity not found")
+	// ErrLastIdentity is returned when unlinking would leave the user unable to sign in
+	ErrLastIdentity = errors.New("cannot unlink the last identity")
+)
+
+// finds the user an identity signs in as. Unknown identities are attached to
+// the account with the same email only when both sides have verified it,
+// otherwise a new account is created.
+func resolveIdentityUser(tx *sql.Tx, identity models.OAuthIdentity) (int, error) {
+	var userID int
+	err := tx.QueryRow(`
+		UPDATE user_identities
+		SET email = $3, email_verified = $4, last_login_at = NOW()
+		WHERE provider = $1 AND subject = $2
+		RETURNING user_id
+	`, identity.Provider, identity.Subject, identity.Email, identity.EmailVerified).Scan(&userID)
+	if err == nil {
+		return userID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("failed to look up identity: %w", err)
+	}
+
+	err = tx.QueryRow(`SELECT id FROM Users WHERE email = $1 FOR UPDATE`, identity.Email).Scan(&userID)
+	switch {
+	case err == sql.ErrNoRows:
+		err = tx.QueryRow(`
+			INSERT INTO Users (username, email, oauth_provider, oauth_id)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id
+		`, identity.Name, identity.Email, identity.Provider, identity.Subject).Scan(&userID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create user: %w", err)
+		}
+	case err != nil:
+		return 0, fmt.Errorf("failed to look up user by email: %w", err)
+	default:
+		if !identity.EmailVerified {
+			return 0, ErrLinkRequired
+		}
+		var emailVerified bool
+		err = tx.QueryRow(`
+			SELECT EXISTS (
+				SELECT 1 FROM user_identities
+				WHERE user_id = $1 AND email_verified AND LOWER(email) = LOWER($2)
+			)
+		`, userID, identity.Email).Scan(&emailVerified)
+		if err != nil {
+			return 0, fmt.Errorf("failed to check verified email: %w", err)
+		}
+		if !emailVerified {
+			return 0, ErrLinkRequired
+		}
+		utils.Logger.Info("Auto-linking identity by verified email",
+			zap.Int("user_id", userID),
+			zap.String("provider", identity.Provider),
+		)
+	}
+
+	if _, err = tx.Exec(`
+		INSERT INTO user_identities (user_id, provider, subject, email, email_verified)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, identity.Provider, identity.Subject, identity.Email, identity.EmailVerified); err != nil {
+		return 0, fmt.Errorf("failed to store identity: %w", err)
+	}
+	return userID, nil
+}
+
+// attaches an identity to a signed-in user, returns ErrIdentityLinked when it
+// already belongs to someone else
+func LinkIdentity(userID int, identity models.OAuthIdentity) error {
+	var identityID int
+	err := DB.QueryRow(`
+		INSERT INTO user_identities (user_id, provider, subject, email, email_verified)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, subject) DO UPDATE
+		SET email = EXCLUDED.email, email_verified = EXCLUDED.email_verified
+		WHERE user_identities.user_id = EXCLUDED.user_id
+		RETURNING id
+	`, userID, identity.Provider, identity.Subject, identity.Email, identity.EmailVerified).Scan(&identityID)
+	if err == sql.ErrNoRows {
+		return ErrIdentityLinked
+	}
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
+
+// lists the identities the user can sign in with, oldest first
+func ListIdentities(userID int) ([]models.UserIdentity, error) {
+	rows, err := DB.Query(`
+		SELECT id, provider, COALESCE(email, ''), email_verified, created_at, last_login_at
+		FROM user_identities
+		WHERE user_id = $1
+		ORDER BY created_at, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	identities := []models.UserIdentity{}
+	for rows.Next() {
+		var identity models.UserIdentity
+		if err := rows.Scan(&identity.ID, &identity.Provider, &identity.Email, &identity.EmailVerified, &identity.CreatedAt, &identity.LastLoginAt); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+// removes one of the user's identities. The last one can't be removed, the
+// account would have no way to sign in.
+func UnlinkIdentity(userID, identityID int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	// lock every identity of the user so concurrent unlinks can't remove them all
+	var count int
+	if err = tx.QueryRow(`
+		SELECT COUNT(*) FROM (
+			SELECT id FROM user_identities WHERE user_id = $1 FOR UPDATE
+		) locked
+	`, userID).Scan(&count); err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to count identities: %w", err)
+	}
+
+	var provider, subject string
+	err = tx.QueryRow(`
+		SELECT provider, subject FROM user_identities WHERE id = $1 AND user_id = $2
+	`, identityID, userID).Scan(&provider, &subject)
+	if err == sql.ErrNoRows {
+		rollback(tx)
+		return ErrIdentityNotFound
+	}
+	if err != nil {
+		rollback(tx)
+		return err
+	}
+	if count <= 1 {
+		rollback(tx)
+		return ErrLastIdentity
+	}
+
+	if _, err = tx.Exec(`DELETE FROM user_identities WHERE id = $1`, identityID); err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to unlink identity: %w", err)
+	}
+
+	// provider tokens stored from a login with this identity are no longer ours to use
+	if _, err = tx.Exec(`
+		UPDATE Users
+		SET access_token = NULL, refresh_token = NULL
+		WHERE id = $1 AND oauth_provider = $2 AND oauth_id = $3
+	`, userID, provider, subject); err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to clear provider tokens: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// instruction kinds stored in Instructions.kind
+const (
+	instructionKindStep    = "step"
+	instructionKindCue     = "cue"
+	instructionKindMistake = "mistake"
+)
+
+// returns the steps, cues and mistakes of an exercise in display order
+func GetInstructions(exerciseID int) (models.ExerciseInstructions, error) {
+	instructions := models.ExerciseInstructions{Steps: []string{}, Cues: []string{}, Mistakes: []string{}}
+
+	rows, err := DB.Query(`
+		SELECT kind, instruction
+		FROM Instructions
+		WHERE exercise_id = $1
+		ORDER BY kind, position, id
+	`, exerciseID)
+	if err != nil {
+		return instructions, fmt.Errorf("failed to query instructions: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	for rows.Next() {
+		var kind, text string
+		if err := rows.Scan(&kind, &text); err != nil {
+			return instructions, fmt.Errorf("failed to scan instruction: %w", err)
+		}
+		switch kind {
+		case instructionKindStep:
+			instructions.Steps = append(instructions.Steps, text)
+		case instructionKindCue:
+			instructions.Cues = append(instructions.Cues, text)
+		case instructionKindMistake:
+			instructions.Mistakes = append(instructions.Mistakes, text)
+		}
+	}
+	return instructions, rows.Err()
+}
+
+// replaces all instructions of an exercise
+func ReplaceInstructions(exerciseID int, instructions models.ExerciseInstructions) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	// lock the exercise so concurrent edits don't interleave
+	var id int
+	err = tx.QueryRow(`SELECT id FROM Exercises WHERE id = $1 FOR UPDATE`, exerciseID).Scan(&id)
+	if err == sql.ErrNoRows {
+		rollback(tx)
+		return ErrExerciseNotFound
+	}
+	if err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to lock exercise: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM Instructions WHERE exercise_id = $1`, exerciseID); err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to clear instructions: %w", err)
+	}
+
+	for kind, texts := range map[string][]string{
+		instructionKindStep:    instructions.Steps,
+		instructionKindCue:     instructions.Cues,
+		instructionKindMistake: instructions.Mistakes,
+	} {
+		if len(texts) == 0 {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO Instructions (exercise_id, kind, position, instruction)
+			SELECT $1, $2, t.position, t.instruction
+			FROM unnest($3::text[]) WITH ORDINALITY AS t(instruction, position)
+		`, exerciseID, kind, pq.Array(texts))
+		if err != nil {
+			rollback(tx)
+			return fmt.Errorf("failed to insert instructions: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Refresh tokens are stored hashed. Every login starts a new family; each
+-- refresh rotates the token within the family, so presenting a rotated token
+-- again means it was stolen and the whole family gets revoked.
+CREATE TABLE RefreshTokens (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    family_id VARCHAR(64) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    rotated_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_refresh_tokens_family_id ON RefreshTokens (family_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS RefreshTokens;
+-- +goose StatementEnd
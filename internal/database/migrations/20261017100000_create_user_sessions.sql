@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- One row per login. Session tokens carry the session ID so a revoked
+-- session is rejected even before its token expires.
+CREATE TABLE user_sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_user_sessions_user_id ON user_sessions (user_id);
+
+-- refresh token families now belong to a session; tokens issued before
+-- sessions existed cannot be tied to one, so those users sign in again
+DELETE FROM RefreshTokens;
+ALTER TABLE RefreshTokens
+ADD COLUMN session_id INT NOT NULL REFERENCES user_sessions(id) ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE RefreshTokens DROP COLUMN IF EXISTS session_id;
+DROP TABLE IF EXISTS user_sessions;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_sessions
+ADD COLUMN user_agent TEXT,
+ADD COLUMN ip_address VARCHAR(45),
+ADD COLUMN last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_sessions
+DROP COLUMN IF EXISTS user_agent,
+DROP COLUMN IF EXISTS ip_address,
+DROP COLUMN IF EXISTS last_seen_at;
+-- +goose StatementEnd
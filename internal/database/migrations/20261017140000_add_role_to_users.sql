@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE Users
+ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'user'
+CHECK (role IN ('user', 'coach', 'admin'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Users
+DROP COLUMN IF EXISTS role;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE WorkoutSections ADD COLUMN sort_order INT NOT NULL DEFAULT 0;
+ALTER TABLE Exercises ADD COLUMN sort_order INT NOT NULL DEFAULT 0;
+
+-- keep the current id based ordering
+UPDATE WorkoutSections SET sort_order = id;
+UPDATE Exercises SET sort_order = id;
+
+-- enforced for new and edited prescriptions only, seeded rows are left alone
+ALTER TABLE ExerciseDetails
+ADD CONSTRAINT exercise_details_week_range CHECK (week_start >= 1 AND week_end >= week_start) NOT VALID;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE ExerciseDetails DROP CONSTRAINT IF EXISTS exercise_details_week_range;
+ALTER TABLE Exercises DROP COLUMN IF EXISTS sort_order;
+ALTER TABLE WorkoutSections DROP COLUMN IF EXISTS sort_order;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- step: ordered how-to, cue: short coaching cue, mistake: common mistake to avoid
+ALTER TABLE Instructions ADD COLUMN kind VARCHAR(10) NOT NULL DEFAULT 'step'
+    CHECK (kind IN ('step', 'cue', 'mistake'));
+ALTER TABLE Instructions ADD COLUMN position INT NOT NULL DEFAULT 0;
+
+-- existing rows become steps in insertion order
+UPDATE Instructions i
+SET position = numbered.position
+FROM (
+    SELECT id, ROW_NUMBER() OVER (PARTITION BY exercise_id ORDER BY id) AS position
+    FROM Instructions
+) numbered
+WHERE i.id = numbered.id;
+
+CREATE INDEX idx_instructions_exercise ON Instructions (exercise_id, kind, position);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_instructions_exercise;
+ALTER TABLE Instructions DROP COLUMN IF EXISTS position;
+ALTER TABLE Instructions DROP COLUMN IF EXISTS kind;
+-- +goose StatementEnd
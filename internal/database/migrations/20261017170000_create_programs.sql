@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE Programs (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    length_weeks INT NOT NULL CHECK (length_weeks >= 1),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE WorkoutSections ADD COLUMN program_id INT REFERENCES Programs(id) ON DELETE SET NULL;
+
+-- existing sections form the default program, long enough for every seeded week
+INSERT INTO Programs (name, description, length_weeks)
+SELECT 'Gymbara Hypertrophy', 'The default Gymbara program', GREATEST(COALESCE(MAX(week_end), 1), 1)
+FROM ExerciseDetails;
+
+UPDATE WorkoutSections SET program_id = (SELECT MIN(id) FROM Programs);
+
+-- the program each user follows and the date its week 1 started
+CREATE TABLE user_programs (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL UNIQUE REFERENCES Users(id) ON DELETE CASCADE,
+    program_id INT NOT NULL REFERENCES Programs(id) ON DELETE CASCADE,
+    start_date DATE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_programs;
+ALTER TABLE WorkoutSections DROP COLUMN IF EXISTS program_id;
+DROP TABLE IF EXISTS Programs;
+-- +goose StatementEnd
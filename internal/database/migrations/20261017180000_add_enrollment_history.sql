@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- keep ended enrollments as history, a user has at most one active or paused one
+ALTER TABLE user_programs DROP CONSTRAINT IF EXISTS user_programs_user_id_key;
+ALTER TABLE user_programs ADD COLUMN status VARCHAR(10) NOT NULL DEFAULT 'active'
+    CHECK (status IN ('active', 'paused', 'ended'));
+ALTER TABLE user_programs ADD COLUMN paused_at TIMESTAMP;
+-- whole days spent paused, the program calendar is shifted by this much
+ALTER TABLE user_programs ADD COLUMN paused_days INT NOT NULL DEFAULT 0;
+ALTER TABLE user_programs ADD COLUMN ended_at TIMESTAMP;
+ALTER TABLE user_programs ADD COLUMN end_reason VARCHAR(10)
+    CHECK (end_reason IN ('restarted', 'switched'));
+
+CREATE UNIQUE INDEX user_programs_current ON user_programs (user_id) WHERE status <> 'ended';
+CREATE INDEX idx_user_programs_user ON user_programs (user_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_user_programs_user;
+DROP INDEX IF EXISTS user_programs_current;
+DELETE FROM user_programs WHERE status = 'ended';
+ALTER TABLE user_programs DROP COLUMN IF EXISTS end_reason;
+ALTER TABLE user_programs DROP COLUMN IF EXISTS ended_at;
+ALTER TABLE user_programs DROP COLUMN IF EXISTS paused_days;
+ALTER TABLE user_programs DROP COLUMN IF EXISTS paused_at;
+ALTER TABLE user_programs DROP COLUMN IF EXISTS status;
+ALTER TABLE user_programs ADD CONSTRAINT user_programs_user_id_key UNIQUE (user_id);
+-- +goose StatementEnd
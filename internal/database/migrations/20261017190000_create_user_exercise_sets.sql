@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- individual sets of a logged exercise, UserExercisesDetails keeps the top set
+CREATE TABLE UserExerciseSets (
+    id SERIAL PRIMARY KEY,
+    user_exercise_detail_id INT NOT NULL REFERENCES UserExercisesDetails(id) ON DELETE CASCADE,
+    set_index INT NOT NULL CHECK (set_index >= 1),
+    set_type VARCHAR(10) NOT NULL DEFAULT 'working' CHECK (set_type IN ('warmup', 'working', 'drop')),
+    reps INT NOT NULL CHECK (reps >= 0),
+    load DOUBLE PRECISION NOT NULL DEFAULT 0 CHECK (load >= 0),
+    rpe DOUBLE PRECISION CHECK (rpe BETWEEN 1 AND 10),
+    rest_seconds INT CHECK (rest_seconds >= 0),
+    UNIQUE (user_exercise_detail_id, set_index)
+);
+
+-- existing logs become a single working set
+INSERT INTO UserExerciseSets (user_exercise_detail_id, set_index, set_type, reps, load)
+SELECT id, 1, 'working', custom_reps, COALESCE(custom_load, 0)
+FROM UserExercisesDetails
+WHERE custom_reps IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS UserExerciseSets;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE WorkoutSessions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    user_workout_id INT NOT NULL REFERENCES UserWorkouts(id) ON DELETE CASCADE,
+    started_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP,
+    session_rpe DOUBLE PRECISION CHECK (session_rpe BETWEEN 1 AND 10),
+    notes TEXT,
+    CHECK (finished_at IS NULL OR finished_at >= started_at)
+);
+
+CREATE INDEX idx_workout_sessions_user ON WorkoutSessions (user_id, started_at DESC);
+
+ALTER TABLE UserExercisesDetails ADD COLUMN session_id INT REFERENCES WorkoutSessions(id) ON DELETE CASCADE;
+
+-- loose submissions keep one row per exercise per day, sessions one row per
+-- exercise per session, so the same section can be trained twice a day
+ALTER TABLE UserExercisesDetails DROP CONSTRAINT IF EXISTS unique_user_exercise_submission;
+CREATE UNIQUE INDEX unique_user_exercise_submission
+    ON UserExercisesDetails (user_workout_id, exercise_id, submitted_at) WHERE session_id IS NULL;
+CREATE UNIQUE INDEX unique_user_exercise_session
+    ON UserExercisesDetails (session_id, exercise_id) WHERE session_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS unique_user_exercise_session;
+DROP INDEX IF EXISTS unique_user_exercise_submission;
+DELETE FROM UserExercisesDetails WHERE session_id IS NOT NULL;
+ALTER TABLE UserExercisesDetails
+ADD CONSTRAINT unique_user_exercise_submission UNIQUE (user_workout_id, exercise_id, submitted_at);
+ALTER TABLE UserExercisesDetails DROP COLUMN IF EXISTS session_id;
+DROP TABLE IF EXISTS WorkoutSessions;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- when the client says the exercise was performed, with its UTC offset in
+-- minutes when it sent one. submitted_at stays the local day of performed_at.
+ALTER TABLE UserExercisesDetails ADD COLUMN performed_at TIMESTAMPTZ;
+ALTER TABLE UserExercisesDetails ADD COLUMN performed_tz_offset INT;
+-- when the server received it, later than performed_at for offline logs
+ALTER TABLE UserExercisesDetails ADD COLUMN received_at TIMESTAMPTZ;
+
+UPDATE UserExercisesDetails
+SET performed_at = submitted_at::timestamp AT TIME ZONE 'UTC',
+    received_at = COALESCE(submitted_at::timestamp AT TIME ZONE 'UTC', NOW());
+
+ALTER TABLE UserExercisesDetails ALTER COLUMN received_at SET DEFAULT NOW();
+ALTER TABLE UserExercisesDetails ALTER COLUMN received_at SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE UserExercisesDetails DROP COLUMN IF EXISTS received_at;
+ALTER TABLE UserExercisesDetails DROP COLUMN IF EXISTS performed_tz_offset;
+ALTER TABLE UserExercisesDetails DROP COLUMN IF EXISTS performed_at;
+-- +goose StatementEnd
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE idempotency_keys (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    -- NULL until the request it reserves has completed
+    response_status INT,
+    response_body BYTEA,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMP,
+    UNIQUE (user_id, key)
+);
+
+-- keyed submissions are separate logs, only unkeyed ones still collapse into
+-- one row per exercise per day
+ALTER TABLE UserExercisesDetails ADD COLUMN idempotency_key VARCHAR(255);
+DROP INDEX IF EXISTS unique_user_exercise_submission;
+CREATE UNIQUE INDEX unique_user_exercise_submission
+    ON UserExercisesDetails (user_workout_id, exercise_id, submitted_at)
+    WHERE session_id IS NULL AND idempotency_key IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS unique_user_exercise_submission;
+DELETE FROM UserExercisesDetails WHERE idempotency_key IS NOT NULL;
+CREATE UNIQUE INDEX unique_user_exercise_submission
+    ON UserExercisesDetails (user_workout_id, exercise_id, submitted_at) WHERE session_id IS NULL;
+ALTER TABLE UserExercisesDetails DROP COLUMN IF EXISTS idempotency_key;
+DROP TABLE IF EXISTS idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- soft delete, a deleted entry can be restored for a while before it is purged
+ALTER TABLE UserExercisesDetails ADD COLUMN deleted_at TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM UserExercisesDetails WHERE deleted_at IS NOT NULL;
+ALTER TABLE UserExercisesDetails DROP COLUMN IF EXISTS deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE Exercises ADD COLUMN measurement_type VARCHAR(20) NOT NULL DEFAULT 'weighted'
+    CHECK (measurement_type IN ('weighted', 'bodyweight', 'bodyweight_added', 'assisted', 'duration', 'distance'));
+
+-- best guess for the seeded catalog, admins can correct it
+UPDATE Exercises SET measurement_type = 'bodyweight_added'
+WHERE name ILIKE 'weighted%' AND (name ILIKE '%dip%' OR name ILIKE '%pull%' OR name ILIKE '%chin%');
+UPDATE Exercises SET measurement_type = 'bodyweight'
+WHERE measurement_type = 'weighted'
+    AND (name ILIKE '%pullup%' OR name ILIKE '%pull-up%' OR name ILIKE '%chinup%' OR name ILIKE '%chin-up%'
+        OR name ILIKE '%push-up%' OR name ILIKE '%pushup%' OR name ILIKE '%hanging leg raise%');
+UPDATE Exercises SET measurement_type = 'assisted' WHERE name ILIKE 'assisted%';
+UPDATE Exercises SET measurement_type = 'duration' WHERE name ILIKE '%plank%' OR name ILIKE '%hold%';
+
+ALTER TABLE UserExerciseSets
+    ADD COLUMN duration_seconds INT CHECK (duration_seconds > 0),
+    ADD COLUMN distance_meters DOUBLE PRECISION CHECK (distance_meters > 0),
+    ADD COLUMN bodyweight DOUBLE PRECISION CHECK (bodyweight > 0);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE UserExerciseSets
+    DROP COLUMN IF EXISTS duration_seconds,
+    DROP COLUMN IF EXISTS distance_meters,
+    DROP COLUMN IF EXISTS bodyweight;
+ALTER TABLE Exercises DROP COLUMN IF EXISTS measurement_type;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- loads are stored in kg, preferred_unit is what responses are converted to.
+-- plate_increment is in the preferred unit, NULL is 2.5 kg or 5 lb.
+ALTER TABLE Users
+    ADD COLUMN preferred_unit VARCHAR(2) NOT NULL DEFAULT 'kg' CHECK (preferred_unit IN ('kg', 'lb')),
+    ADD COLUMN plate_increment DOUBLE PRECISION CHECK (plate_increment > 0);
+
+-- the unit a set's load was entered in, its load and bodyweight are kg
+ALTER TABLE UserExerciseSets
+    ADD COLUMN load_unit VARCHAR(2) NOT NULL DEFAULT 'kg' CHECK (load_unit IN ('kg', 'lb'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE UserExerciseSets DROP COLUMN IF EXISTS load_unit;
+ALTER TABLE Users
+    DROP COLUMN IF EXISTS preferred_unit,
+    DROP COLUMN IF EXISTS plate_increment;
+-- +goose StatementEnd
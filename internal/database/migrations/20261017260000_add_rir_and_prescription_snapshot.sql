@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- reps in reserve, kept in line with rpe (rpe = 10 - rir)
+ALTER TABLE UserExerciseSets ADD COLUMN rir DOUBLE PRECISION CHECK (rir BETWEEN 0 AND 9);
+UPDATE UserExerciseSets SET rir = 10 - rpe WHERE rpe IS NOT NULL;
+
+-- the prescription of the user's program week when the entry was logged, so
+-- later catalog edits don't change what the lifter was asked to do
+ALTER TABLE UserExercisesDetails
+    ADD COLUMN prescribed_reps VARCHAR(20),
+    ADD COLUMN prescribed_rpe VARCHAR;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE UserExercisesDetails
+    DROP COLUMN IF EXISTS prescribed_reps,
+    DROP COLUMN IF EXISTS prescribed_rpe;
+ALTER TABLE UserExerciseSets DROP COLUMN IF EXISTS rir;
+-- +goose StatementEnd
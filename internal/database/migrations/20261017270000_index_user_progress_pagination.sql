@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- performed_at is the progress sort key, every insert sets it so backfill
+-- what the earlier migration couldn't and require it
+UPDATE UserExercisesDetails SET performed_at = received_at WHERE performed_at IS NULL;
+ALTER TABLE UserExercisesDetails ALTER COLUMN performed_at SET NOT NULL;
+
+-- keyset pagination of GET /user/progress on (performed_at, id)
+CREATE INDEX idx_user_exercises_details_progress
+    ON UserExercisesDetails (user_workout_id, performed_at, id)
+    WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_user_exercises_details_progress;
+ALTER TABLE UserExercisesDetails ALTER COLUMN performed_at DROP NOT NULL;
+-- +goose StatementEnd
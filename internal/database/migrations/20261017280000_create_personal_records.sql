@@ -0,0 +1,68 @@
+-- +goose Up
+-- +goose StatementBegin
+-- every personal record a user set, in kg. The current record of a kind is
+-- the best one whose entry isn't deleted. reps is the rep count of a rep_max
+-- and 0 for the other kinds.
+CREATE TABLE PersonalRecords (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    exercise_id INT NOT NULL REFERENCES Exercises(id) ON DELETE CASCADE,
+    user_exercise_detail_id INT NOT NULL REFERENCES UserExercisesDetails(id) ON DELETE CASCADE,
+    record_type VARCHAR(20) NOT NULL CHECK (record_type IN ('max_load', 'rep_max', 'e1rm', 'session_volume')),
+    reps INT NOT NULL DEFAULT 0 CHECK (reps >= 0),
+    value DOUBLE PRECISION NOT NULL CHECK (value > 0),
+    previous_value DOUBLE PRECISION,
+    achieved_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_personal_records_exercise ON PersonalRecords (user_id, exercise_id, record_type, reps);
+CREATE INDEX idx_personal_records_entry ON PersonalRecords (user_exercise_detail_id);
+
+-- seed the current records from what was logged so far, mirrors
+-- training.RecordCandidates so the next log isn't a record by default
+WITH effective_sets AS (
+    SELECT uw.user_id, ued.exercise_id, ued.id AS detail_id, ued.performed_at, s.reps,
+        CASE e.measurement_type
+            WHEN 'weighted' THEN s.load
+            WHEN 'bodyweight' THEN s.bodyweight + s.load
+            WHEN 'bodyweight_added' THEN s.bodyweight + s.load
+            WHEN 'assisted' THEN GREATEST(s.bodyweight - s.load, 0)
+        END AS load
+    FROM UserExerciseSets s
+    JOIN UserExercisesDetails ued ON ued.id = s.user_exercise_detail_id
+    JOIN UserWorkouts uw ON uw.id = ued.user_workout_id
+    JOIN Exercises e ON e.id = ued.exercise_id
+    WHERE ued.deleted_at IS NULL AND s.set_type <> 'warmup' AND s.reps > 0
+), candidates AS (
+    SELECT user_id, exercise_id, detail_id, performed_at, 'max_load' AS record_type, 0 AS reps, MAX(load) AS value
+    FROM effective_sets
+    GROUP BY user_id, exercise_id, detail_id, performed_at
+    UNION ALL
+    SELECT user_id, exercise_id, detail_id, performed_at, 'rep_max', reps, MAX(load)
+    FROM effective_sets
+    WHERE reps <= 12
+    GROUP BY user_id, exercise_id, detail_id, performed_at, reps
+    UNION ALL
+    SELECT user_id, exercise_id, detail_id, performed_at, 'e1rm', 0,
+        MAX(CASE WHEN reps = 1 THEN load ELSE load * (1 + reps / 30.0) END)
+    FROM effective_sets
+    WHERE reps <= 12
+    GROUP BY user_id, exercise_id, detail_id, performed_at
+    UNION ALL
+    SELECT user_id, exercise_id, detail_id, performed_at, 'session_volume', 0, SUM(reps * load)
+    FROM effective_sets
+    GROUP BY user_id, exercise_id, detail_id, performed_at
+)
+INSERT INTO PersonalRecords (user_id, exercise_id, user_exercise_detail_id, record_type, reps, value, achieved_at)
+SELECT DISTINCT ON (user_id, exercise_id, record_type, reps)
+    user_id, exercise_id, detail_id, record_type, reps, value, performed_at
+FROM candidates
+WHERE value > 0
+ORDER BY user_id, exercise_id, record_type, reps, value DESC, performed_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS PersonalRecords;
+-- +goose StatementEnd
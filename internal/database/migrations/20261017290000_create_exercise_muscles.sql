@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the muscles an exercise trains, secondary ones count as half a set in
+-- volume summaries
+CREATE TABLE ExerciseMuscles (
+    exercise_id INT NOT NULL REFERENCES Exercises(id) ON DELETE CASCADE,
+    muscle VARCHAR(20) NOT NULL CHECK (muscle IN ('chest', 'back', 'shoulders', 'biceps', 'triceps', 'forearms',
+        'abs', 'quads', 'hamstrings', 'glutes', 'calves')),
+    role VARCHAR(10) NOT NULL CHECK (role IN ('primary', 'secondary')),
+    PRIMARY KEY (exercise_id, muscle)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ExerciseMuscles;
+-- +goose StatementEnd
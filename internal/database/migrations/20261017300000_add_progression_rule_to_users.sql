@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- how next-session recommendations progress the user's exercises, set by the
+-- user, their coach or an admin
+ALTER TABLE Users ADD COLUMN progression_rule VARCHAR(20) NOT NULL DEFAULT 'double_progression'
+    CHECK (progression_rule IN ('double_progression', 'rpe'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE Users DROP COLUMN IF EXISTS progression_rule;
+-- +goose StatementEnd
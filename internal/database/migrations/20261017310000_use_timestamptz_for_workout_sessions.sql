@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- session times were written with NOW() in the server's zone, which is how
+-- the conversion reads them
+ALTER TABLE WorkoutSessions
+    ALTER COLUMN started_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN finished_at TYPE TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE WorkoutSessions
+    ALTER COLUMN started_at TYPE TIMESTAMP,
+    ALTER COLUMN finished_at TYPE TIMESTAMP;
+-- +goose StatementEnd
@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+-- sets logged in workout sessions didn't set personal records, add the ones
+-- their entries beat. Mirrors training.RecordCandidates like the
+-- PersonalRecords migration.
+WITH effective_sets AS (
+    SELECT uw.user_id, ued.exercise_id, ued.id AS detail_id, ued.performed_at, s.reps,
+        CASE e.measurement_type
+            WHEN 'weighted' THEN s.load
+            WHEN 'bodyweight' THEN s.bodyweight + s.load
+            WHEN 'bodyweight_added' THEN s.bodyweight + s.load
+            WHEN 'assisted' THEN GREATEST(s.bodyweight - s.load, 0)
+        END AS load
+    FROM UserExerciseSets s
+    JOIN UserExercisesDetails ued ON ued.id = s.user_exercise_detail_id
+    JOIN UserWorkouts uw ON uw.id = ued.user_workout_id
+    JOIN Exercises e ON e.id = ued.exercise_id
+    WHERE ued.deleted_at IS NULL AND ued.session_id IS NOT NULL AND s.set_type <> 'warmup' AND s.reps > 0
+        AND NOT EXISTS (SELECT 1 FROM PersonalRecords pr WHERE pr.user_exercise_detail_id = ued.id)
+), candidates AS (
+    SELECT user_id, exercise_id, detail_id, performed_at, 'max_load' AS record_type, 0 AS reps, MAX(load) AS value
+    FROM effective_sets
+    GROUP BY user_id, exercise_id, detail_id, performed_at
+    UNION ALL
+    SELECT user_id, exercise_id, detail_id, performed_at, 'rep_max', reps, MAX(load)
+    FROM effective_sets
+    WHERE reps <= 12
+    GROUP BY user_id, exercise_id, detail_id, performed_at, reps
+    UNION ALL
+    SELECT user_id, exercise_id, detail_id, performed_at, 'e1rm', 0,
+        MAX(CASE WHEN reps = 1 THEN load ELSE load * (1 + reps / 30.0) END)
+    FROM effective_sets
+    WHERE reps <= 12
+    GROUP BY user_id, exercise_id, detail_id, performed_at
+    UNION ALL
+    SELECT user_id, exercise_id, detail_id, performed_at, 'session_volume', 0, SUM(reps * load)
+    FROM effective_sets
+    GROUP BY user_id, exercise_id, detail_id, performed_at
+), best AS (
+    SELECT DISTINCT ON (user_id, exercise_id, record_type, reps) *
+    FROM candidates
+    WHERE value > 0
+    ORDER BY user_id, exercise_id, record_type, reps, value DESC, performed_at
+), current AS (
+    SELECT pr.user_id, pr.exercise_id, pr.record_type, pr.reps, MAX(pr.value) AS value
+    FROM PersonalRecords pr
+    JOIN UserExercisesDetails ued ON ued.id = pr.user_exercise_detail_id
+    WHERE ued.deleted_at IS NULL
+    GROUP BY pr.user_id, pr.exercise_id, pr.record_type, pr.reps
+)
+INSERT INTO PersonalRecords (user_id, exercise_id, user_exercise_detail_id, record_type, reps, value,
+    previous_value, achieved_at)
+SELECT b.user_id, b.exercise_id, b.detail_id, b.record_type, b.reps, b.value, c.value, b.performed_at
+FROM best b
+LEFT JOIN current c USING (user_id, exercise_id, record_type, reps)
+WHERE c.value IS NULL OR b.value > c.value + 1e-6;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- the backfilled records are kept, they are records the sets did set
+SELECT 1;
+-- +goose StatementEnd
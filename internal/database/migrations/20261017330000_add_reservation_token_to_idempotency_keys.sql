@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- identifies the request holding a key, a new one is set when a retry takes
+-- over a reservation whose request never finished
+ALTER TABLE idempotency_keys ADD COLUMN reservation_token UUID;
+UPDATE idempotency_keys SET reservation_token = md5(id::text || clock_timestamp()::text)::uuid;
+ALTER TABLE idempotency_keys ALTER COLUMN reservation_token SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE idempotency_keys DROP COLUMN IF EXISTS reservation_token;
+-- +goose StatementEnd
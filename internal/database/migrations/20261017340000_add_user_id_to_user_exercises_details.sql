@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+-- GET /user/progress lists a user's entries, keep the user on the entry so
+-- the pagination index can lead with it instead of user_workout_id
+ALTER TABLE UserExercisesDetails ADD COLUMN user_id INT;
+UPDATE UserExercisesDetails ued
+SET user_id = uw.user_id
+FROM UserWorkouts uw
+WHERE uw.id = ued.user_workout_id;
+ALTER TABLE UserExercisesDetails ALTER COLUMN user_id SET NOT NULL;
+
+-- the user of an entry is always the user of its workout
+ALTER TABLE UserWorkouts ADD CONSTRAINT unique_user_workout_user UNIQUE (id, user_id);
+ALTER TABLE UserExercisesDetails ADD CONSTRAINT fk_user_exercises_details_workout_user
+    FOREIGN KEY (user_workout_id, user_id) REFERENCES UserWorkouts (id, user_id);
+
+-- keyset pagination of GET /user/progress on (performed_at, id) per user
+DROP INDEX IF EXISTS idx_user_exercises_details_progress;
+CREATE INDEX idx_user_exercises_details_progress
+    ON UserExercisesDetails (user_id, performed_at, id)
+    WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_user_exercises_details_progress;
+CREATE INDEX idx_user_exercises_details_progress
+    ON UserExercisesDetails (user_workout_id, performed_at, id)
+    WHERE deleted_at IS NULL;
+ALTER TABLE UserExercisesDetails DROP CONSTRAINT IF EXISTS fk_user_exercises_details_workout_user;
+ALTER TABLE UserWorkouts DROP CONSTRAINT IF EXISTS unique_user_workout_user;
+ALTER TABLE UserExercisesDetails DROP COLUMN IF EXISTS user_id;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the users a coach coaches, a coach may set their athletes' progression rule
+CREATE TABLE CoachAthletes (
+    coach_id INT NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    athlete_id INT NOT NULL REFERENCES Users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (coach_id, athlete_id),
+    CHECK (coach_id <> athlete_id)
+);
+CREATE INDEX idx_coach_athletes_athlete ON CoachAthletes (athlete_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS CoachAthletes;
+-- +goose StatementEnd
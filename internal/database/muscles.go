@@ -0,0 +1,114 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// muscle roles stored in ExerciseMuscles.role
+const (
+	muscleRolePrimary   = "primary"
+	muscleRoleSecondary = "secondary"
+)
+
+// returns the muscles of an exercise, empty lists when it has none
+func GetExerciseMuscles(exerciseID int) (models.ExerciseMuscles, error) {
+	muscles, err := ListExerciseMuscles([]int{exerciseID})
+	if err != nil {
+		return models.ExerciseMuscles{}, err
+	}
+	return muscles[exerciseID], nil
+}
+
+// returns the muscles of each of exerciseIDs, empty lists for exercises that
+// have none
+func ListExerciseMuscles(exerciseIDs []int) (map[int]models.ExerciseMuscles, error) {
+	muscles := make(map[int]models.ExerciseMuscles, len(exerciseIDs))
+	for _, id := range exerciseIDs {
+		muscles[id] = models.ExerciseMuscles{Primary: []string{}, Secondary: []string{}}
+	}
+	if len(exerciseIDs) == 0 {
+		return muscles, nil
+	}
+
+	rows, err := DB.Query(`
+		SELECT exercise_id, muscle, role
+		FROM ExerciseMuscles
+		WHERE exercise_id = ANY($1)
+		ORDER BY exercise_id, muscle
+	`, pq.Array(exerciseIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query exercise muscles: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	for rows.Next() {
+		var exerciseID int
+		var muscle, role string
+		if err := rows.Scan(&exerciseID, &muscle, &role); err != nil {
+			return nil, fmt.Errorf("failed to scan exercise muscle: %w", err)
+		}
+		exercise := muscles[exerciseID]
+		if role == muscleRolePrimary {
+			exercise.Primary = append(exercise.Primary, muscle)
+		} else {
+			exercise.Secondary = append(exercise.Secondary, muscle)
+		}
+		muscles[exerciseID] = exercise
+	}
+	return muscles, rows.Err()
+}
+
+// replaces all muscles of an exercise
+func ReplaceExerciseMuscles(exerciseID int, muscles models.ExerciseMuscles) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	// lock the exercise so concurrent edits don't interleave
+	var id int
+	err = tx.QueryRow(`SELECT id FROM Exercises WHERE id = $1 FOR UPDATE`, exerciseID).Scan(&id)
+	if err == sql.ErrNoRows {
+		rollback(tx)
+		return ErrExerciseNotFound
+	}
+	if err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to lock exercise: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM ExerciseMuscles WHERE exercise_id = $1`, exerciseID); err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to clear exercise muscles: %w", err)
+	}
+
+	for role, names := range map[string][]string{
+		muscleRolePrimary:   muscles.Primary,
+		muscleRoleSecondary: muscles.Secondary,
+	} {
+		if len(names) == 0 {
+			continue
+		}
+		_, err := tx.Exec(`
+			INSERT INTO ExerciseMuscles (exercise_id, muscle, role)
+			SELECT $1, muscle, $2
+			FROM unnest($3::text[]) AS muscle
+		`, exerciseID, role, pq.Array(names))
+		if err != nil {
+			rollback(tx)
+			return fmt.Errorf("failed to insert exercise muscles: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// records closer than this are equal, not a new record
+const recordTolerance = 1e-6
+
+// stores the candidates that beat the user's current record of their kind
+// and returns them with the record they beat. The records entryIDs set
+// before are dropped first, their sets were replaced. Callers hold the
+// entries' UserWorkouts row lock, so concurrent logs of an exercise can't
+// both set the same record.
+func RecordPersonalRecords(tx *sql.Tx, userID int, entryIDs []int, candidates []models.PersonalRecord) ([]models.PersonalRecord, error) {
+	if _, err := tx.Exec(`
+		DELETE FROM PersonalRecords WHERE user_exercise_detail_id = ANY($1)
+	`, pq.Array(entryIDs)); err != nil {
+		return nil, fmt.Errorf("failed to drop replaced personal records: %w", err)
+	}
+
+	records := []models.PersonalRecord{}
+	if len(candidates) == 0 {
+		return records, nil
+	}
+	var exerciseIDs []int
+	for _, candidate := range candidates {
+		exerciseIDs = append(exerciseIDs, candidate.ExerciseID)
+	}
+
+	type recordKey struct {
+		exerciseID int
+		recordType string
+		reps       int
+	}
+	best := map[recordKey]float64{}
+	rows, err := tx.Query(`
+		SELECT pr.exercise_id, pr.record_type, pr.reps, MAX(pr.value)
+		FROM PersonalRecords pr
+		JOIN UserExercisesDetails ued ON ued.id = pr.user_exercise_detail_id
+		WHERE pr.user_id = $1 AND pr.exercise_id = ANY($2) AND ued.deleted_at IS NULL
+		GROUP BY pr.exercise_id, pr.record_type, pr.reps
+	`, userID, pq.Array(exerciseIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query personal records: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+	for rows.Next() {
+		var key recordKey
+		var value float64
+		if err := rows.Scan(&key.exerciseID, &key.recordType, &key.reps, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan personal record: %w", err)
+		}
+		best[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		key := recordKey{candidate.ExerciseID, candidate.Type, candidate.Reps}
+		previous, ok := best[key]
+		if ok && candidate.Value <= previous+recordTolerance {
+			continue
+		}
+		if ok {
+			candidate.PreviousValue = &previous
+		}
+		err := tx.QueryRow(`
+			INSERT INTO PersonalRecords (user_id, exercise_id, user_exercise_detail_id, record_type, reps, value,
+				previous_value, achieved_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			RETURNING id
+		`, userID, candidate.ExerciseID, candidate.EntryID, candidate.Type, candidate.Reps, candidate.Value,
+			candidate.PreviousValue, candidate.AchievedAt).Scan(&candidate.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert personal record: %w", err)
+		}
+		// two entries of a submission can't both set the same record
+		best[key] = candidate.Value
+		records = append(records, candidate)
+	}
+	return records, nil
+}
+
+// returns every record the user's logs of an exercise set, newest first.
+// Records of deleted entries are left out.
+func ListPersonalRecords(userID, exerciseID int) ([]models.PersonalRecord, error) {
+	rows, err := DB.Query(`
+		SELECT pr.id, pr.exercise_id, pr.user_exercise_detail_id, pr.record_type, pr.reps, pr.value,
+			pr.previous_value, pr.achieved_at
+		FROM PersonalRecords pr
+		JOIN UserExercisesDetails ued ON ued.id = pr.user_exercise_detail_id
+		WHERE pr.user_id = $1 AND pr.exercise_id = $2 AND ued.deleted_at IS NULL
+		ORDER BY pr.achieved_at DESC, pr.id DESC
+	`, userID, exerciseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query personal records: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	records := []models.PersonalRecord{}
+	for rows.Next() {
+		var record models.PersonalRecord
+		var previous sql.NullFloat64
+		if err := rows.Scan(
+			&record.ID, &record.ExerciseID, &record.EntryID, &record.Type, &record.Reps, &record.Value,
+			&previous, &record.AchievedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan personal record: %w", err)
+		}
+		if previous.Valid {
+			record.PreviousValue = &previous.Float64
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/training"
+)
+
+// returns the user's unit, plate increment (the unit's default when none is
+// set) and progression rule
+func GetUserPreferences(userID int) (models.UserPreferences, error) {
+	var (
+		preferences    models.UserPreferences
+		plateIncrement sql.NullFloat64
+	)
+	err := DB.QueryRow(`
+		SELECT preferred_unit, plate_increment, progression_rule FROM Users WHERE id = $1
+	`, userID).Scan(&preferences.Unit, &plateIncrement, &preferences.ProgressionRule)
+	if err == sql.ErrNoRows {
+		return models.UserPreferences{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.UserPreferences{}, fmt.Errorf("failed to query user preferences: %w", err)
+	}
+
+	preferences.PlateIncrement = training.DefaultPlateIncrement(preferences.Unit)
+	if plateIncrement.Valid {
+		preferences.PlateIncrement = plateIncrement.Float64
+	}
+	return preferences, nil
+}
+
+// updates the given preferences and returns all of them
+func UpdateUserPreferences(userID int, req models.UpdatePreferencesRequest) (models.UserPreferences, error) {
+	result, err := DB.Exec(`
+		UPDATE Users
+		SET preferred_unit = COALESCE($2, preferred_unit),
+			plate_increment = CASE
+				WHEN $3::double precision IS NOT NULL THEN $3::double precision
+				WHEN $2::varchar IS NOT NULL AND $2::varchar <> preferred_unit THEN NULL
+				ELSE plate_increment
+			END,
+			progression_rule = COALESCE($4, progression_rule)
+		WHERE id = $1
+	`, userID, req.Unit, req.PlateIncrement, req.ProgressionRule)
+	if err != nil {
+		return models.UserPreferences{}, fmt.Errorf("failed to update user preferences: %w", err)
+	}
+	if err := expectOneRow(result, ErrUserNotFound); err != nil {
+		return models.UserPreferences{}, err
+	}
+	return GetUserPreferences(userID)
+}
@@ -0,0 +1,440 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrProgramNotFound  = errors.New("program not found")
+	ErrNotEnrolled      = errors.New("user is not enrolled in a program")
+	ErrAlreadyEnrolled  = errors.New("user is already enrolled in a program")
+	ErrProgramNotActive = errors.New("program is not active")
+	ErrProgramNotPaused = errors.New("program is not paused")
+)
+
+// dateLayout is how program dates are sent and returned
+const dateLayout = "2006-01-02"
+
+// lists all programs
+func ListPrograms() ([]models.Program, error) {
+	rows, err := DB.Query(`
+		SELECT id, name, COALESCE(description, ''), length_weeks
+		FROM Programs
+		ORDER BY id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query programs: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	programs := []models.Program{}
+	for rows.Next() {
+		var program models.Program
+		if err := rows.Scan(&program.ID, &program.Name, &program.Description, &program.LengthWeeks); err != nil {
+			return nil, fmt.Errorf("failed to scan program: %w", err)
+		}
+		programs = append(programs, program)
+	}
+	return programs, rows.Err()
+}
+
+// creates a program, sections are assigned to it through the section endpoints
+func CreateProgram(input models.ProgramInput) (models.Program, error) {
+	var program models.Program
+	err := DB.QueryRow(`
+		INSERT INTO Programs (name, description, length_weeks)
+		VALUES ($1, $2, $3)
+		RETURNING id, name, COALESCE(description, ''), length_weeks
+	`, input.Name, input.Description, input.LengthWeeks).Scan(
+		&program.ID, &program.Name, &program.Description, &program.LengthWeeks,
+	)
+	if err != nil {
+		return models.Program{}, fmt.Errorf("failed to create program: %w", err)
+	}
+	return program, nil
+}
+
+// columns of an enrollment, selected from user_programs up joined with Programs p
+const enrollmentColumns = `up.id, p.id, p.name, COALESCE(p.description, ''), p.length_weeks,
+	up.status, up.start_date, up.paused_at, up.paused_days, up.ended_at, COALESCE(up.end_reason, '')`
+
+// returns the user's active or paused enrollment with its schedule and the
+// sessions due today
+func GetUserProgram(userID int) (models.UserProgram, error) {
+	row := DB.QueryRow(`
+		SELECT `+enrollmentColumns+`
+		FROM user_programs up
+		JOIN Programs p ON p.id = up.program_id
+		WHERE up.user_id = $1 AND up.status <> 'ended'
+	`, userID)
+	userProgram, err := scanEnrollment(row, time.Now().UTC())
+	if err == sql.ErrNoRows {
+		return models.UserProgram{}, ErrNotEnrolled
+	}
+	if err != nil {
+		return models.UserProgram{}, fmt.Errorf("failed to query user program: %w", err)
+	}
+
+	schedule, err := getSchedule(userID, userProgram.Program.ID)
+	if err != nil {
+		return models.UserProgram{}, err
+	}
+	userProgram.Schedule = schedule
+
+	if userProgram.Status == models.ProgramStatusActive && userProgram.CurrentDay > 0 && !userProgram.Completed {
+		for _, session := range schedule {
+			if session.Day == userProgram.CurrentDay {
+				userProgram.DueToday = append(userProgram.DueToday, session)
+			}
+		}
+	}
+	return userProgram, nil
+}
+
+// lists all enrollments of the user, newest first
+func ListUserPrograms(userID int) ([]models.UserProgram, error) {
+	rows, err := DB.Query(`
+		SELECT `+enrollmentColumns+`
+		FROM user_programs up
+		JOIN Programs p ON p.id = up.program_id
+		WHERE up.user_id = $1
+		ORDER BY up.created_at DESC, up.id DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query program history: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	now := time.Now().UTC()
+	history := []models.UserProgram{}
+	for rows.Next() {
+		userProgram, err := scanEnrollment(rows, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan program history: %w", err)
+		}
+		history = append(history, userProgram)
+	}
+	return history, rows.Err()
+}
+
+// enrolls a user who doesn't follow a program yet
+func StartProgram(userID, programID int, startDate time.Time, trainingDays []int) (models.UserProgram, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return models.UserProgram{}, err
+	}
+	if err := startEnrollment(tx, userID, programID, startDate, trainingDays, true); err != nil {
+		rollback(tx)
+		return models.UserProgram{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.UserProgram{}, err
+	}
+	return GetUserProgram(userID)
+}
+
+// pauses the active enrollment, its calendar stands still until resumed
+func PauseProgram(userID int) (models.UserProgram, error) {
+	return transitionProgram(userID, func(tx *sql.Tx, current currentEnrollment, now time.Time) error {
+		if current.status != models.ProgramStatusActive {
+			return ErrProgramNotActive
+		}
+		_, err := tx.Exec(`
+			UPDATE user_programs
+			SET status = 'paused', paused_at = $2, updated_at = NOW()
+			WHERE id = $1
+		`, current.id, now)
+		return err
+	})
+}
+
+// resumes a paused enrollment, shifting its calendar by the days spent paused
+func ResumeProgram(userID int) (models.UserProgram, error) {
+	return transitionProgram(userID, func(tx *sql.Tx, current currentEnrollment, now time.Time) error {
+		if current.status != models.ProgramStatusPaused {
+			return ErrProgramNotPaused
+		}
+		_, err := tx.Exec(`
+			UPDATE user_programs
+			SET status = 'active', paused_at = NULL, paused_days = paused_days + $2, updated_at = NOW()
+			WHERE id = $1
+		`, current.id, current.pausedDaysSince(now))
+		return err
+	})
+}
+
+// ends the current enrollment and starts its program again from week 1. The
+// schedule is kept unless trainingDays is set.
+func RestartProgram(userID int, startDate time.Time, trainingDays []int) (models.UserProgram, error) {
+	return transitionProgram(userID, func(tx *sql.Tx, current currentEnrollment, now time.Time) error {
+		if err := endEnrollment(tx, current, now, "restarted"); err != nil {
+			return err
+		}
+		return startEnrollment(tx, userID, current.programID, startDate, trainingDays, len(trainingDays) > 0)
+	})
+}
+
+// ends the current enrollment and starts another program
+func SwitchProgram(userID, programID int, startDate time.Time, trainingDays []int) (models.UserProgram, error) {
+	return transitionProgram(userID, func(tx *sql.Tx, current currentEnrollment, now time.Time) error {
+		if err := endEnrollment(tx, current, now, "switched"); err != nil {
+			return err
+		}
+		return startEnrollment(tx, userID, programID, startDate, trainingDays, true)
+	})
+}
+
+// returns the user's current week in the program of a section, week 1 when
+// they don't follow that program
+func GetSectionWeek(userID, sectionID int) (int, error) {
+	row := DB.QueryRow(`
+		SELECT `+enrollmentColumns+`
+		FROM WorkoutSections ws
+		JOIN user_programs up ON up.program_id = ws.program_id AND up.user_id = $1 AND up.status <> 'ended'
+		JOIN Programs p ON p.id = up.program_id
+		WHERE ws.id = $2
+	`, userID, sectionID)
+	userProgram, err := scanEnrollment(row, time.Now().UTC())
+	if err == sql.ErrNoRows {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to query section week: %w", err)
+	}
+	return userProgram.CurrentWeek, nil
+}
+
+// ProgramPosition is the 1-based week and day of a program started on
+// startDate, not counting pausedDays. Day is 0 before the start date. After the
+// last week it stays on the last day and completed is true.
+func ProgramPosition(startDate, at time.Time, pausedDays, lengthWeeks int) (week, day int, completed bool) {
+	days := daysBetween(startDate, at) - pausedDays
+	if days < 0 {
+		return 1, 0, false
+	}
+	if lengthWeeks > 0 && days >= lengthWeeks*7 {
+		return lengthWeeks, 7, true
+	}
+	return days/7 + 1, days%7 + 1, false
+}
+
+// ScheduleDays assigns each of n sections, in order, a day of the program
+// week. Sections cycle through trainingDays, or are spread evenly over the
+// week when none are given.
+func ScheduleDays(n int, trainingDays []int) []int {
+	days := make([]int, n)
+	for i := range days {
+		if len(trainingDays) > 0 {
+			days[i] = trainingDays[i%len(trainingDays)]
+		} else {
+			days[i] = 1 + i*7/n
+		}
+	}
+	return days
+}
+
+// whole calendar days from one date to another, ignoring the time of day
+func daysBetween(from, to time.Time) int {
+	fromDate := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDate := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDate.Sub(fromDate).Hours() / 24)
+}
+
+func scanEnrollment(row rowScanner, now time.Time) (models.UserProgram, error) {
+	var (
+		userProgram       models.UserProgram
+		startDate         time.Time
+		pausedAt, endedAt sql.NullTime
+	)
+	err := row.Scan(
+		&userProgram.ID, &userProgram.Program.ID, &userProgram.Program.Name, &userProgram.Program.Description,
+		&userProgram.Program.LengthWeeks, &userProgram.Status, &startDate, &pausedAt, &userProgram.PausedDays,
+		&endedAt, &userProgram.EndReason,
+	)
+	if err != nil {
+		return models.UserProgram{}, err
+	}
+
+	// the calendar stands still while paused and once ended
+	at := now
+	if pausedAt.Valid {
+		userProgram.PausedAt = &pausedAt.Time
+		at = pausedAt.Time
+	}
+	if endedAt.Valid {
+		userProgram.EndedAt = &endedAt.Time
+		at = endedAt.Time
+	}
+
+	userProgram.StartDate = startDate.Format(dateLayout)
+	userProgram.CurrentWeek, userProgram.CurrentDay, userProgram.Completed =
+		ProgramPosition(startDate, at, userProgram.PausedDays, userProgram.Program.LengthWeeks)
+	return userProgram, nil
+}
+
+// the sections of a program on the user's schedule, by day
+func getSchedule(userID, programID int) ([]models.ScheduledSession, error) {
+	rows, err := DB.Query(`
+		SELECT ws.id, ws.name, COALESCE(ws.route, ''), uw.day_of_week
+		FROM UserWorkouts uw
+		JOIN WorkoutSections ws ON ws.id = uw.section_id
+		WHERE uw.user_id = $1 AND ws.program_id = $2 AND uw.day_of_week IS NOT NULL
+		ORDER BY uw.day_of_week, ws.sort_order, ws.id
+	`, userID, programID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedule: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	schedule := []models.ScheduledSession{}
+	for rows.Next() {
+		var session models.ScheduledSession
+		if err := rows.Scan(&session.SectionID, &session.Name, &session.Route, &session.Day); err != nil {
+			return nil, fmt.Errorf("failed to scan schedule: %w", err)
+		}
+		schedule = append(schedule, session)
+	}
+	return schedule, rows.Err()
+}
+
+// the user's active or paused enrollment, locked for a transition
+type currentEnrollment struct {
+	id        int
+	programID int
+	status    string
+	pausedAt  sql.NullTime
+}
+
+// whole days paused so far, 0 when not paused
+func (c currentEnrollment) pausedDaysSince(now time.Time) int {
+	if c.status != models.ProgramStatusPaused || !c.pausedAt.Valid {
+		return 0
+	}
+	return daysBetween(c.pausedAt.Time, now)
+}
+
+// locks the current enrollment, runs the transition and returns the result
+func transitionProgram(userID int, transition func(tx *sql.Tx, current currentEnrollment, now time.Time) error) (models.UserProgram, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return models.UserProgram{}, err
+	}
+
+	var current currentEnrollment
+	err = tx.QueryRow(`
+		SELECT id, program_id, status, paused_at
+		FROM user_programs
+		WHERE user_id = $1 AND status <> 'ended'
+		FOR UPDATE
+	`, userID).Scan(&current.id, &current.programID, &current.status, &current.pausedAt)
+	if err == sql.ErrNoRows {
+		rollback(tx)
+		return models.UserProgram{}, ErrNotEnrolled
+	}
+	if err != nil {
+		rollback(tx)
+		return models.UserProgram{}, fmt.Errorf("failed to lock user program: %w", err)
+	}
+
+	if err := transition(tx, current, time.Now().UTC()); err != nil {
+		rollback(tx)
+		return models.UserProgram{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.UserProgram{}, err
+	}
+	return GetUserProgram(userID)
+}
+
+// ends an enrollment, a pause still running counts towards its paused days
+func endEnrollment(tx *sql.Tx, current currentEnrollment, now time.Time, reason string) error {
+	_, err := tx.Exec(`
+		UPDATE user_programs
+		SET status = 'ended', ended_at = $2, end_reason = $3,
+			paused_at = NULL, paused_days = paused_days + $4, updated_at = NOW()
+		WHERE id = $1
+	`, current.id, now, reason, current.pausedDaysSince(now))
+	if err != nil {
+		return fmt.Errorf("failed to end user program: %w", err)
+	}
+	return nil
+}
+
+// inserts a new enrollment and, with writeSchedule, puts the program's
+// sections on the user's schedule in UserWorkouts.day_of_week
+func startEnrollment(tx *sql.Tx, userID, programID int, startDate time.Time, trainingDays []int, writeSchedule bool) error {
+	_, err := tx.Exec(`
+		INSERT INTO user_programs (user_id, program_id, start_date)
+		VALUES ($1, $2, $3)
+	`, userID, programID, startDate.Format(dateLayout))
+	switch pqErrorCode(err) {
+	case pqUniqueViolation:
+		return ErrAlreadyEnrolled
+	case pqForeignKeyViolation:
+		return ErrProgramNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enroll user: %w", err)
+	}
+	if !writeSchedule {
+		return nil
+	}
+
+	rows, err := tx.Query(`SELECT id FROM WorkoutSections WHERE program_id = $1 ORDER BY sort_order, id`, programID)
+	if err != nil {
+		return fmt.Errorf("failed to query program sections: %w", err)
+	}
+	var sectionIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			if closeErr := rows.Close(); closeErr != nil {
+				utils.Logger.Error("Failed to close rows", zap.Error(closeErr))
+			}
+			return fmt.Errorf("failed to scan program section: %w", err)
+		}
+		sectionIDs = append(sectionIDs, id)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	// sections of other programs drop off the schedule, their logs stay
+	if _, err := tx.Exec(`UPDATE UserWorkouts SET day_of_week = NULL WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to clear schedule: %w", err)
+	}
+	if len(sectionIDs) == 0 {
+		return nil
+	}
+	_, err = tx.Exec(`
+		INSERT INTO UserWorkouts (user_id, section_id, day_of_week)
+		SELECT $1, s.section_id, s.day
+		FROM unnest($2::int[], $3::int[]) AS s(section_id, day)
+		ON CONFLICT (user_id, section_id) DO UPDATE
+		SET day_of_week = EXCLUDED.day_of_week
+	`, userID, pq.Array(sectionIDs), pq.Array(ScheduleDays(len(sectionIDs), trainingDays)))
+	if err != nil {
+		return fmt.Errorf("failed to write schedule: %w", err)
+	}
+	return nil
+}
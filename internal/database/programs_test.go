@@ -0,0 +1,58 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProgramPosition(t *testing.T) {
+	start := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		at            time.Time
+		pausedDays    int
+		wantWeek      int
+		wantDay       int
+		wantCompleted bool
+	}{
+		{"before start", start.AddDate(0, 0, -3), 0, 1, 0, false},
+		{"start day", start, 0, 1, 1, false},
+		{"last day of week 1", start.AddDate(0, 0, 6).Add(23 * time.Hour), 0, 1, 7, false},
+		{"first day of week 2", start.AddDate(0, 0, 7), 0, 2, 1, false},
+		{"week 8", start.AddDate(0, 0, 7*7+2), 0, 8, 3, false},
+		{"paused days shift the calendar", start.AddDate(0, 0, 7), 3, 1, 5, false},
+		{"after the last week", start.AddDate(0, 0, 7*20), 0, 12, 7, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			week, day, completed := ProgramPosition(start, tt.at, tt.pausedDays, 12)
+			if week != tt.wantWeek || day != tt.wantDay || completed != tt.wantCompleted {
+				t.Fatalf("ProgramPosition() = (%d, %d, %v), want (%d, %d, %v)",
+					week, day, completed, tt.wantWeek, tt.wantDay, tt.wantCompleted)
+			}
+		})
+	}
+}
+
+func TestScheduleDays(t *testing.T) {
+	tests := []struct {
+		name         string
+		n            int
+		trainingDays []int
+		want         []int
+	}{
+		{"even spread of 4", 4, nil, []int{1, 2, 4, 6}},
+		{"even spread of 3", 3, nil, []int{1, 3, 5}},
+		{"training days", 3, []int{1, 3, 5}, []int{1, 3, 5}},
+		{"more sections than days", 4, []int{2, 6}, []int{2, 6, 2, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ScheduleDays(tt.n, tt.trainingDays); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ScheduleDays() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
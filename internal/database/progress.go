@@ -0,0 +1,192 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// returns a page of the user's logged entries with their sets, ordered by
+// performed_at then id. more reports whether entries follow the page, a
+// zero filter.Limit returns every entry.
+func ListProgress(userID int, filter models.ProgressFilter) (entries []models.UserProgressResponse, more bool, err error) {
+	// ued.user_id leads the pagination index, see its migration
+	conditions := []string{"ued.user_id = $1", "ued.deleted_at IS NULL"}
+	args := []interface{}{userID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.ExerciseID != 0 {
+		conditions = append(conditions, "ued.exercise_id = "+arg(filter.ExerciseID))
+	}
+	if filter.SectionID != 0 {
+		conditions = append(conditions, "uw.section_id = "+arg(filter.SectionID))
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "ued.submitted_at >= "+arg(filter.From.Format(dateLayout))+"::date")
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "ued.submitted_at <= "+arg(filter.To.Format(dateLayout))+"::date")
+	}
+
+	order, after := "ASC", ">"
+	if filter.Descending {
+		order, after = "DESC", "<"
+	}
+	if filter.After != nil {
+		conditions = append(conditions, fmt.Sprintf("(ued.performed_at, ued.id) %s (%s, %s)",
+			after, arg(filter.After.PerformedAt), arg(filter.After.ID)))
+	}
+
+	// one extra row tells whether there is a next page
+	limit := ""
+	if filter.Limit > 0 {
+		limit = "LIMIT " + arg(filter.Limit+1)
+	}
+	rows, err := DB.Query(`
+		SELECT ued.id, ued.exercise_id, e.name, e.measurement_type, COALESCE(ued.custom_load, 0), COALESCE(ued.custom_reps, 0),
+			ued.submitted_at, ued.performed_at, ued.prescribed_reps, ued.prescribed_rpe
+		FROM UserExercisesDetails ued
+		JOIN Exercises e ON e.id = ued.exercise_id
+		JOIN UserWorkouts uw ON uw.id = ued.user_workout_id
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY ued.performed_at `+order+`, ued.id `+order+`
+		`+limit, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query user progress: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	entries = []models.UserProgressResponse{}
+	var detailIDs []int
+	for rows.Next() {
+		var (
+			entry          models.UserProgressResponse
+			submittedAt    time.Time
+			performedAt    time.Time
+			prescribedReps sql.NullString
+			prescribedRPE  sql.NullString
+		)
+		if err := rows.Scan(
+			&entry.ID, &entry.ExerciseID, &entry.ExerciseName, &entry.MeasurementType, &entry.CustomLoad, &entry.CustomReps,
+			&submittedAt, &performedAt, &prescribedReps, &prescribedRPE,
+		); err != nil {
+			return nil, false, fmt.Errorf("failed to scan user progress: %w", err)
+		}
+		entry.SubmittedAt = submittedAt.Format(dateLayout)
+		entry.PerformedAt = &performedAt
+		entry.Effort = prescribedEffort(prescribedReps, prescribedRPE)
+		entries = append(entries, entry)
+		detailIDs = append(detailIDs, entry.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries, detailIDs, more = entries[:filter.Limit], detailIDs[:filter.Limit], true
+	}
+	sets, err := GetExerciseSets(detailIDs)
+	if err != nil {
+		return nil, false, err
+	}
+	attachSets(entries, sets)
+	return entries, more, nil
+}
+
+// returns the user's last logged entry of each of exerciseIDs with its sets,
+// exercises never logged are missing
+func LastEntries(userID int, exerciseIDs []int) (map[int]models.UserProgressResponse, error) {
+	last := make(map[int]models.UserProgressResponse, len(exerciseIDs))
+	if len(exerciseIDs) == 0 {
+		return last, nil
+	}
+
+	rows, err := DB.Query(`
+		SELECT DISTINCT ON (ued.exercise_id)
+			ued.id, ued.exercise_id, e.name, e.measurement_type, COALESCE(ued.custom_load, 0), COALESCE(ued.custom_reps, 0),
+			ued.submitted_at, ued.performed_at
+		FROM UserExercisesDetails ued
+		JOIN Exercises e ON e.id = ued.exercise_id
+		JOIN UserWorkouts uw ON uw.id = ued.user_workout_id
+		WHERE ued.user_id = $1 AND ued.exercise_id = ANY($2) AND ued.deleted_at IS NULL
+		ORDER BY ued.exercise_id, ued.performed_at DESC, ued.id DESC
+	`, userID, pq.Array(exerciseIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query last entries: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	entries := []models.UserProgressResponse{}
+	var detailIDs []int
+	for rows.Next() {
+		var entry models.UserProgressResponse
+		var submittedAt, performedAt time.Time
+		if err := rows.Scan(
+			&entry.ID, &entry.ExerciseID, &entry.ExerciseName, &entry.MeasurementType, &entry.CustomLoad, &entry.CustomReps,
+			&submittedAt, &performedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan last entry: %w", err)
+		}
+		entry.SubmittedAt = submittedAt.Format(dateLayout)
+		entry.PerformedAt = &performedAt
+		entries = append(entries, entry)
+		detailIDs = append(detailIDs, entry.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sets, err := GetExerciseSets(detailIDs)
+	if err != nil {
+		return nil, err
+	}
+	attachSets(entries, sets)
+	for _, entry := range entries {
+		last[entry.ExerciseID] = entry
+	}
+	return last, nil
+}
+
+// returns the prescriptions of a section's exercises for program week week,
+// in section order, loads in kg
+func ListSectionPrescriptions(sectionID, week int) ([]models.ExerciseDetails, error) {
+	rows, err := StmtGetExerciseDetails.Query(sectionID, week)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query section prescriptions: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	details := []models.ExerciseDetails{}
+	for rows.Next() {
+		var detail models.ExerciseDetails
+		if err := rows.Scan(
+			&detail.ID, &detail.Name, &detail.MeasurementType, &detail.WarmupSets, &detail.WorkSets,
+			&detail.Reps, &detail.Load, &detail.RPE, &detail.RestTime,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan section prescription: %w", err)
+		}
+		details = append(details, detail)
+	}
+	return details, rows.Err()
+}
@@ -0,0 +1,56 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+)
+
+// ErrUserNotFound is returned when no user matches
+var ErrUserNotFound = errors.New("user not found")
+
+// returns the role of the user
+func GetUserRole(userID int) (models.Role, error) {
+	var role string
+	err := DB.QueryRow(`SELECT role FROM Users WHERE id = $1`, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", ErrUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return models.Role(role), nil
+}
+
+// changes the role of the user
+func SetUserRole(userID int, role models.Role) error {
+	result, err := DB.Exec(`UPDATE Users SET role = $1 WHERE id = $2`, string(role), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set user role: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// changes the role of the user with this email and returns their ID
+func SetUserRoleByEmail(email string, role models.Role) (int, error) {
+	var userID int
+	err := DB.QueryRow(`
+		UPDATE Users SET role = $1 WHERE email = $2 RETURNING id
+	`, string(role), email).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrUserNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to set user role: %w", err)
+	}
+	return userID, nil
+}
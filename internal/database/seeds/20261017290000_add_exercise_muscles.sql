@@ -0,0 +1,51 @@
+-- file: internal/database/seeds/20261017290000_add_exercise_muscles.sql
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO ExerciseMuscles (exercise_id, muscle, role)
+SELECT e.id, m.muscle, m.role
+FROM (VALUES
+    ('Incline Machine Press', 'chest', 'primary'),
+    ('Incline Machine Press', 'shoulders', 'secondary'),
+    ('Incline Machine Press', 'triceps', 'secondary'),
+    ('Single-Leg Leg Press(Heavy)', 'quads', 'primary'),
+    ('Single-Leg Leg Press(Heavy)', 'glutes', 'secondary'),
+    ('Single-Leg Leg Press(Back off)', 'quads', 'primary'),
+    ('Single-Leg Leg Press(Back off)', 'glutes', 'secondary'),
+    ('Pendlay Row', 'back', 'primary'),
+    ('Pendlay Row', 'biceps', 'secondary'),
+    ('Glute-Ham Raise', 'hamstrings', 'primary'),
+    ('Glute-Ham Raise', 'glutes', 'secondary'),
+    ('Spider Curl', 'biceps', 'primary'),
+    ('Cable Lateral Raise', 'shoulders', 'primary'),
+    ('Hanging Leg Raise', 'abs', 'primary'),
+    ('2-Grip Pullup', 'back', 'primary'),
+    ('2-Grip Pullup', 'biceps', 'secondary'),
+    ('Weighted Dip(Heavy)', 'chest', 'primary'),
+    ('Weighted Dip(Heavy)', 'triceps', 'secondary'),
+    ('Weighted Dip(Heavy)', 'shoulders', 'secondary'),
+    ('Weighted Dip(Back off)', 'chest', 'primary'),
+    ('Weighted Dip(Back off)', 'triceps', 'secondary'),
+    ('Weighted Dip(Back off)', 'shoulders', 'secondary'),
+    ('Incline Chest-Supported DB Row', 'back', 'primary'),
+    ('Incline Chest-Supported DB Row', 'biceps', 'secondary'),
+    ('Standing DB Arnold Press', 'shoulders', 'primary'),
+    ('Standing DB Arnold Press', 'triceps', 'secondary'),
+    ('A1: DB Incline Curl', 'biceps', 'primary'),
+    ('A2: DB French Press', 'triceps', 'primary'),
+    ('DB Bulgarian Split Squat', 'quads', 'primary'),
+    ('DB Bulgarian Split Squat', 'glutes', 'secondary'),
+    ('DB Romanian Deadlift', 'hamstrings', 'primary'),
+    ('DB Romanian Deadlift', 'glutes', 'secondary'),
+    ('Goblet Squat', 'quads', 'primary'),
+    ('Goblet Squat', 'glutes', 'secondary'),
+    ('Leg Press Toe Press', 'calves', 'primary'),
+    ('Machine Crunch', 'abs', 'primary')
+) AS m(exercise_name, muscle, role)
+JOIN Exercises e ON e.name = m.exercise_name
+ON CONFLICT (exercise_id, muscle) DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM ExerciseMuscles;
+-- +goose StatementEnd
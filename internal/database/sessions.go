@@ -0,0 +1,169 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// ErrSessionNotFound is returned when there is no active session to revoke
+var ErrSessionNotFound = errors.New("session not found")
+
+// creates a new login session for the user's device and returns its ID
+func CreateSession(userID int, userAgent, ipAddress string) (int, error) {
+	var sessionID int
+	err := DB.QueryRow(`
+		INSERT INTO user_sessions (user_id, user_agent, ip_address)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, userID, userAgent, ipAddress).Scan(&sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create session: %w", err)
+	}
+	return sessionID, nil
+}
+
+// reports whether the session exists, belongs to the user and is not revoked
+func IsSessionActive(sessionID, userID int) (bool, error) {
+	var active bool
+	err := StmtGetSessionStatus.QueryRow(sessionID, userID).Scan(&active)
+	if err != nil {
+		return false, err
+	}
+	return active, nil
+}
+
+// bumps last_seen_at, at most once a minute so requests stay read-only
+func TouchSession(sessionID int) error {
+	_, err := StmtTouchSession.Exec(sessionID)
+	return err
+}
+
+// lists the user's active sessions, most recently used first
+func ListActiveSessions(userID int) ([]models.UserSession, error) {
+	rows, err := DB.Query(`
+		SELECT id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_seen_at
+		FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY last_seen_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	sessions := []models.UserSession{}
+	for rows.Next() {
+		var session models.UserSession
+		if err := rows.Scan(&session.ID, &session.UserAgent, &session.IPAddress, &session.CreatedAt, &session.LastSeenAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// revokes a single session and its refresh tokens, returns ErrSessionNotFound
+// when the user has no such active session
+func RevokeSession(userID, sessionID int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE user_sessions
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, sessionID, userID)
+	if err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	if revoked, err := result.RowsAffected(); err != nil || revoked == 0 {
+		rollback(tx)
+		if err != nil {
+			return err
+		}
+		return ErrSessionNotFound
+	}
+
+	if _, err = tx.Exec(`
+		UPDATE RefreshTokens
+		SET revoked_at = NOW()
+		WHERE session_id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, sessionID, userID); err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if err = clearAccessTokenIfNoSessions(tx, userID); err != nil {
+		rollback(tx)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// revokes every session and refresh token of the user
+func RevokeAllSessions(userID int) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(`
+		UPDATE user_sessions
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+
+	if _, err = tx.Exec(`
+		UPDATE RefreshTokens
+		SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID); err != nil {
+		rollback(tx)
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	if err = clearAccessTokenIfNoSessions(tx, userID); err != nil {
+		rollback(tx)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// the stored Google access token is only needed while a session is alive
+func clearAccessTokenIfNoSessions(tx *sql.Tx, userID int) error {
+	_, err := tx.Exec(`
+		UPDATE Users
+		SET access_token = NULL
+		WHERE id = $1
+		AND NOT EXISTS (
+			SELECT 1 FROM user_sessions WHERE user_id = $1 AND revoked_at IS NULL
+		)
+	`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to clear access token: %w", err)
+	}
+	return nil
+}
+
+func rollback(tx *sql.Tx) {
+	if err := tx.Rollback(); err != nil {
+		utils.Logger.Error("Transaction rollback failed", zap.Error(err))
+	}
+}
@@ -12,20 +12,31 @@ var (
 	StmtGetWorkoutSections      *sql.Stmt
 	StmtGetExercisesBySectionID *sql.Stmt
 	StmtGetExerciseDetails      *sql.Stmt
-	StmtGetUserProgress         *sql.Stmt
+	StmtGetSessionStatus        *sql.Stmt
+	StmtTouchSession            *sql.Stmt
+	StmtGetActiveAPIKey         *sql.Stmt
+	StmtTouchAPIKey             *sql.Stmt
 )
 
+// how far program week $2 is from a prescription's week range, 0 inside it.
+// Weeks a program doesn't prescribe fall back to the nearest range.
+const weekDistance = `CASE
+	WHEN $2::int BETWEEN ed.week_start AND ed.week_end THEN 0
+	ELSE LEAST(ABS($2::int - ed.week_start), ABS($2::int - ed.week_end))
+END`
+
 func PrepareStatements() {
 	var err error
 	StmtGetWorkoutSections, err = DB.Prepare(
-		"SELECT id, name, route FROM WorkoutSections",
+		"SELECT id, name, route FROM WorkoutSections ORDER BY sort_order, id",
 	)
 	if err != nil {
 		utils.Logger.Fatal("Failed to prepare StmtGetWorkoutSections", zap.Error(err))
 	}
 
+	// one prescription per exercise for program week $2, see weekDistance
 	StmtGetExercisesBySectionID, err = DB.Prepare(`
-    SELECT
+    SELECT DISTINCT ON (e.sort_order, e.id)
       e.name,
       ed.reps,
       ed.working_sets,
@@ -33,15 +44,17 @@ func PrepareStatements() {
     FROM Exercises e
     JOIN ExerciseDetails ed ON e.id = ed.exercise_id
     WHERE e.workout_section_id = $1
+    ORDER BY e.sort_order, e.id, ` + weekDistance + `, ed.week_start
   `)
 	if err != nil {
 		utils.Logger.Fatal("Failed to prepare StmtGetExercisesBySectionID", zap.Error(err))
 	}
 
 	StmtGetExerciseDetails, err = DB.Prepare(`
-		SELECT
+		SELECT DISTINCT ON (e.sort_order, e.id)
 			e.id,
 			e.name,
+			e.measurement_type,
 			ed.warmup_sets,
 			ed.working_sets,
 			ed.reps,
@@ -51,21 +64,51 @@ func PrepareStatements() {
 		FROM Exercises e
 		JOIN ExerciseDetails ed ON e.id = ed.exercise_id
 		WHERE e.workout_section_id = $1
+		ORDER BY e.sort_order, e.id, ` + weekDistance + `, ed.week_start
   `)
 	if err != nil {
 		utils.Logger.Fatal("Failed to prepare StmtGetExerciseDetails", zap.Error(err))
 	}
 
-	StmtGetUserProgress, err = DB.Prepare(`
-	SELECT ued.exercise_id, e.name, ued.custom_load, ued.custom_reps, ued.submitted_at
-	FROM UserExercisesDetails ued
-	JOIN Exercises e ON ued.exercise_id = e.id
-	JOIN UserWorkouts uw ON ued.user_workout_id = uw.id
-	WHERE uw.user_id = $1
-	ORDER BY ued.submitted_at ASC
+	// checked by AuthMiddleware on every request, keep it cheap
+	StmtGetSessionStatus, err = DB.Prepare(`
+	SELECT revoked_at IS NULL
+	FROM user_sessions
+	WHERE id = $1 AND user_id = $2
+	`)
+	if err != nil {
+		utils.Logger.Fatal("Failed to prepare StmtGetSessionStatus", zap.Error(err))
+	}
+
+	// only writes when last_seen_at is stale, most requests match no row
+	StmtTouchSession, err = DB.Prepare(`
+	UPDATE user_sessions
+	SET last_seen_at = NOW()
+	WHERE id = $1 AND last_seen_at < NOW() - INTERVAL '1 minute'
+	`)
+	if err != nil {
+		utils.Logger.Fatal("Failed to prepare StmtTouchSession", zap.Error(err))
+	}
+
+	// checked by AuthMiddleware on every API key request
+	StmtGetActiveAPIKey, err = DB.Prepare(`
+	SELECT k.id, k.user_id, u.email, k.scopes
+	FROM api_keys k
+	JOIN Users u ON u.id = k.user_id
+	WHERE k.key_hash = $1 AND k.revoked_at IS NULL
+	`)
+	if err != nil {
+		utils.Logger.Fatal("Failed to prepare StmtGetActiveAPIKey", zap.Error(err))
+	}
+
+	// same once-a-minute throttle as StmtTouchSession
+	StmtTouchAPIKey, err = DB.Prepare(`
+	UPDATE api_keys
+	SET last_used_at = NOW()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')
 	`)
 	if err != nil {
-		utils.Logger.Fatal("Failed to prepare StmtGetUserProgress", zap.Error(err))
+		utils.Logger.Fatal("Failed to prepare StmtTouchAPIKey", zap.Error(err))
 	}
 }
 
@@ -79,7 +122,16 @@ func CloseStatement() {
 	if err := StmtGetExerciseDetails.Close(); err != nil {
 		utils.Logger.Error("Failed to close StmtGetExerciseDetails", zap.Error(err))
 	}
-	if err := StmtGetUserProgress.Close(); err != nil {
-		utils.Logger.Error("Failed to close StmtGetUserProgress", zap.Error(err))
+	if err := StmtGetSessionStatus.Close(); err != nil {
+		utils.Logger.Error("Failed to close StmtGetSessionStatus", zap.Error(err))
+	}
+	if err := StmtTouchSession.Close(); err != nil {
+		utils.Logger.Error("Failed to close StmtTouchSession", zap.Error(err))
+	}
+	if err := StmtGetActiveAPIKey.Close(); err != nil {
+		utils.Logger.Error("Failed to close StmtGetActiveAPIKey", zap.Error(err))
+	}
+	if err := StmtTouchAPIKey.Close(); err != nil {
+		utils.Logger.Error("Failed to close StmtTouchAPIKey", zap.Error(err))
 	}
 }
@@ -0,0 +1,522 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/training"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrWorkoutSessionNotFound = errors.New("workout session not found")
+	ErrWorkoutSessionFinished = errors.New("workout session is already finished")
+)
+
+// starts a workout session of a section for the user
+func StartWorkoutSession(userID, sectionID int, notes string) (models.WorkoutSession, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return models.WorkoutSession{}, err
+	}
+
+	var userWorkoutID int
+	err = tx.QueryRow(`
+		INSERT INTO UserWorkouts (user_id, section_id)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id, section_id) DO UPDATE
+		SET user_id = EXCLUDED.user_id
+		RETURNING id
+	`, userID, sectionID).Scan(&userWorkoutID)
+	if pqErrorCode(err) == pqForeignKeyViolation {
+		rollback(tx)
+		return models.WorkoutSession{}, ErrSectionNotFound
+	}
+	if err != nil {
+		rollback(tx)
+		return models.WorkoutSession{}, fmt.Errorf("failed to upsert user workout: %w", err)
+	}
+
+	var sessionID int
+	err = tx.QueryRow(`
+		INSERT INTO WorkoutSessions (user_id, user_workout_id, notes)
+		VALUES ($1, $2, NULLIF($3, ''))
+		RETURNING id
+	`, userID, userWorkoutID, notes).Scan(&sessionID)
+	if err != nil {
+		rollback(tx)
+		return models.WorkoutSession{}, fmt.Errorf("failed to create workout session: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.WorkoutSession{}, err
+	}
+	return GetWorkoutSession(userID, sessionID)
+}
+
+// returns a session of the user with its exercises and sets
+func GetWorkoutSession(userID, sessionID int) (models.WorkoutSession, error) {
+	var (
+		session    models.WorkoutSession
+		finishedAt sql.NullTime
+		sessionRPE sql.NullFloat64
+	)
+	err := DB.QueryRow(`
+		SELECT s.id, ws.id, ws.name, s.started_at, s.finished_at, s.session_rpe, COALESCE(s.notes, '')
+		FROM WorkoutSessions s
+		JOIN UserWorkouts uw ON uw.id = s.user_workout_id
+		JOIN WorkoutSections ws ON ws.id = uw.section_id
+		WHERE s.id = $1 AND s.user_id = $2
+	`, sessionID, userID).Scan(
+		&session.ID, &session.SectionID, &session.SectionName, &session.StartedAt,
+		&finishedAt, &sessionRPE, &session.Notes,
+	)
+	if err == sql.ErrNoRows {
+		return models.WorkoutSession{}, ErrWorkoutSessionNotFound
+	}
+	if err != nil {
+		return models.WorkoutSession{}, fmt.Errorf("failed to query workout session: %w", err)
+	}
+	if finishedAt.Valid {
+		session.FinishedAt = &finishedAt.Time
+		session.DurationSeconds = sessionDuration(session.StartedAt, finishedAt.Time)
+	}
+	if sessionRPE.Valid {
+		session.SessionRPE = &sessionRPE.Float64
+	}
+
+	session.Exercises, err = getSessionEntries(sessionID)
+	if err != nil {
+		return models.WorkoutSession{}, err
+	}
+	return session, nil
+}
+
+// appends sets of an exercise to an unfinished session and returns the
+// exercise entry with all its sets and the personal records they set.
+// performed is when the exercise's first
+// sets were performed, in the client's zone, tzOffset its UTC offset in
+// minutes when the client sent one.
+func AddSessionSets(userID, sessionID, exerciseID int, sets []models.UserExerciseSet, performed time.Time, tzOffset *int) (models.UserProgressResponse, []models.PersonalRecord, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return models.UserProgressResponse{}, nil, err
+	}
+
+	// the UserWorkouts lock keeps records stable, see RecordPersonalRecords
+	var (
+		userWorkoutID, sectionID int
+		finished                 bool
+	)
+	err = tx.QueryRow(`
+		SELECT s.user_workout_id, uw.section_id, s.finished_at IS NOT NULL
+		FROM WorkoutSessions s
+		JOIN UserWorkouts uw ON uw.id = s.user_workout_id
+		WHERE s.id = $1 AND s.user_id = $2
+		FOR UPDATE OF s, uw
+	`, sessionID, userID).Scan(&userWorkoutID, &sectionID, &finished)
+	if err == sql.ErrNoRows {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, ErrWorkoutSessionNotFound
+	}
+	if err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, fmt.Errorf("failed to lock workout session: %w", err)
+	}
+	if finished {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, ErrWorkoutSessionFinished
+	}
+
+	// an exercise deleted from the session starts over when it is logged again,
+	// one still in the session keeps when its first sets were performed
+	_, err = tx.Exec(`
+		DELETE FROM UserExerciseSets
+		WHERE user_exercise_detail_id IN (
+			SELECT id FROM UserExercisesDetails
+			WHERE session_id = $1 AND exercise_id = $2 AND deleted_at IS NOT NULL
+		)
+	`, sessionID, exerciseID)
+	if err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, fmt.Errorf("failed to clear deleted session sets: %w", err)
+	}
+
+	var (
+		detailID    int
+		performedAt time.Time
+	)
+	err = tx.QueryRow(`
+		INSERT INTO UserExercisesDetails (user_workout_id, user_id, exercise_id, session_id,
+			submitted_at, performed_at, performed_tz_offset, received_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (session_id, exercise_id) WHERE session_id IS NOT NULL DO UPDATE
+		SET submitted_at = CASE WHEN UserExercisesDetails.deleted_at IS NULL
+				THEN UserExercisesDetails.submitted_at ELSE EXCLUDED.submitted_at END,
+			performed_at = CASE WHEN UserExercisesDetails.deleted_at IS NULL
+				THEN UserExercisesDetails.performed_at ELSE EXCLUDED.performed_at END,
+			performed_tz_offset = CASE WHEN UserExercisesDetails.deleted_at IS NULL
+				THEN UserExercisesDetails.performed_tz_offset ELSE EXCLUDED.performed_tz_offset END,
+			deleted_at = NULL
+		RETURNING id, performed_at
+	`, userWorkoutID, userID, exerciseID, sessionID, performed.Format(dateLayout), performed, tzOffset).Scan(&detailID, &performedAt)
+	if pqErrorCode(err) == pqForeignKeyViolation {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, ErrExerciseNotFound
+	}
+	if err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, fmt.Errorf("failed to upsert session exercise: %w", err)
+	}
+
+	var lastIndex int
+	err = tx.QueryRow(`
+		SELECT COALESCE(MAX(set_index), 0) FROM UserExerciseSets WHERE user_exercise_detail_id = $1
+	`, detailID).Scan(&lastIndex)
+	if err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, fmt.Errorf("failed to query last set index: %w", err)
+	}
+
+	if err := insertExerciseSets(tx, detailID, sets, lastIndex); err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, fmt.Errorf("failed to insert session sets: %w", err)
+	}
+	if err := updateTopSet(tx, detailID); err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, fmt.Errorf("failed to update top set: %w", err)
+	}
+
+	week, err := GetSectionWeek(userID, sectionID)
+	if err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, err
+	}
+	if err := SnapshotPrescriptions(tx, []int{detailID}, week); err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, fmt.Errorf("failed to store prescription: %w", err)
+	}
+
+	records, err := recordSessionEntry(tx, userID, exerciseID, detailID, performedAt)
+	if err != nil {
+		rollback(tx)
+		return models.UserProgressResponse{}, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.UserProgressResponse{}, nil, err
+	}
+
+	entries, err := getSessionEntries(sessionID)
+	if err != nil {
+		return models.UserProgressResponse{}, nil, err
+	}
+	for _, entry := range entries {
+		if entry.ID == detailID {
+			return entry, records, nil
+		}
+	}
+	return models.UserProgressResponse{}, nil, ErrExerciseNotFound
+}
+
+// recomputes the records of a session entry from all of its sets and
+// returns the ones its latest sets set. Records its earlier sets already
+// set aren't new again.
+func recordSessionEntry(tx *sql.Tx, userID, exerciseID, detailID int, performedAt time.Time) ([]models.PersonalRecord, error) {
+	var measurementType string
+	if err := tx.QueryRow(`SELECT measurement_type FROM Exercises WHERE id = $1`, exerciseID).Scan(&measurementType); err != nil {
+		return nil, fmt.Errorf("failed to query measurement type: %w", err)
+	}
+	sets, err := queryExerciseSets(tx, []int{detailID})
+	if err != nil {
+		return nil, err
+	}
+
+	type recordKey struct {
+		recordType string
+		reps       int
+	}
+	earlier := map[recordKey]float64{}
+	rows, err := tx.Query(`
+		SELECT record_type, reps, value FROM PersonalRecords WHERE user_exercise_detail_id = $1
+	`, detailID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session entry records: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+	for rows.Next() {
+		var key recordKey
+		var value float64
+		if err := rows.Scan(&key.recordType, &key.reps, &value); err != nil {
+			return nil, fmt.Errorf("failed to scan session entry record: %w", err)
+		}
+		earlier[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	candidates := training.RecordCandidates(measurementType, sets[detailID])
+	for i := range candidates {
+		candidates[i].ExerciseID, candidates[i].EntryID, candidates[i].AchievedAt = exerciseID, detailID, performedAt
+	}
+	records, err := RecordPersonalRecords(tx, userID, []int{detailID}, candidates)
+	if err != nil {
+		return nil, err
+	}
+
+	newRecords := []models.PersonalRecord{}
+	for _, record := range records {
+		value, ok := earlier[recordKey{record.Type, record.Reps}]
+		if !ok || record.Value > value+recordTolerance {
+			newRecords = append(newRecords, record)
+		}
+	}
+	return newRecords, nil
+}
+
+// finishes a session, nil sessionRPE and notes are left unchanged
+func FinishWorkoutSession(userID, sessionID int, sessionRPE *float64, notes *string) (models.WorkoutSession, error) {
+	result, err := DB.Exec(`
+		UPDATE WorkoutSessions
+		SET finished_at = NOW(),
+			session_rpe = COALESCE($3, session_rpe),
+			notes = COALESCE($4, notes)
+		WHERE id = $1 AND user_id = $2 AND finished_at IS NULL
+	`, sessionID, userID, sessionRPE, notes)
+	if err != nil {
+		return models.WorkoutSession{}, fmt.Errorf("failed to finish workout session: %w", err)
+	}
+	if err := expectOneRow(result, ErrWorkoutSessionFinished); err != nil {
+		if errors.Is(err, ErrWorkoutSessionFinished) {
+			// tell a finished session apart from one that isn't there
+			if _, getErr := GetWorkoutSession(userID, sessionID); getErr != nil {
+				return models.WorkoutSession{}, getErr
+			}
+		}
+		return models.WorkoutSession{}, err
+	}
+	return GetWorkoutSession(userID, sessionID)
+}
+
+// returns a page of the user's progress grouped by session, ordered by when
+// the group started then by its first entry's id. Entries without a session
+// are grouped by section and day and start with their first entry. Only
+// filter.Descending, Limit and After apply, Limit counts groups. more
+// reports whether groups follow the page.
+func ListProgressBySession(userID int, filter models.ProgressFilter) (groups []models.ProgressSession, more bool, err error) {
+	args := []interface{}{userID}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	order, after := "ASC", ">"
+	if filter.Descending {
+		order, after = "DESC", "<"
+	}
+	keyset := ""
+	if filter.After != nil {
+		keyset = fmt.Sprintf("WHERE (group_at, group_id) %s (%s, %s)",
+			after, arg(filter.After.PerformedAt), arg(filter.After.ID))
+	}
+	// one extra group tells whether there is a next page
+	limit := ""
+	if filter.Limit > 0 {
+		limit = "LIMIT " + arg(filter.Limit+1)
+	}
+
+	rows, err := DB.Query(`
+		WITH grouped AS (
+			SELECT ued.id, ued.exercise_id, e.name AS exercise_name, e.measurement_type,
+				COALESCE(ued.custom_load, 0) AS custom_load, COALESCE(ued.custom_reps, 0) AS custom_reps,
+				ued.submitted_at, ued.performed_at, ued.prescribed_reps, ued.prescribed_rpe, ued.session_id, uw.section_id,
+				s.started_at, s.finished_at, s.session_rpe, COALESCE(s.notes, '') AS notes,
+				COALESCE(s.started_at, MIN(ued.performed_at) OVER entry_group) AS group_at,
+				MIN(ued.id) OVER entry_group AS group_id
+			FROM UserExercisesDetails ued
+			JOIN Exercises e ON e.id = ued.exercise_id
+			JOIN UserWorkouts uw ON uw.id = ued.user_workout_id
+			LEFT JOIN WorkoutSessions s ON s.id = ued.session_id
+			WHERE ued.user_id = $1 AND ued.deleted_at IS NULL
+			WINDOW entry_group AS (PARTITION BY
+				ued.session_id,
+				CASE WHEN ued.session_id IS NULL THEN ued.user_workout_id END,
+				CASE WHEN ued.session_id IS NULL THEN ued.submitted_at END
+			)
+		), page AS (
+			SELECT DISTINCT group_at, group_id
+			FROM grouped
+			`+keyset+`
+			ORDER BY group_at `+order+`, group_id `+order+`
+			`+limit+`
+		)
+		SELECT id, exercise_id, exercise_name, measurement_type, custom_load, custom_reps, submitted_at, performed_at,
+			prescribed_reps, prescribed_rpe, session_id, section_id, started_at, finished_at, session_rpe, notes,
+			group_at, group_id
+		FROM grouped
+		JOIN page USING (group_at, group_id)
+		ORDER BY group_at `+order+`, group_id `+order+`, id
+	`, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to query progress by session: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	groups = []models.ProgressSession{}
+	var detailIDs []int
+	lastGroup := 0
+	for rows.Next() {
+		var (
+			entry                 models.UserProgressResponse
+			submittedAt           time.Time
+			performedAt           time.Time
+			sessionID             sql.NullInt64
+			sectionID             int
+			startedAt, finishedAt sql.NullTime
+			sessionRPE            sql.NullFloat64
+			prescribedReps        sql.NullString
+			prescribedRPE         sql.NullString
+			notes                 string
+			at                    time.Time
+			groupID               int
+		)
+		if err := rows.Scan(
+			&entry.ID, &entry.ExerciseID, &entry.ExerciseName, &entry.MeasurementType,
+			&entry.CustomLoad, &entry.CustomReps, &submittedAt, &performedAt, &prescribedReps, &prescribedRPE,
+			&sessionID, &sectionID, &startedAt, &finishedAt, &sessionRPE, &notes, &at, &groupID,
+		); err != nil {
+			return nil, false, fmt.Errorf("failed to scan progress by session: %w", err)
+		}
+		entry.SubmittedAt = submittedAt.Format(dateLayout)
+		entry.PerformedAt = &performedAt
+		entry.Effort = prescribedEffort(prescribedReps, prescribedRPE)
+
+		if groupID != lastGroup {
+			group := models.ProgressSession{SectionID: sectionID, Date: entry.SubmittedAt, Notes: notes}
+			if sessionID.Valid {
+				id := int(sessionID.Int64)
+				group.SessionID = &id
+			}
+			if startedAt.Valid {
+				group.StartedAt = &startedAt.Time
+				group.Date = startedAt.Time.Format(dateLayout)
+			}
+			if finishedAt.Valid {
+				group.FinishedAt = &finishedAt.Time
+				if startedAt.Valid {
+					group.DurationSeconds = sessionDuration(startedAt.Time, finishedAt.Time)
+				}
+			}
+			if sessionRPE.Valid {
+				group.SessionRPE = &sessionRPE.Float64
+			}
+			group.Key = models.ProgressCursor{PerformedAt: at, ID: groupID, Descending: filter.Descending}
+			groups = append(groups, group)
+			lastGroup = groupID
+		}
+		groups[len(groups)-1].Entries = append(groups[len(groups)-1].Entries, entry)
+		detailIDs = append(detailIDs, entry.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	if filter.Limit > 0 && len(groups) > filter.Limit {
+		groups, more = groups[:filter.Limit], true
+	}
+	sets, err := GetExerciseSets(detailIDs)
+	if err != nil {
+		return nil, false, err
+	}
+	for i := range groups {
+		attachSets(groups[i].Entries, sets)
+	}
+	return groups, more, nil
+}
+
+// the exercise entries of a session with their sets, in logging order
+func getSessionEntries(sessionID int) ([]models.UserProgressResponse, error) {
+	rows, err := DB.Query(`
+		SELECT ued.id, ued.exercise_id, e.name, e.measurement_type,
+			COALESCE(ued.custom_load, 0), COALESCE(ued.custom_reps, 0), ued.submitted_at,
+			ued.prescribed_reps, ued.prescribed_rpe
+		FROM UserExercisesDetails ued
+		JOIN Exercises e ON e.id = ued.exercise_id
+		WHERE ued.session_id = $1 AND ued.deleted_at IS NULL
+		ORDER BY ued.id
+	`, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query session exercises: %w", err)
+	}
+	defer func() {
+		if err := rows.Close(); err != nil {
+			utils.Logger.Error("Failed to close rows", zap.Error(err))
+		}
+	}()
+
+	entries := []models.UserProgressResponse{}
+	var detailIDs []int
+	for rows.Next() {
+		var entry models.UserProgressResponse
+		var submittedAt time.Time
+		var prescribedReps, prescribedRPE sql.NullString
+		if err := rows.Scan(
+			&entry.ID, &entry.ExerciseID, &entry.ExerciseName, &entry.MeasurementType,
+			&entry.CustomLoad, &entry.CustomReps, &submittedAt, &prescribedReps, &prescribedRPE,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan session exercise: %w", err)
+		}
+		entry.SubmittedAt = submittedAt.Format(dateLayout)
+		entry.Effort = prescribedEffort(prescribedReps, prescribedRPE)
+		entries = append(entries, entry)
+		detailIDs = append(detailIDs, entry.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sets, err := GetExerciseSets(detailIDs)
+	if err != nil {
+		return nil, err
+	}
+	attachSets(entries, sets)
+	return entries, nil
+}
+
+// sets each entry's sets, an empty list when none were logged, with their
+// effective loads and the entry's e1RM
+func attachSets(entries []models.UserProgressResponse, sets map[int][]models.UserExerciseSet) {
+	for i := range entries {
+		entries[i].Sets = sets[entries[i].ID]
+		if entries[i].Sets == nil {
+			entries[i].Sets = []models.UserExerciseSet{}
+		}
+		training.AnnotateEntry(&entries[i])
+	}
+}
+
+// the prescription an entry was logged against, nil when it had none
+func prescribedEffort(reps, rpe sql.NullString) *models.EffortComparison {
+	if !reps.Valid && !rpe.Valid {
+		return nil
+	}
+	return &models.EffortComparison{PrescribedReps: reps.String, PrescribedRPE: rpe.String}
+}
+
+func sessionDuration(startedAt, finishedAt time.Time) *int {
+	seconds := int(finishedAt.Sub(startedAt).Seconds())
+	return &seconds
+}
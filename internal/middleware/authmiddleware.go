@@ -3,6 +3,7 @@ package middleware
 import (
 	"context"
 	"net/http"
+	"strings"
 
 	"github.com/haikali3/gymbara-backend/internal/auth"
 	"github.com/haikali3/gymbara-backend/internal/database"
@@ -16,12 +17,34 @@ type contextKey string
 const (
 	UserIDKey    contextKey = "user_id"
 	UserEmailKey contextKey = "user_email"
+	SessionIDKey contextKey = "session_id"
+	// APIKeyIDKey is only set when the request was made with an API key
+	APIKeyIDKey contextKey = "api_key_id"
 )
 
-// AuthMiddleware extracts the user ID and email from the JWT token
+// AuthMiddleware verifies the Gymbara session token and attaches the user ID and email.
+// API keys sent as a Bearer token are recognised, but only routes wrapped with
+// AuthWithScope accept them.
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return authenticate("", next)
+}
+
+// AuthWithScope works like AuthMiddleware and also accepts API keys that were
+// granted scope
+func AuthWithScope(scope string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return authenticate(scope, next)
+	}
+}
+
+func authenticate(scope string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// Extract and validate access token from cookie
+		if token, ok := bearerToken(r); ok {
+			authenticateAPIKey(w, r, token, scope, next)
+			return
+		}
+
+		// Extract session token from cookie
 		accessToken, err := r.Cookie("access_token")
 		if err != nil {
 			utils.Logger.Error("Access token cookie missing", zap.Error(err))
@@ -29,34 +52,77 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		utils.Logger.Info("Received access token", zap.String("token", accessToken.Value))
-
-		// Validate access token and get user ID
-		userID, err := auth.ValidateToken(accessToken.Value)
+		// Verify the token locally and make sure its session was not revoked
+		claims, err := auth.ValidateToken(accessToken.Value)
 		if err != nil {
 			utils.Logger.Error("Invalid access token", zap.Error(err))
 			utils.WriteStandardResponse(w, http.StatusUnauthorized, "Invalid access token", nil)
 			return
 		}
 
-		utils.Logger.Info("Token validated successfully", zap.Int("user_id", userID))
+		utils.Logger.Info("Token validated successfully",
+			zap.Int("user_id", claims.UserID),
+			zap.String("email", claims.Email),
+		)
 
-		// Fetch user email from database
-		var email string
-		err = database.DB.QueryRow("SELECT email FROM Users WHERE id = $1", userID).Scan(&email)
-		if err != nil {
-			utils.Logger.Error("Failed to fetch user email from database", zap.Error(err))
-			utils.WriteStandardResponse(w, http.StatusUnauthorized, "User email not found", nil)
-			return
+		// record activity for the device list, failures must not block the request
+		if err := database.TouchSession(claims.SessionID); err != nil {
+			utils.Logger.Warn("Failed to update session last seen", zap.Int("session_id", claims.SessionID), zap.Error(err))
 		}
 
-		utils.Logger.Info("Attaching user email to context", zap.String("email", email))
-
-		// Attach userID and email to request context
-		ctx := context.WithValue(r.Context(), UserIDKey, userID)
-		ctx = context.WithValue(ctx, UserEmailKey, email)
+		// Attach userID, email and session ID to request context
+		ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+		ctx = context.WithValue(ctx, UserEmailKey, claims.Email)
+		ctx = context.WithValue(ctx, SessionIDKey, claims.SessionID)
 
 		// Pass modified request to next handler
 		next.ServeHTTP(w, r.WithContext(ctx))
 	}
 }
+
+// authenticates a request made with a personal API key
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, token, scope string, next http.HandlerFunc) {
+	claims, err := auth.ValidateAPIKey(token)
+	if err != nil {
+		utils.Logger.Error("Invalid API key", zap.Error(err))
+		utils.WriteStandardResponse(w, http.StatusUnauthorized, "Invalid API key", nil)
+		return
+	}
+
+	if scope == "" {
+		utils.WriteStandardResponse(w, http.StatusForbidden, "API keys cannot access this endpoint", nil)
+		return
+	}
+	if !claims.HasScope(scope) {
+		utils.Logger.Warn("API key is missing scope",
+			zap.Int("api_key_id", claims.KeyID),
+			zap.String("scope", scope),
+		)
+		utils.WriteStandardResponse(w, http.StatusForbidden, "API key is missing the "+scope+" scope", nil)
+		return
+	}
+
+	utils.Logger.Info("API key validated successfully",
+		zap.Int("user_id", claims.UserID),
+		zap.Int("api_key_id", claims.KeyID),
+	)
+
+	ctx := context.WithValue(r.Context(), UserIDKey, claims.UserID)
+	ctx = context.WithValue(ctx, UserEmailKey, claims.Email)
+	ctx = context.WithValue(ctx, APIKeyIDKey, claims.KeyID)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// extracts the token of an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", false
+	}
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
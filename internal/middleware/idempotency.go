@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// IdempotencyKeyKey is only set when the request carried an Idempotency-Key
+const IdempotencyKeyKey contextKey = "idempotency_key"
+
+const (
+	maxIdempotencyKeyLength = 255
+	maxIdempotentBodySize   = 1 << 20
+)
+
+// swapped out in tests, they run without a database
+var (
+	reserveIdempotencyKey  = database.ReserveIdempotencyKey
+	completeIdempotencyKey = database.CompleteIdempotencyKey
+	releaseIdempotencyKey  = database.ReleaseIdempotencyKey
+)
+
+// Idempotent makes retries of a request with the same Idempotency-Key header
+// safe. The first successful response is stored and replayed for the same
+// request, a different request under the same key gets 409. Requests without
+// the header are passed through. Must run after the auth middleware.
+func Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			utils.HandleError(w, "Idempotency-Key must be at most 255 characters", http.StatusBadRequest, nil)
+			return
+		}
+
+		userID, ok := r.Context().Value(UserIDKey).(int)
+		if !ok {
+			utils.WriteStandardResponse(w, http.StatusUnauthorized, "Invalid user ID in context", nil)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		if err != nil {
+			utils.HandleError(w, "Invalid request body", http.StatusBadRequest, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		// the same key on another endpoint is a different request too
+		sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		requestHash := hex.EncodeToString(sum[:])
+
+		reservation, earlier, err := reserveIdempotencyKey(userID, key, requestHash)
+		if errors.Is(err, database.ErrIdempotencyKeyInUse) {
+			utils.HandleError(w, "A request with this Idempotency-Key is in progress", http.StatusConflict, nil)
+			return
+		}
+		if err != nil {
+			utils.HandleError(w, "Failed to check Idempotency-Key", http.StatusInternalServerError, err)
+			return
+		}
+
+		if earlier != nil {
+			switch {
+			case earlier.RequestHash != requestHash:
+				utils.HandleError(w, "Idempotency-Key was already used for a different request", http.StatusConflict, nil)
+			case earlier.Status == 0:
+				utils.HandleError(w, "A request with this Idempotency-Key is in progress", http.StatusConflict, nil)
+			default:
+				utils.Logger.Info("Replaying idempotent response", zap.Int("user_id", userID), zap.String("idempotency_key", key))
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(earlier.Status)
+				if _, err := w.Write(earlier.Body); err != nil {
+					utils.Logger.Error("Failed to write replayed response", zap.Error(err))
+				}
+			}
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		next(recorder, r.WithContext(context.WithValue(r.Context(), IdempotencyKeyKey, key)))
+
+		// only successful responses are kept, a failed request can be retried
+		// under the same key
+		if recorder.status >= 200 && recorder.status < 300 {
+			err = completeIdempotencyKey(reservation, recorder.status, recorder.body.Bytes())
+		} else {
+			err = releaseIdempotencyKey(reservation)
+		}
+		if err != nil {
+			utils.Logger.Error("Failed to update idempotency key", zap.Int("user_id", userID), zap.String("idempotency_key", key), zap.Error(err))
+		}
+	}
+}
+
+// passes the response through and keeps a copy of its status and body
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// keeps the last status, a handler that fails after writing its response,
+// e.g. on commit, must not have that response replayed
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
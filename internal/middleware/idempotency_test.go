@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// fakeKeys stands in for the idempotency_keys table
+type fakeKeys struct {
+	keys   map[string]*fakeKey
+	tokens int
+}
+
+type fakeKey struct {
+	record database.IdempotencyRecord
+	token  string
+}
+
+func (f *fakeKeys) reserve(userID int, key, requestHash string) (database.IdempotencyReservation, *database.IdempotencyRecord, error) {
+	f.tokens++
+	token := fmt.Sprintf("token-%d", f.tokens)
+	name := fmt.Sprintf("%d/%s", userID, key)
+	existing, ok := f.keys[name]
+	if !ok {
+		existing = &fakeKey{record: database.IdempotencyRecord{ID: len(f.keys) + 1}}
+		f.keys[name] = existing
+	} else if !existing.record.Reclaimable() {
+		record := existing.record
+		return database.IdempotencyReservation{}, &record, nil
+	}
+	existing.record = database.IdempotencyRecord{ID: existing.record.ID, RequestHash: requestHash}
+	existing.token = token
+	return database.IdempotencyReservation{ID: existing.record.ID, Token: token}, nil, nil
+}
+
+func (f *fakeKeys) held(reservation database.IdempotencyReservation) (string, *fakeKey) {
+	for name, key := range f.keys {
+		if key.record.ID == reservation.ID && key.token == reservation.Token {
+			return name, key
+		}
+	}
+	return "", nil
+}
+
+func (f *fakeKeys) complete(reservation database.IdempotencyReservation, status int, body []byte) error {
+	if _, key := f.held(reservation); key != nil {
+		key.record.Status, key.record.Body = status, body
+	}
+	return nil
+}
+
+func (f *fakeKeys) release(reservation database.IdempotencyReservation) error {
+	if name, key := f.held(reservation); key != nil && key.record.Status == 0 {
+		delete(f.keys, name)
+	}
+	return nil
+}
+
+func TestIdempotent(t *testing.T) {
+	utils.Logger = zap.NewNop()
+
+	keys := &fakeKeys{keys: map[string]*fakeKey{}}
+	reserve, complete, release := reserveIdempotencyKey, completeIdempotencyKey, releaseIdempotencyKey
+	defer func() {
+		reserveIdempotencyKey, completeIdempotencyKey, releaseIdempotencyKey = reserve, complete, release
+	}()
+	reserveIdempotencyKey, completeIdempotencyKey, releaseIdempotencyKey = keys.reserve, keys.complete, keys.release
+
+	calls := 0
+	status := http.StatusCreated
+	handler := Idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		utils.WriteStandardResponse(w, status, fmt.Sprintf("call %d", calls), nil)
+	})
+	post := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/workout-sections/user-exercise-details", strings.NewReader(body))
+		r.Header.Set("Idempotency-Key", key)
+		r = r.WithContext(context.WithValue(r.Context(), UserIDKey, 1))
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	t.Run("retry replays the stored response", func(t *testing.T) {
+		first := post("replay", `{"reps": 5}`)
+		retry := post("replay", `{"reps": 5}`)
+		if calls != 1 {
+			t.Fatalf("handler ran %d times, want 1", calls)
+		}
+		if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() || retry.Header().Get("Idempotent-Replayed") != "true" {
+			t.Errorf("retry = %d %q, want the replayed %d %q", retry.Code, retry.Body, first.Code, first.Body)
+		}
+	})
+
+	t.Run("different body under the same key", func(t *testing.T) {
+		calls = 0
+		post("reused", `{"reps": 5}`)
+		if w := post("reused", `{"reps": 6}`); w.Code != http.StatusConflict || calls != 1 {
+			t.Errorf("different body = %d after %d calls, want 409 after 1", w.Code, calls)
+		}
+	})
+
+	t.Run("failed response releases the key", func(t *testing.T) {
+		calls = 0
+		status = http.StatusInternalServerError
+		post("failed", `{"reps": 5}`)
+		status = http.StatusCreated
+		if w := post("failed", `{"reps": 5}`); w.Code != http.StatusCreated || calls != 2 {
+			t.Errorf("retry after a failure = %d after %d calls, want 201 after 2", w.Code, calls)
+		}
+	})
+
+	t.Run("unfinished reservation is taken over after the lease", func(t *testing.T) {
+		calls = 0
+		// a request that crashed after reserving the key
+		stale, _, _ := keys.reserve(1, "crashed", "")
+		keys.keys["1/crashed"].record.Age = 30 * time.Second
+		if w := post("crashed", `{"reps": 5}`); w.Code != http.StatusConflict || calls != 0 {
+			t.Fatalf("retry within the lease = %d after %d calls, want 409 after 0", w.Code, calls)
+		}
+
+		keys.keys["1/crashed"].record.Age = 2 * time.Minute
+		if w := post("crashed", `{"reps": 5}`); w.Code != http.StatusCreated || calls != 1 {
+			t.Fatalf("retry after the lease = %d after %d calls, want 201 after 1", w.Code, calls)
+		}
+		// the stale request coming back can't touch the new reservation
+		if err := keys.release(stale); err != nil {
+			t.Fatal(err)
+		}
+		if err := keys.complete(stale, http.StatusInternalServerError, nil); err != nil {
+			t.Fatal(err)
+		}
+		if w := post("crashed", `{"reps": 5}`); w.Code != http.StatusCreated || calls != 1 {
+			t.Errorf("replay after the stale request returned = %d after %d calls, want 201 after 1", w.Code, calls)
+		}
+	})
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/haikali3/gymbara-backend/internal/database"
+	"github.com/haikali3/gymbara-backend/pkg/models"
+	"github.com/haikali3/gymbara-backend/pkg/utils"
+	"go.uber.org/zap"
+)
+
+// RequireRole only lets users through whose role includes role, e.g. an admin
+// passes RequireRole(models.RoleCoach, ...). Use it inside secureHandler.
+func RequireRole(role models.Role, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(UserIDKey).(int)
+		if !ok {
+			utils.WriteStandardResponse(w, http.StatusUnauthorized, "Invalid user ID in context", nil)
+			return
+		}
+
+		// read on every request so a demotion takes effect immediately
+		userRole, err := database.GetUserRole(userID)
+		if err != nil {
+			utils.Logger.Error("Failed to fetch user role", zap.Int("user_id", userID), zap.Error(err))
+			utils.WriteStandardResponse(w, http.StatusInternalServerError, "Internal server error", nil)
+			return
+		}
+
+		if !userRole.Includes(role) {
+			utils.Logger.Warn("Access denied by role",
+				zap.Int("user_id", userID),
+				zap.String("role", string(userRole)),
+				zap.String("required_role", string(role)),
+			)
+			utils.WriteStandardResponse(w, http.StatusForbidden, "Access denied: insufficient role", nil)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/haikali3/gymbara-backend/internal/middleware"
 	"github.com/haikali3/gymbara-backend/internal/payment"
 	"github.com/haikali3/gymbara-backend/internal/payment/webhook"
+	"github.com/haikali3/gymbara-backend/pkg/models"
 )
 
 // RegisterRoutes sets up all the HTTP routes for the application.
@@ -22,22 +23,29 @@ import (
 //
 // 2. User Routes:
 //    - Includes endpoints for submitting user exercise details, fetching user
-//      progress, and retrieving user information.
+//      progress, retrieving user information, following a program and managing
+//      signed-in devices, linked identity providers and personal API keys.
 //
 // 3. OAuth Routes:
 //    - Provides endpoints for handling OAuth login and callback functionality
-//      using Google authentication.
+//      using Google or any other registered identity provider, and for
+//      refreshing and revoking the Gymbara session.
 //
-// 4. Payment Routes:
+// 4. Admin Routes:
+//    - Catalog management and support endpoints, restricted by role with
+//      RequireRole.
+//
+// 5. Payment Routes:
 //    - Manages payment-related endpoints such as creating subscriptions,
 //      verifying checkout sessions, canceling subscriptions, and retrieving
 //      subscription details.
 //
-// 5. Webhook Routes:
+// 6. Webhook Routes:
 //    - Handles Stripe webhook events, such as checkout session completion.
 //
 // Middleware is applied to ensure proper security and functionality for each
-// route group.
+// route group. Routes built with scopedHandler also accept personal API keys
+// that carry the route's scope.
 
 func RegisterRoutes() {
 	const maxRequests = 10
@@ -51,26 +59,103 @@ func RegisterRoutes() {
 		return corsHandler(rateLimitedHandler(authHandler(handler)))
 	}
 
+	// like secureHandler, but personal API keys with the given scope are accepted too
+	scopedHandler := func(scope string, handler http.HandlerFunc) http.Handler {
+		rateLimitedHandler := middleware.RateLimit(maxRequests, duration)
+		corsHandler := middleware.CORS
+		authHandler := middleware.AuthWithScope(scope)
+
+		return corsHandler(rateLimitedHandler(authHandler(handler)))
+	}
+
 	// Workout routes
-	http.Handle("/workout-sections", secureHandler(middleware.RequireSubscription(controllers.GetWorkoutSections)))
-	http.Handle("/workout-sections/list", secureHandler(middleware.RequireSubscription(controllers.GetExercisesList)))
-	http.Handle("/workout-sections/details", secureHandler(middleware.RequireSubscription(controllers.GetExerciseDetails)))
+	http.Handle("/workout-sections", scopedHandler(oauth.APIKeyScopeRead, middleware.RequireSubscription(controllers.GetWorkoutSections)))
+	http.Handle("/workout-sections/list", scopedHandler(oauth.APIKeyScopeRead, middleware.RequireSubscription(controllers.GetExercisesList)))
+	http.Handle("/workout-sections/details", scopedHandler(oauth.APIKeyScopeRead, middleware.RequireSubscription(controllers.GetExerciseDetails)))
+	http.Handle("/programs", scopedHandler(oauth.APIKeyScopeRead, middleware.RequireSubscription(controllers.GetPrograms)))
 
 	//frontend fetch from this to display list of exercises
-	http.Handle("/workout-sections/exercises", secureHandler(middleware.RequireSubscription(controllers.GetWorkoutSectionsWithExercises)))
+	http.Handle("/workout-sections/exercises", scopedHandler(oauth.APIKeyScopeRead, middleware.RequireSubscription(controllers.GetWorkoutSectionsWithExercises)))
 	// exercise guide by id
-	http.Handle("/workout-sections/exercises/", secureHandler(middleware.RequireSubscription(controllers.GetExerciseGuide)))
+	http.Handle("/workout-sections/exercises/", scopedHandler(oauth.APIKeyScopeRead, middleware.RequireSubscription(controllers.GetExerciseGuide)))
 
-	// User submit exercise details
-	http.Handle("/workout-sections/user-exercise-details", secureHandler(controllers.SubmitUserExerciseDetails))
+	// User submit exercise details, retries with the same Idempotency-Key are replayed
+	http.Handle("/workout-sections/user-exercise-details", scopedHandler(oauth.APIKeyScopeLogsWrite, middleware.Idempotent(controllers.SubmitUserExerciseDetails)))
+	// Workout sessions: start, log sets as they happen, finish
+	http.Handle("/user/workout-sessions", scopedHandler(oauth.APIKeyScopeLogsWrite, controllers.StartWorkoutSession))
+	http.Handle("/user/workout-sessions/{id}", scopedHandler(oauth.APIKeyScopeRead, controllers.GetWorkoutSession))
+	http.Handle("/user/workout-sessions/{id}/sets", scopedHandler(oauth.APIKeyScopeLogsWrite, middleware.Idempotent(controllers.AddWorkoutSessionSets)))
+	http.Handle("/user/workout-sessions/{id}/finish", scopedHandler(oauth.APIKeyScopeLogsWrite, controllers.FinishWorkoutSession))
+	// Correct or delete a logged entry, deletes can be undone for 24 hours
+	http.Handle("/user/exercise-entries/{id}", scopedHandler(oauth.APIKeyScopeLogsWrite, controllers.ExerciseEntry))
+	http.Handle("/user/exercise-entries/{id}/restore", scopedHandler(oauth.APIKeyScopeLogsWrite, controllers.RestoreExerciseEntry))
 	// Fetch user submitted exercise detail
-	http.Handle("/user/progress", secureHandler(controllers.GetUserProgress))
+	http.Handle("/user/progress", scopedHandler(oauth.APIKeyScopeRead, controllers.GetUserProgress))
+	// Estimated one rep max of an exercise over time
+	http.Handle("/user/progress/exercises/{id}/trend", scopedHandler(oauth.APIKeyScopeRead, controllers.GetExerciseTrend))
+	// Personal records set on an exercise, new ones are returned when logging
+	http.Handle("/user/progress/exercises/{id}/records", scopedHandler(oauth.APIKeyScopeRead, controllers.GetExercisePersonalRecords))
+	// Sets, reps, tonnage and hard sets per muscle by week
+	http.Handle("/user/progress/weekly-summary", scopedHandler(oauth.APIKeyScopeRead, controllers.GetWeeklySummary))
+	// Load and reps to aim for in the next session of a section
+	http.Handle("/user/recommendations", scopedHandler(oauth.APIKeyScopeRead, controllers.GetSessionRecommendations))
+	// Program the user follows, drives which week's prescriptions are served
+	http.Handle("/user/program", scopedHandler(oauth.APIKeyScopeRead, controllers.GetUserProgram))
+	http.Handle("/user/program/history", scopedHandler(oauth.APIKeyScopeRead, controllers.GetUserProgramHistory))
+	http.Handle("/user/program/start", secureHandler(controllers.StartUserProgram))
+	http.Handle("/user/program/pause", secureHandler(controllers.PauseUserProgram))
+	http.Handle("/user/program/resume", secureHandler(controllers.ResumeUserProgram))
+	http.Handle("/user/program/restart", secureHandler(controllers.RestartUserProgram))
+	http.Handle("/user/program/switch", secureHandler(controllers.SwitchUserProgram))
+	// Unit (kg or lb) and plate increment loads are shown in, and the progression rule
+	http.Handle("/user/preferences", secureHandler(controllers.UserPreferences))
 	// Fetch user details
 	http.Handle("/api/user-info", secureHandler(controllers.GetUserInfoHandler))
+	// List signed-in devices and sign one out
+	http.Handle("/user/sessions", secureHandler(controllers.GetUserSessions))
+	http.Handle("/user/sessions/{id}", secureHandler(controllers.RevokeUserSession))
+	// List linked sign-in providers and unlink one
+	http.Handle("/user/identities", secureHandler(controllers.GetUserIdentities))
+	http.Handle("/user/identities/{id}", secureHandler(controllers.UnlinkUserIdentity))
+	// Personal API keys, only manageable from a signed-in browser session
+	http.Handle("/user/api-keys", secureHandler(controllers.UserAPIKeys))
+	http.Handle("/user/api-keys/{id}", secureHandler(controllers.RevokeUserAPIKey))
 
 	// OAuth routes
 	http.Handle("/oauth/login", middleware.RateLimit(maxRequests, duration)(http.HandlerFunc(oauth.GoogleLoginHandler)))
 	http.Handle("/oauth/callback", middleware.RateLimit(maxRequests, duration)(http.HandlerFunc(oauth.GoogleCallbackHandler)))
+	// login with any registered provider, e.g. /oauth/github/login
+	http.Handle("/oauth/{provider}/login", middleware.RateLimit(maxRequests, duration)(http.HandlerFunc(oauth.LoginHandler)))
+	http.Handle("/oauth/{provider}/callback", middleware.RateLimit(maxRequests, duration)(http.HandlerFunc(oauth.CallbackHandler)))
+	// link another provider to the signed-in account
+	http.Handle("/oauth/{provider}/link", middleware.RateLimit(maxRequests, duration)(http.HandlerFunc(oauth.LinkHandler)))
+	// rotate the refresh token cookie and issue a new session token
+	http.Handle("/oauth/refresh", middleware.CORS(middleware.RateLimit(maxRequests, duration)(http.HandlerFunc(oauth.RefreshHandler))))
+	// revoke the session of this device / of every device
+	http.Handle("/oauth/logout", middleware.CORS(middleware.RateLimit(maxRequests, duration)(http.HandlerFunc(oauth.LogoutHandler))))
+	http.Handle("/oauth/logout-all", middleware.CORS(middleware.RateLimit(maxRequests, duration)(http.HandlerFunc(oauth.LogoutAllHandler))))
+
+	// Admin
+	http.Handle("/admin/users/{id}/role", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.SetUserRole)))
+	// How a user's recommendations progress
+	http.Handle("/admin/users/{id}/progression-rule", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.SetUserProgressionRule)))
+	// Which users a coach coaches
+	http.Handle("/admin/coaches/{id}/athletes/{athlete_id}", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.CoachAthleteLink)))
+
+	// Coach, only on the users linked to the coach
+	http.Handle("/coach/athletes/{id}/progression-rule", secureHandler(middleware.RequireRole(models.RoleCoach, controllers.SetAthleteProgressionRule)))
+	// Workout catalog management, every write invalidates the workout cache
+	http.Handle("/admin/programs", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.AdminPrograms)))
+	http.Handle("/admin/workout-sections", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.AdminWorkoutSections)))
+	http.Handle("/admin/workout-sections/order", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.ReorderWorkoutSections)))
+	http.Handle("/admin/workout-sections/{id}", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.AdminWorkoutSection)))
+	http.Handle("/admin/workout-sections/{id}/exercises/order", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.ReorderSectionExercises)))
+	http.Handle("/admin/exercises", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.AdminExercises)))
+	http.Handle("/admin/exercises/{id}", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.AdminExercise)))
+	http.Handle("/admin/exercises/{id}/prescriptions", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.AdminExercisePrescriptions)))
+	http.Handle("/admin/exercises/{id}/instructions", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.AdminExerciseInstructions)))
+	http.Handle("/admin/exercises/{id}/muscles", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.AdminExerciseMuscles)))
+	http.Handle("/admin/prescriptions/{id}", secureHandler(middleware.RequireRole(models.RoleAdmin, controllers.AdminPrescription)))
 
 	// Payment
 	http.Handle("/payment/checkout", middleware.CORS(http.HandlerFunc(payment.CreateSubscription)))
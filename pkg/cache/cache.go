@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"strings"
 	"sync"
 	"time"
 )
@@ -52,6 +53,17 @@ func (c *InMemoryCache) Delete(key string) {
 	delete(c.data, key)
 }
 
+// delete every item whose key starts with prefix
+func (c *InMemoryCache) DeletePrefix(prefix string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.data {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.data, key)
+		}
+	}
+}
+
 // cleanup expired cache item(by time)
 func (c *InMemoryCache) Cleanup(interval time.Duration, stopChan chan struct{}) {
 	ticker := time.NewTicker(interval)
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// APIKey is a personal API key as shown to its owner, the secret itself is
+// never stored
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// CreateAPIKeyRequest is the body of POST /user/api-keys
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreatedAPIKey is returned once on creation and carries the full key
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,103 @@
+package models
+
+// CatalogSection is a workout section as managed by admins
+type CatalogSection struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	Route     string `json:"route"`
+	ProgramID *int   `json:"program_id"`
+	SortOrder int    `json:"sort_order"`
+}
+
+// CatalogExercise is an exercise as managed by admins
+type CatalogExercise struct {
+	ID               int    `json:"id"`
+	WorkoutSectionID int    `json:"workout_section_id"`
+	Name             string `json:"name"`
+	Notes            string `json:"notes"`
+	Substitution1    string `json:"substitution_1"`
+	Substitution2    string `json:"substitution_2"`
+	MeasurementType  string `json:"measurement_type"`
+	SortOrder        int    `json:"sort_order"`
+}
+
+// Prescription is the set/rep/RPE target of an exercise for a range of weeks,
+// stored in ExerciseDetails
+type Prescription struct {
+	ID          int      `json:"id"`
+	ExerciseID  int      `json:"exercise_id"`
+	WeekStart   int      `json:"week_start"`
+	WeekEnd     int      `json:"week_end"`
+	WarmupSets  int      `json:"warmup_sets"`
+	WorkingSets int      `json:"working_sets"`
+	Reps        string   `json:"reps"`
+	Load        *float64 `json:"load"`
+	RPE         string   `json:"rpe"`
+	RestTime    string   `json:"rest_time"`
+}
+
+// SectionInput is the body of the admin section endpoints, nil fields are
+// left unchanged on update
+type SectionInput struct {
+	Name      *string `json:"name"`
+	Route     *string `json:"route"`
+	ProgramID *int    `json:"program_id"`
+}
+
+// ExerciseInput is the body of the admin exercise endpoints, nil fields are
+// left unchanged on update
+type ExerciseInput struct {
+	WorkoutSectionID *int    `json:"workout_section_id"`
+	Name             *string `json:"name"`
+	Notes            *string `json:"notes"`
+	Substitution1    *string `json:"substitution_1"`
+	Substitution2    *string `json:"substitution_2"`
+	MeasurementType  *string `json:"measurement_type"`
+}
+
+// PrescriptionInput is the body of the admin prescription endpoints, nil
+// fields are left unchanged on update
+type PrescriptionInput struct {
+	WeekStart   *int     `json:"week_start"`
+	WeekEnd     *int     `json:"week_end"`
+	WarmupSets  *int     `json:"warmup_sets"`
+	WorkingSets *int     `json:"working_sets"`
+	Reps        *string  `json:"reps"`
+	Load        *float64 `json:"load"`
+	RPE         *string  `json:"rpe"`
+	RestTime    *string  `json:"rest_time"`
+}
+
+// ReorderRequest lists every ID of a collection in its new order
+type ReorderRequest struct {
+	IDs []int `json:"ids"`
+}
+
+// ExerciseInstructions is the coaching text of an exercise, each list in display order
+type ExerciseInstructions struct {
+	Steps    []string `json:"steps"`
+	Cues     []string `json:"cues"`
+	Mistakes []string `json:"mistakes"`
+}
+
+// muscles exercises are tagged with
+const (
+	MuscleChest      = "chest"
+	MuscleBack       = "back"
+	MuscleShoulders  = "shoulders"
+	MuscleBiceps     = "biceps"
+	MuscleTriceps    = "triceps"
+	MuscleForearms   = "forearms"
+	MuscleAbs        = "abs"
+	MuscleQuads      = "quads"
+	MuscleHamstrings = "hamstrings"
+	MuscleGlutes     = "glutes"
+	MuscleCalves     = "calves"
+)
+
+// ExerciseMuscles is the muscles an exercise trains, a muscle is either
+// primary or secondary
+type ExerciseMuscles struct {
+	Primary   []string `json:"primary"`
+	Secondary []string `json:"secondary"`
+}
@@ -18,14 +18,15 @@ type WorkoutSection struct {
 }
 
 type ExerciseDetails struct {
-	ID         int    `json:"id"`
-	Name       string `json:"name"`
-	WarmupSets int    `json:"warmup_sets"`
-	WorkSets   int    `json:"working_sets"`
-	Reps       string `json:"reps"`
-	Load       int    `json:"load"`
-	RPE        string `json:"rpe"`
-	RestTime   string `json:"rest_time"`
+	ID              int     `json:"id"`
+	Name            string  `json:"name"`
+	MeasurementType string  `json:"measurement_type,omitempty"`
+	WarmupSets      int     `json:"warmup_sets"`
+	WorkSets        int     `json:"working_sets"`
+	Reps            string  `json:"reps"`
+	Load            float64 `json:"load"`
+	RPE             string  `json:"rpe"`
+	RestTime        string  `json:"rest_time"`
 }
 
 type WorkoutSectionWithExercises struct {
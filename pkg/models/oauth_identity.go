@@ -0,0 +1,13 @@
+package models
+
+// OAuthIdentity is the provider-neutral profile we get back from any identity
+// provider after login. JSON keys follow Google's userinfo response, which is
+// what the frontend was built against.
+type OAuthIdentity struct {
+	Provider      string `json:"provider"`
+	Subject       string `json:"id"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"verified_email"`
+	Name          string `json:"name"`
+	Picture       string `json:"picture,omitempty"`
+}
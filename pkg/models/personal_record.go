@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// kinds of personal records
+const (
+	// heaviest effective load of a set
+	RecordMaxLoad = "max_load"
+	// heaviest effective load for a rep count
+	RecordRepMax = "rep_max"
+	RecordE1RM   = "e1rm"
+	// reps times effective load of an entry's sets
+	RecordSessionVolume = "session_volume"
+)
+
+// PersonalRecord is a record set by a logged entry. Reps is the rep count of
+// a rep_max. PreviousValue is the record it beat, nil for the first one.
+type PersonalRecord struct {
+	ID            int       `json:"id"`
+	ExerciseID    int       `json:"exercise_id"`
+	EntryID       int       `json:"entry_id"`
+	Type          string    `json:"type"`
+	Reps          int       `json:"reps,omitempty"`
+	Value         float64   `json:"value"`
+	PreviousValue *float64  `json:"previous_value,omitempty"`
+	AchievedAt    time.Time `json:"achieved_at"`
+}
+
+// PersonalRecordHistory is every record an exercise's logs set, newest
+// first, values in Unit
+type PersonalRecordHistory struct {
+	ExerciseID int              `json:"exercise_id"`
+	Unit       string           `json:"unit"`
+	Records    []PersonalRecord `json:"records"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// load units, loads are stored in kg
+const (
+	UnitKg = "kg"
+	UnitLb = "lb"
+)
+
+// UserPreferences are the user's display and training settings. Loads in
+// responses are in Unit, prescribed loads are rounded to PlateIncrement (in
+// Unit). ProgressionRule drives next-session recommendations.
+type UserPreferences struct {
+	Unit            string  `json:"unit"`
+	PlateIncrement  float64 `json:"plate_increment"`
+	ProgressionRule string  `json:"progression_rule"`
+}
+
+// UpdatePreferencesRequest is the body of PATCH /user/preferences, nil fields
+// are left unchanged. Changing the unit resets the plate increment to the
+// unit's default unless one is sent.
+type UpdatePreferencesRequest struct {
+	Unit            *string  `json:"unit"`
+	PlateIncrement  *float64 `json:"plate_increment"`
+	ProgressionRule *string  `json:"progression_rule"`
+}
@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// Program groups workout sections into a periodized plan of LengthWeeks weeks
+type Program struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	LengthWeeks int    `json:"length_weeks"`
+}
+
+// ProgramInput is the body of the admin program endpoint
+type ProgramInput struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	LengthWeeks *int    `json:"length_weeks"`
+}
+
+// enrollment statuses, a user has at most one active or paused enrollment
+const (
+	ProgramStatusActive = "active"
+	ProgramStatusPaused = "paused"
+	ProgramStatusEnded  = "ended"
+)
+
+// UserProgram is an enrollment of a user in a program and where they are in
+// it. Days are days of the program week, day 1 is the weekday of StartDate.
+type UserProgram struct {
+	ID          int        `json:"id"`
+	Program     Program    `json:"program"`
+	Status      string     `json:"status"`
+	StartDate   string     `json:"start_date"` // YYYY-MM-DD
+	CurrentWeek int        `json:"current_week"`
+	CurrentDay  int        `json:"current_day"`
+	Completed   bool       `json:"completed"`
+	PausedAt    *time.Time `json:"paused_at,omitempty"`
+	PausedDays  int        `json:"paused_days"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+	EndReason   string     `json:"end_reason,omitempty"`
+
+	// only set for the current enrollment
+	Schedule []ScheduledSession `json:"schedule,omitempty"`
+	DueToday []ScheduledSession `json:"due_today,omitempty"`
+}
+
+// ScheduledSession is a workout section on a day of the program week
+type ScheduledSession struct {
+	SectionID int    `json:"section_id"`
+	Name      string `json:"name"`
+	Route     string `json:"route"`
+	Day       int    `json:"day"`
+}
+
+// EnrollRequest starts or switches to a program. StartDate (YYYY-MM-DD)
+// defaults to today, TrainingDays are the program days (1-7) the sections are
+// spread over and default to an even spread.
+type EnrollRequest struct {
+	ProgramID    int    `json:"program_id"`
+	StartDate    string `json:"start_date"`
+	TrainingDays []int  `json:"training_days"`
+}
+
+// RestartRequest restarts the current program from week 1, keeping its
+// schedule unless TrainingDays is set
+type RestartRequest struct {
+	StartDate    string `json:"start_date"`
+	TrainingDays []int  `json:"training_days"`
+}
@@ -0,0 +1,45 @@
+package models
+
+// progression rules of next-session recommendations
+const (
+	// add reps until every working set hits the top of the rep range, then
+	// add load and go back to the bottom
+	ProgressionDoubleProgression = "double_progression"
+	// pick the load that lands the bottom of the rep range at the prescribed
+	// RPE, from the e1RM of the last session
+	ProgressionRPE = "rpe"
+)
+
+// what a recommendation changes from the last session
+const (
+	// no session logged yet, start from the prescription
+	RecommendStart        = "start"
+	RecommendIncreaseLoad = "increase_load"
+	RecommendIncreaseReps = "increase_reps"
+	RecommendHold         = "hold"
+	RecommendDecreaseLoad = "decrease_load"
+)
+
+// Recommendation is the suggested working sets of an exercise for the next
+// session, Load in Unit. Reason explains Action to the lifter.
+type Recommendation struct {
+	ExerciseID   int     `json:"exercise_id"`
+	ExerciseName string  `json:"exercise_name"`
+	Action       string  `json:"action"`
+	Sets         int     `json:"sets"`
+	Reps         int     `json:"reps"`
+	Load         float64 `json:"load"`
+	Unit         string  `json:"unit"`
+	TargetRPE    string  `json:"target_rpe,omitempty"`
+	Reason       string  `json:"reason"`
+	LastEntryID  *int    `json:"last_entry_id,omitempty"`
+}
+
+// SessionRecommendations is the next session of a section under Rule, for
+// the user's current program week
+type SessionRecommendations struct {
+	SectionID int              `json:"section_id"`
+	Week      int              `json:"week"`
+	Rule      string           `json:"rule"`
+	Exercises []Recommendation `json:"exercises"`
+}
@@ -0,0 +1,27 @@
+package models
+
+// Role controls what a user may do, each role includes the ones below it
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleCoach Role = "coach"
+	RoleAdmin Role = "admin"
+)
+
+var roleRank = map[Role]int{
+	RoleUser:  1,
+	RoleCoach: 2,
+	RoleAdmin: 3,
+}
+
+// Valid reports whether r is a known role
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// Includes reports whether r grants at least the access of required
+func (r Role) Includes(required Role) bool {
+	return r.Valid() && roleRank[r] >= roleRank[required]
+}
@@ -0,0 +1,21 @@
+package models
+
+// StrengthTrend is an exercise's estimated one rep max over time. Points are
+// oldest first and only cover buckets with logged sessions.
+type StrengthTrend struct {
+	ExerciseID int          `json:"exercise_id"`
+	Formula    string       `json:"formula"`
+	Bucket     string       `json:"bucket"`
+	Window     int          `json:"window"`
+	Unit       string       `json:"unit"`
+	Points     []TrendPoint `json:"points"`
+}
+
+// TrendPoint is the best e1RM of the sessions of a day, week (starting on
+// Monday) or month, and the average of it over the last Window points
+type TrendPoint struct {
+	Start         string  `json:"start"`
+	E1RM          float64 `json:"e1rm"`
+	MovingAverage float64 `json:"moving_average"`
+	Sessions      int     `json:"sessions"`
+}
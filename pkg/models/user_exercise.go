@@ -2,20 +2,74 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
+// Reps and Load are the top set, derived from Sets when those are sent.
+// SubmittedAt is when the client performed the exercise, see UnmarshalJSON.
 type UserExerciseInput struct {
-	ExerciseID  int       `json:"exercise_id"`
-	Reps        int       `json:"custom_reps"`
-	Load        float64   `json:"custom_load"`
-	SubmittedAt time.Time `json:"submitted_at"`
+	ExerciseID  int               `json:"exercise_id"`
+	Reps        int               `json:"custom_reps"`
+	Load        float64           `json:"custom_load"`
+	Sets        []UserExerciseSet `json:"sets,omitempty"`
+	SubmittedAt time.Time         `json:"submitted_at"`
+	// achieved effort of a single reps/load submission
+	RPE *float64 `json:"rpe,omitempty"`
+	RIR *float64 `json:"rir,omitempty"`
+
+	// set when submitted_at was sent as a date without a time
+	SubmittedAtDateOnly bool `json:"-"`
+}
+
+// set types of a logged set
+const (
+	SetTypeWarmup  = "warmup"
+	SetTypeWorking = "working"
+	SetTypeDrop    = "drop"
+)
+
+// measurement types of an exercise, they decide what a set's load means
+const (
+	MeasurementWeighted = "weighted"
+	// load must be 0, the lifter's bodyweight is moved
+	MeasurementBodyweight = "bodyweight"
+	// load is added to the lifter's bodyweight, e.g. weighted dips
+	MeasurementBodyweightAdded = "bodyweight_added"
+	// load is the assistance taken off the lifter's bodyweight
+	MeasurementAssisted = "assisted"
+	// sets are logged with duration_seconds, e.g. planks
+	MeasurementDuration = "duration"
+	// sets are logged with distance_meters, e.g. carries
+	MeasurementDistance = "distance"
+)
+
+// UserExerciseSet is one logged set of an exercise. Bodyweight is the
+// lifter's bodyweight when the set was done, EffectiveLoad is derived from it
+// and the exercise's measurement type and is never read from requests.
+// Loads are kg in storage, LoadUnit is the unit they were entered in. RPE and
+// RIR are the achieved effort, either one is derived from the other.
+type UserExerciseSet struct {
+	SetIndex        int      `json:"set_index"`
+	SetType         string   `json:"set_type"`
+	Reps            int      `json:"reps"`
+	Load            float64  `json:"load"`
+	RPE             *float64 `json:"rpe,omitempty"`
+	RIR             *float64 `json:"rir,omitempty"`
+	RestSeconds     *int     `json:"rest_seconds,omitempty"`
+	DurationSeconds *int     `json:"duration_seconds,omitempty"`
+	DistanceMeters  *float64 `json:"distance_meters,omitempty"`
+	Bodyweight      *float64 `json:"bodyweight,omitempty"`
+	EffectiveLoad   *float64 `json:"effective_load,omitempty"`
+	LoadUnit        string   `json:"-"`
 }
 
+// Unit is the unit of the loads, the user's preferred unit when empty
 type UserExerciseRequest struct {
 	SectionID int                 `json:"section_id"`
 	Exercises []UserExerciseInput `json:"exercises"`
 	UserEmail string              `json:"user_email"`
+	Unit      string              `json:"unit"`
 }
 
 // Custom JSON marshaler for UserExerciseInput
@@ -30,11 +84,111 @@ func (u UserExerciseInput) MarshalJSON() ([]byte, error) {
 	})
 }
 
-// Response model for progress data
+// Custom JSON unmarshaler for UserExerciseInput, submitted_at may be a date
+// (yyyy-mm-dd) or an RFC 3339 timestamp with the client's UTC offset
+func (u *UserExerciseInput) UnmarshalJSON(data []byte) error {
+	type Alias UserExerciseInput
+	aux := &struct {
+		SubmittedAt *string `json:"submitted_at"`
+		*Alias
+	}{
+		Alias: (*Alias)(u),
+	}
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+
+	u.SubmittedAt, u.SubmittedAtDateOnly = time.Time{}, false
+	if aux.SubmittedAt == nil || *aux.SubmittedAt == "" {
+		return nil
+	}
+	if date, err := time.Parse("2006-01-02", *aux.SubmittedAt); err == nil {
+		u.SubmittedAt, u.SubmittedAtDateOnly = date, true
+		return nil
+	}
+	performedAt, err := time.Parse(time.RFC3339, *aux.SubmittedAt)
+	if err != nil {
+		return fmt.Errorf("submitted_at must be a date (YYYY-MM-DD) or an RFC 3339 timestamp: %w", err)
+	}
+	u.SubmittedAt = performedAt
+	return nil
+}
+
+// Response model for progress data, E1RM is the best estimated one rep max
+// of the entry's sets. Loads are in Unit.
 type UserProgressResponse struct {
-	ExerciseID   int     `json:"exercise_id"`
-	ExerciseName string  `json:"exercise_name"` //refactor to use UserExerciseInput model
-	CustomReps   int     `json:"custom_reps"`
-	CustomLoad   float64 `json:"custom_load"`
-	SubmittedAt  string  `json:"submitted_at"`
+	ID              int               `json:"id"`
+	ExerciseID      int               `json:"exercise_id"`
+	ExerciseName    string            `json:"exercise_name"` //refactor to use UserExerciseInput model
+	MeasurementType string            `json:"measurement_type"`
+	Unit            string            `json:"unit"`
+	CustomReps      int               `json:"custom_reps"`
+	CustomLoad      float64           `json:"custom_load"`
+	SubmittedAt     string            `json:"submitted_at"`
+	PerformedAt     *time.Time        `json:"performed_at,omitempty"`
+	E1RM            *float64          `json:"e1rm,omitempty"`
+	Effort          *EffortComparison `json:"effort,omitempty"`
+	Sets            []UserExerciseSet `json:"sets"`
+}
+
+// ProgressFilter narrows and pages GET /user/progress, zero values don't
+// filter. From and To are inclusive days of submitted_at.
+type ProgressFilter struct {
+	ExerciseID int
+	SectionID  int
+	From       *time.Time
+	To         *time.Time
+	Descending bool
+	Limit      int
+	After      *ProgressCursor
+}
+
+// ProgressCursor is the sort key of the last entry of a page, the next page
+// starts after it
+type ProgressCursor struct {
+	PerformedAt time.Time `json:"t"`
+	ID          int       `json:"id"`
+	Descending  bool      `json:"desc,omitempty"`
+}
+
+// ProgressPage is a page of progress entries, NextCursor is nil on the last
+// page
+type ProgressPage struct {
+	Items      []UserProgressResponse `json:"items"`
+	NextCursor *string                `json:"next_cursor"`
+}
+
+// effort verdicts of an entry against its prescribed RPE
+const (
+	// easier than prescribed, e.g. sandbagging
+	EffortBelow    = "below"
+	EffortOnTarget = "on_target"
+	// harder than prescribed, e.g. overreaching
+	EffortAbove = "above"
+)
+
+// EffortComparison is the prescription of an entry's program week when it
+// was logged against the achieved effort, the average RPE of its working sets.
+// Verdict is empty when either side is unknown.
+type EffortComparison struct {
+	PrescribedReps string   `json:"prescribed_reps,omitempty"`
+	PrescribedRPE  string   `json:"prescribed_rpe,omitempty"`
+	AchievedRPE    *float64 `json:"achieved_rpe,omitempty"`
+	Verdict        string   `json:"verdict,omitempty"`
+}
+
+// UpdateEntryRequest edits a logged entry. Sets replace all of its sets,
+// custom_reps and custom_load edit an entry logged with a single set. Unit is
+// the unit of the loads, the user's preferred unit when empty.
+type UpdateEntryRequest struct {
+	Reps *int              `json:"custom_reps"`
+	Load *float64          `json:"custom_load"`
+	Sets []UserExerciseSet `json:"sets"`
+	Unit string            `json:"unit"`
+}
+
+// DeletedEntry is a soft deleted entry, it can be restored until RestorableUntil
+type DeletedEntry struct {
+	ID              int       `json:"id"`
+	RestorableUntil time.Time `json:"restorable_until"`
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// UserIdentity is a provider account linked to a user, as shown to the user
+type UserIdentity struct {
+	ID            int       `json:"id"`
+	Provider      string    `json:"provider"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	LastLoginAt   time.Time `json:"last_login_at"`
+}
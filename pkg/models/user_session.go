@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// UserSession is a login on one device, as shown to the user
+type UserSession struct {
+	ID         int       `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
@@ -0,0 +1,26 @@
+package models
+
+// WeeklySummary is the training volume of a week starting on Monday, tonnage
+// in Unit. Muscles only lists muscles of the exercises logged that week.
+type WeeklySummary struct {
+	WeekStart string         `json:"week_start"`
+	Unit      string         `json:"unit"`
+	Total     VolumeSummary  `json:"total"`
+	Muscles   []MuscleVolume `json:"muscles"`
+}
+
+// VolumeSummary counts the non-warmup sets of a week. Tonnage is reps times
+// effective load, hard sets are sets of RPE 7 or more, or without an RPE.
+type VolumeSummary struct {
+	Sets     float64 `json:"sets"`
+	Reps     float64 `json:"reps"`
+	Tonnage  float64 `json:"tonnage"`
+	HardSets float64 `json:"hard_sets"`
+}
+
+// MuscleVolume is the volume a muscle got, sets of exercises it is a
+// secondary muscle of count as half
+type MuscleVolume struct {
+	Muscle string `json:"muscle"`
+	VolumeSummary
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// WorkoutSession is one workout of a section, from start to finish
+type WorkoutSession struct {
+	ID              int                    `json:"id"`
+	SectionID       int                    `json:"section_id"`
+	SectionName     string                 `json:"section_name"`
+	StartedAt       time.Time              `json:"started_at"`
+	FinishedAt      *time.Time             `json:"finished_at"`
+	DurationSeconds *int                   `json:"duration_seconds"`
+	SessionRPE      *float64               `json:"session_rpe"`
+	Notes           string                 `json:"notes"`
+	Exercises       []UserProgressResponse `json:"exercises"`
+}
+
+// StartSessionRequest is the body of the start session endpoint
+type StartSessionRequest struct {
+	SectionID int    `json:"section_id"`
+	Notes     string `json:"notes"`
+}
+
+// AddSetsRequest appends sets of an exercise to a session. Set indexes count
+// from the sets already logged for the exercise in that session. Unit is the
+// unit of the loads, the user's preferred unit when empty. PerformedAt is an
+// RFC 3339 timestamp with the client's UTC offset, e.g. for sets synced after
+// training offline. Without it the sets were performed now.
+type AddSetsRequest struct {
+	ExerciseID  int               `json:"exercise_id"`
+	Sets        []UserExerciseSet `json:"sets"`
+	Unit        string            `json:"unit"`
+	PerformedAt *time.Time        `json:"performed_at"`
+}
+
+// LoggedSets is the session entry sets were logged to, with the personal
+// records they set
+type LoggedSets struct {
+	UserProgressResponse
+	NewRecords []PersonalRecord `json:"new_records"`
+}
+
+// FinishSessionRequest is the body of the finish session endpoint
+type FinishSessionRequest struct {
+	SessionRPE *float64 `json:"session_rpe"`
+	Notes      *string  `json:"notes"`
+}
+
+// ProgressSession groups progress entries by session. Entries submitted
+// without a session are grouped by section and day and have no SessionID.
+// Key is the group's sort key, when it started and its first entry.
+type ProgressSession struct {
+	SessionID       *int                   `json:"session_id"`
+	SectionID       int                    `json:"section_id"`
+	Date            string                 `json:"date"`
+	StartedAt       *time.Time             `json:"started_at,omitempty"`
+	FinishedAt      *time.Time             `json:"finished_at,omitempty"`
+	DurationSeconds *int                   `json:"duration_seconds,omitempty"`
+	SessionRPE      *float64               `json:"session_rpe,omitempty"`
+	Notes           string                 `json:"notes,omitempty"`
+	Entries         []UserProgressResponse `json:"entries"`
+	Key             ProgressCursor         `json:"-"`
+}
+
+// ProgressSessionPage is a page of session groups, NextCursor is nil on the
+// last page
+type ProgressSessionPage struct {
+	Items      []ProgressSession `json:"items"`
+	NextCursor *string           `json:"next_cursor"`
+}
@@ -0,0 +1,77 @@
+package training
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+)
+
+// RPE of a set left with rir reps in reserve
+func RPEFromRIR(rir float64) float64 {
+	return 10 - rir
+}
+
+// reps in reserve of a set at rpe
+func RIRFromRPE(rpe float64) float64 {
+	return 10 - rpe
+}
+
+// parses a prescribed RPE such as "8", "9-10" or "~8-9" into its range
+func ParseRPERange(rpe string) (float64, float64, bool) {
+	rpe = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(rpe), "~"))
+	low, high, isRange := strings.Cut(rpe, "-")
+	if !isRange {
+		high = low
+	}
+	lo, err := strconv.ParseFloat(strings.TrimSpace(low), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	hi, err := strconv.ParseFloat(strings.TrimSpace(high), 64)
+	if err != nil || hi < lo {
+		return 0, 0, false
+	}
+	return lo, hi, true
+}
+
+// the average RPE of the working sets, of all sets when no working set has
+// one. Returns false when no set has an RPE.
+func AchievedRPE(sets []models.UserExerciseSet) (float64, bool) {
+	for _, onlyWorking := range []bool{true, false} {
+		var sum float64
+		var count int
+		for _, set := range sets {
+			if set.RPE == nil || (onlyWorking && set.SetType != models.SetTypeWorking) {
+				continue
+			}
+			sum += *set.RPE
+			count++
+		}
+		if count > 0 {
+			return math.Round(sum/float64(count)*10) / 10, true
+		}
+	}
+	return 0, false
+}
+
+// fills in the achieved RPE of effort and how it compares to the prescribed one
+func CompareEffort(effort *models.EffortComparison, sets []models.UserExerciseSet) {
+	achieved, ok := AchievedRPE(sets)
+	if !ok {
+		return
+	}
+	effort.AchievedRPE = &achieved
+
+	lo, hi, ok := ParseRPERange(effort.PrescribedRPE)
+	switch {
+	case !ok:
+	case achieved < lo:
+		effort.Verdict = models.EffortBelow
+	case achieved > hi:
+		effort.Verdict = models.EffortAbove
+	default:
+		effort.Verdict = models.EffortOnTarget
+	}
+}
@@ -0,0 +1,180 @@
+package training
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+)
+
+// Prescription is what the program asks of an exercise next session, Load in
+// kg. Reps and RPE are as written in ExerciseDetails, e.g. "8-10" and "9-10".
+type Prescription struct {
+	MeasurementType string
+	Sets            int
+	Reps            string
+	RPE             string
+	Load            float64
+}
+
+// ProgressionRule suggests the next session of an exercise from its
+// prescription and its last logged entry, nil when there is none. Loads are
+// in kg, increment is the smallest load step.
+type ProgressionRule interface {
+	Recommend(prescription Prescription, last *models.UserProgressResponse, increment float64) models.Recommendation
+}
+
+var progressionRules = map[string]ProgressionRule{
+	models.ProgressionDoubleProgression: DoubleProgression{},
+	models.ProgressionRPE:               RPEAutoregulation{},
+}
+
+// the progression rule called name, false when there is none
+func ProgressionRuleFor(name string) (ProgressionRule, bool) {
+	rule, ok := progressionRules[name]
+	return rule, ok
+}
+
+var repRangePattern = regexp.MustCompile(`^\D*(\d+)(?:\s*[-–]\s*(\d+))?`)
+
+// parses prescribed reps such as "8", "8-10" or "~10-12 each" into their range
+func ParseRepRange(reps string) (int, int, bool) {
+	match := repRangePattern.FindStringSubmatch(reps)
+	if match == nil {
+		return 0, 0, false
+	}
+	lo, _ := strconv.Atoi(match[1])
+	hi := lo
+	if match[2] != "" {
+		hi, _ = strconv.Atoi(match[2])
+	}
+	if lo <= 0 || hi < lo {
+		return 0, 0, false
+	}
+	return lo, hi, true
+}
+
+// DoubleProgression adds reps until every working set reaches the top of
+// the rep range, then adds a load step and starts again at the bottom. A
+// working set below the range takes a step off. Bodyweight exercises have no
+// load to add and keep adding reps.
+type DoubleProgression struct{}
+
+func (DoubleProgression) Recommend(prescription Prescription, last *models.UserProgressResponse, increment float64) models.Recommendation {
+	rec := models.Recommendation{Sets: prescription.Sets, TargetRPE: prescription.RPE}
+	lo, hi, ok := ParseRepRange(prescription.Reps)
+	working := workingSets(last)
+	if len(working) == 0 {
+		rec.Action, rec.Reps, rec.Load = models.RecommendStart, lo, prescription.Load
+		rec.Reason = "No session logged yet, start with the prescription"
+		return rec
+	}
+
+	top, _ := TopSet(prescription.MeasurementType, working)
+	fewest := top.Reps
+	for _, set := range working {
+		if set.Load == top.Load {
+			fewest = min(fewest, set.Reps)
+		}
+	}
+	rec.Reps, rec.Load = fewest, top.Load
+	bodyweight := prescription.MeasurementType == models.MeasurementBodyweight
+
+	switch {
+	case !ok:
+		rec.Action = models.RecommendHold
+		rec.Reason = "The prescription has no rep range to progress through, repeat the last session"
+	case fewest >= hi && bodyweight:
+		rec.Action, rec.Reps = models.RecommendIncreaseReps, fewest+1
+		rec.Reason = fmt.Sprintf("Every working set reached %d reps, keep adding reps", hi)
+	case fewest >= hi:
+		rec.Action, rec.Reps, rec.Load = models.RecommendIncreaseLoad, lo, stepLoad(prescription.MeasurementType, top.Load, increment)
+		rec.Reason = fmt.Sprintf("Every working set reached %d reps, add load and start again at %d", hi, lo)
+	case fewest < lo && bodyweight:
+		rec.Action, rec.Reps = models.RecommendHold, lo
+		rec.Reason = fmt.Sprintf("A working set fell below %d reps, aim for %d again", lo, lo)
+	case fewest < lo:
+		rec.Action, rec.Reps, rec.Load = models.RecommendDecreaseLoad, lo, stepLoad(prescription.MeasurementType, top.Load, -increment)
+		rec.Reason = fmt.Sprintf("A working set fell below %d reps, take off load", lo)
+	default:
+		rec.Action, rec.Reps = models.RecommendIncreaseReps, min(fewest+1, hi)
+		rec.Reason = fmt.Sprintf("Add a rep to every working set, up to %d", hi)
+	}
+	return rec
+}
+
+// RPEAutoregulation estimates the e1RM of the last top set from its reps and
+// RPE, and picks the load that lands the bottom of the rep range at the
+// middle of the prescribed RPE. Without a prescribed or logged RPE, or for
+// exercises not loaded with weight alone, it falls back to double
+// progression.
+type RPEAutoregulation struct{}
+
+func (RPEAutoregulation) Recommend(prescription Prescription, last *models.UserProgressResponse, increment float64) models.Recommendation {
+	lo, _, repsOK := ParseRepRange(prescription.Reps)
+	rpeLo, rpeHi, rpeOK := ParseRPERange(prescription.RPE)
+	working := workingSets(last)
+	achieved, achievedOK := AchievedRPE(working)
+	if len(working) == 0 || !repsOK || !rpeOK || !achievedOK || prescription.MeasurementType != models.MeasurementWeighted {
+		rec := DoubleProgression{}.Recommend(prescription, last, increment)
+		if rec.Action != models.RecommendStart {
+			rec.Reason += ". RPE autoregulation needs a prescribed and a logged RPE on a weighted exercise, so this follows the rep range"
+		}
+		return rec
+	}
+
+	top, _ := TopSet(prescription.MeasurementType, working)
+	// Epley with the reps left in reserve counted as done
+	e1rm := top.Load * (1 + (float64(top.Reps)+RIRFromRPE(achieved))/30)
+	target := (rpeLo + rpeHi) / 2
+	load := RoundToPlates(e1rm/(1+(float64(lo)+RIRFromRPE(target))/30), increment)
+
+	rec := models.Recommendation{
+		Sets:      prescription.Sets,
+		Reps:      lo,
+		Load:      load,
+		TargetRPE: prescription.RPE,
+		Reason: fmt.Sprintf("Your last top set of %d reps was about RPE %g, this load should give %d reps at RPE %s",
+			top.Reps, achieved, lo, prescription.RPE),
+	}
+	switch {
+	case load > top.Load:
+		rec.Action = models.RecommendIncreaseLoad
+	case load < top.Load:
+		rec.Action = models.RecommendDecreaseLoad
+	default:
+		rec.Action = models.RecommendHold
+	}
+	return rec
+}
+
+// the working sets of last, its non-warmup sets when none is a working set
+func workingSets(last *models.UserProgressResponse) []models.UserExerciseSet {
+	if last == nil {
+		return nil
+	}
+	var working, other []models.UserExerciseSet
+	for _, set := range last.Sets {
+		switch set.SetType {
+		case models.SetTypeWorking:
+			working = append(working, set)
+		case models.SetTypeWarmup:
+		default:
+			other = append(other, set)
+		}
+	}
+	if len(working) == 0 {
+		return other
+	}
+	return working
+}
+
+// moves load one step harder (step > 0) or easier. Less assistance is
+// harder for assisted exercises.
+func stepLoad(measurementType string, load, step float64) float64 {
+	if measurementType == models.MeasurementAssisted {
+		step = -step
+	}
+	return max(load+step, 0)
+}
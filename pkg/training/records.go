@@ -0,0 +1,40 @@
+package training
+
+import "github.com/haikali3/gymbara-backend/pkg/models"
+
+// the personal records sets could set: their heaviest load, the heaviest
+// load per rep count, their best Epley e1RM and their volume. Warmup sets and
+// sets without an effective load don't count, so exercises measured by
+// duration or distance have none. Mirrored in SQL by the PersonalRecords
+// migration.
+func RecordCandidates(measurementType string, sets []models.UserExerciseSet) []models.PersonalRecord {
+	var counted []models.UserExerciseSet
+	var maxLoad, volume float64
+	repMaxes := map[int]float64{}
+	for _, set := range sets {
+		load, ok := EffectiveLoad(measurementType, set)
+		if !ok || set.SetType == models.SetTypeWarmup || set.Reps <= 0 {
+			continue
+		}
+		counted = append(counted, set)
+		maxLoad = max(maxLoad, load)
+		volume += float64(set.Reps) * load
+		if set.Reps <= maxE1RMReps {
+			repMaxes[set.Reps] = max(repMaxes[set.Reps], load)
+		}
+	}
+
+	var records []models.PersonalRecord
+	add := func(recordType string, reps int, value float64) {
+		if value > 0 {
+			records = append(records, models.PersonalRecord{Type: recordType, Reps: reps, Value: value})
+		}
+	}
+	add(models.RecordMaxLoad, 0, maxLoad)
+	for reps := 1; reps <= maxE1RMReps; reps++ {
+		add(models.RecordRepMax, reps, repMaxes[reps])
+	}
+	add(models.RecordE1RM, 0, BestE1RM(FormulaEpley, measurementType, counted))
+	add(models.RecordSessionVolume, 0, volume)
+	return records
+}
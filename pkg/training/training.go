@@ -0,0 +1,123 @@
+// Package training holds the lifting calculations shared by logging and
+// progress: effective load per measurement type, top sets and estimated one
+// rep maxes.
+package training
+
+import "github.com/haikali3/gymbara-backend/pkg/models"
+
+// sets with more reps than this are left out of e1RM, the formulas drift
+// too far from a true max
+const maxE1RMReps = 12
+
+// the load a set moved, taking the lifter's bodyweight into account. Returns
+// false when it is unknown: the set lacks a bodyweight that it needs, or the
+// exercise is measured by duration or distance.
+func EffectiveLoad(measurementType string, set models.UserExerciseSet) (float64, bool) {
+	switch measurementType {
+	case models.MeasurementWeighted:
+		return set.Load, true
+	case models.MeasurementBodyweight, models.MeasurementBodyweightAdded:
+		if set.Bodyweight == nil {
+			return 0, false
+		}
+		return *set.Bodyweight + set.Load, true
+	case models.MeasurementAssisted:
+		if set.Bodyweight == nil {
+			return 0, false
+		}
+		return max(*set.Bodyweight-set.Load, 0), true
+	default:
+		return 0, false
+	}
+}
+
+// Epley's estimate of the one rep max from a set of reps at load
+func EstimateOneRepMax(load float64, reps int) float64 {
+	return EstimateOneRepMaxWith(FormulaEpley, load, reps)
+}
+
+// the best set of sets, only working sets count when there are any. Sets are
+// ranked by what the measurement type measures, then by reps. Less
+// assistance is a better assisted set. Mirrored in SQL by the database
+// package's updateTopSet.
+func TopSet(measurementType string, sets []models.UserExerciseSet) (models.UserExerciseSet, bool) {
+	var top models.UserExerciseSet
+	found := false
+	for _, onlyWorking := range []bool{true, false} {
+		for _, set := range sets {
+			if onlyWorking && set.SetType != models.SetTypeWorking {
+				continue
+			}
+			score, topScore := setScore(measurementType, set), setScore(measurementType, top)
+			if !found || score > topScore || (score == topScore && set.Reps > top.Reps) {
+				top, found = set, true
+			}
+		}
+		if found {
+			break
+		}
+	}
+	return top, found
+}
+
+func setScore(measurementType string, set models.UserExerciseSet) float64 {
+	switch measurementType {
+	case models.MeasurementDuration:
+		if set.DurationSeconds == nil {
+			return 0
+		}
+		return float64(*set.DurationSeconds)
+	case models.MeasurementDistance:
+		if set.DistanceMeters == nil {
+			return 0
+		}
+		return *set.DistanceMeters
+	case models.MeasurementAssisted:
+		return -set.Load
+	default:
+		return set.Load
+	}
+}
+
+// fills in the effective load of each set of entry, the entry's Epley e1RM
+// (see BestE1RM) and its achieved effort
+func AnnotateEntry(entry *models.UserProgressResponse) {
+	if _, ok := AchievedRPE(entry.Sets); ok && entry.Effort == nil {
+		entry.Effort = &models.EffortComparison{}
+	}
+	if entry.Effort != nil {
+		CompareEffort(entry.Effort, entry.Sets)
+	}
+
+	for i := range entry.Sets {
+		if load, ok := EffectiveLoad(entry.MeasurementType, entry.Sets[i]); ok {
+			entry.Sets[i].EffectiveLoad = &load
+		}
+	}
+	if best := BestE1RM(FormulaEpley, entry.MeasurementType, entry.Sets); best > 0 {
+		entry.E1RM = &best
+	}
+}
+
+// the best e1RM by formula of the working sets, or of all sets when no
+// working set has one. 0 when no set has one.
+func BestE1RM(formula, measurementType string, sets []models.UserExerciseSet) float64 {
+	var bestWorking, bestAny float64
+	for _, set := range sets {
+		load, ok := EffectiveLoad(measurementType, set)
+		if !ok || set.Reps > maxE1RMReps {
+			continue
+		}
+
+		e1rm := EstimateOneRepMaxWith(formula, load, set.Reps)
+		bestAny = max(bestAny, e1rm)
+		if set.SetType == models.SetTypeWorking {
+			bestWorking = max(bestWorking, e1rm)
+		}
+	}
+
+	if bestWorking > 0 {
+		return bestWorking
+	}
+	return bestAny
+}
@@ -0,0 +1,396 @@
+package training
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestEffectiveLoad(t *testing.T) {
+	tests := []struct {
+		name            string
+		measurementType string
+		set             models.UserExerciseSet
+		want            float64
+		wantOK          bool
+	}{
+		{"weighted", models.MeasurementWeighted, models.UserExerciseSet{Load: 100}, 100, true},
+		{"bodyweight", models.MeasurementBodyweight, models.UserExerciseSet{Bodyweight: ptr(80.0)}, 80, true},
+		{"bodyweight without bodyweight", models.MeasurementBodyweight, models.UserExerciseSet{}, 0, false},
+		{"bodyweight added", models.MeasurementBodyweightAdded, models.UserExerciseSet{Load: 20, Bodyweight: ptr(80.0)}, 100, true},
+		{"assisted", models.MeasurementAssisted, models.UserExerciseSet{Load: 30, Bodyweight: ptr(80.0)}, 50, true},
+		{"assisted beyond bodyweight", models.MeasurementAssisted, models.UserExerciseSet{Load: 90, Bodyweight: ptr(80.0)}, 0, true},
+		{"duration", models.MeasurementDuration, models.UserExerciseSet{Load: 10, DurationSeconds: ptr(60)}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := EffectiveLoad(tt.measurementType, tt.set)
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("EffectiveLoad() = (%v, %v), want (%v, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEstimateOneRepMax(t *testing.T) {
+	if got := EstimateOneRepMax(100, 1); got != 100 {
+		t.Fatalf("EstimateOneRepMax(100, 1) = %v, want 100", got)
+	}
+	if got := EstimateOneRepMax(100, 10); math.Abs(got-133.33) > 0.01 {
+		t.Fatalf("EstimateOneRepMax(100, 10) = %v, want 133.33", got)
+	}
+	if got := EstimateOneRepMax(100, 0); got != 0 {
+		t.Fatalf("EstimateOneRepMax(100, 0) = %v, want 0", got)
+	}
+}
+
+func TestTopSet(t *testing.T) {
+	assisted := []models.UserExerciseSet{
+		{SetIndex: 1, SetType: models.SetTypeWorking, Reps: 8, Load: 40},
+		{SetIndex: 2, SetType: models.SetTypeWorking, Reps: 6, Load: 20},
+	}
+	if top, _ := TopSet(models.MeasurementAssisted, assisted); top.SetIndex != 2 {
+		t.Fatalf("TopSet(assisted) = set %d, want the set with less assistance", top.SetIndex)
+	}
+
+	planks := []models.UserExerciseSet{
+		{SetIndex: 1, SetType: models.SetTypeWorking, DurationSeconds: ptr(45)},
+		{SetIndex: 2, SetType: models.SetTypeWorking, DurationSeconds: ptr(60)},
+	}
+	if top, _ := TopSet(models.MeasurementDuration, planks); top.SetIndex != 2 {
+		t.Fatalf("TopSet(duration) = set %d, want the longest set", top.SetIndex)
+	}
+
+	pullups := []models.UserExerciseSet{
+		{SetIndex: 1, SetType: models.SetTypeWorking, Reps: 8},
+		{SetIndex: 2, SetType: models.SetTypeWorking, Reps: 10},
+	}
+	if top, _ := TopSet(models.MeasurementBodyweight, pullups); top.SetIndex != 2 {
+		t.Fatalf("TopSet(bodyweight) = set %d, want the set with most reps", top.SetIndex)
+	}
+
+	if _, found := TopSet(models.MeasurementWeighted, nil); found {
+		t.Fatal("TopSet() of no sets found a set")
+	}
+}
+
+func TestAnnotateEntry(t *testing.T) {
+	entry := models.UserProgressResponse{
+		MeasurementType: models.MeasurementBodyweightAdded,
+		Sets: []models.UserExerciseSet{
+			{SetIndex: 1, SetType: models.SetTypeWarmup, Reps: 1, Load: 60, Bodyweight: ptr(80.0)},
+			{SetIndex: 2, SetType: models.SetTypeWorking, Reps: 5, Load: 20, Bodyweight: ptr(80.0)},
+		},
+	}
+	AnnotateEntry(&entry)
+
+	if entry.Sets[1].EffectiveLoad == nil || *entry.Sets[1].EffectiveLoad != 100 {
+		t.Fatalf("effective load = %v, want 100", entry.Sets[1].EffectiveLoad)
+	}
+	// the heavier warmup single doesn't count while there is a working set
+	if entry.E1RM == nil || math.Abs(*entry.E1RM-116.67) > 0.01 {
+		t.Fatalf("e1RM = %v, want 116.67", entry.E1RM)
+	}
+
+	timed := models.UserProgressResponse{
+		MeasurementType: models.MeasurementDuration,
+		Sets:            []models.UserExerciseSet{{SetIndex: 1, SetType: models.SetTypeWorking, DurationSeconds: ptr(60)}},
+	}
+	AnnotateEntry(&timed)
+	if timed.E1RM != nil || timed.Sets[0].EffectiveLoad != nil {
+		t.Fatal("a timed exercise has no effective load or e1RM")
+	}
+}
+
+func TestUnits(t *testing.T) {
+	// a load entered in lb comes back as entered
+	if got := FromKg(ToKg(135, models.UnitLb), models.UnitLb); got != 135 {
+		t.Fatalf("FromKg(ToKg(135 lb)) = %v, want 135", got)
+	}
+	if got := FromKg(100, models.UnitLb); got != 220.46 {
+		t.Fatalf("FromKg(100, lb) = %v, want 220.46", got)
+	}
+	if got := FromKg(61.25, models.UnitKg); got != 61.25 {
+		t.Fatalf("FromKg(61.25, kg) = %v, want 61.25", got)
+	}
+
+	tests := []struct {
+		load, increment, want float64
+	}{
+		{61.3, 2.5, 62.5},
+		{61.2, 2.5, 60},
+		{220.46, 5, 220},
+		{132.28, 2.5, 132.5},
+		{47, 0, 47},
+	}
+	for _, tt := range tests {
+		if got := RoundToPlates(tt.load, tt.increment); got != tt.want {
+			t.Fatalf("RoundToPlates(%v, %v) = %v, want %v", tt.load, tt.increment, got, tt.want)
+		}
+	}
+}
+
+func TestParseRPERange(t *testing.T) {
+	tests := []struct {
+		rpe    string
+		lo, hi float64
+		ok     bool
+	}{
+		{"9-10", 9, 10, true},
+		{"~8-9", 8, 9, true},
+		{"8", 8, 8, true},
+		{"7.5 - 8", 7.5, 8, true},
+		{"N/A", 0, 0, false},
+		{"10-9", 0, 0, false},
+	}
+	for _, tt := range tests {
+		lo, hi, ok := ParseRPERange(tt.rpe)
+		if lo != tt.lo || hi != tt.hi || ok != tt.ok {
+			t.Fatalf("ParseRPERange(%q) = (%v, %v, %v), want (%v, %v, %v)", tt.rpe, lo, hi, ok, tt.lo, tt.hi, tt.ok)
+		}
+	}
+}
+
+func TestCompareEffort(t *testing.T) {
+	sets := []models.UserExerciseSet{
+		{SetType: models.SetTypeWarmup, RPE: ptr(5.0)},
+		{SetType: models.SetTypeWorking, RPE: ptr(7.0)},
+		{SetType: models.SetTypeWorking, RPE: ptr(8.0)},
+	}
+	tests := []struct {
+		prescribed string
+		want       string
+	}{
+		{"9-10", models.EffortBelow},
+		{"7-8", models.EffortOnTarget},
+		{"6", models.EffortAbove},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		effort := models.EffortComparison{PrescribedRPE: tt.prescribed}
+		CompareEffort(&effort, sets)
+		if effort.AchievedRPE == nil || *effort.AchievedRPE != 7.5 || effort.Verdict != tt.want {
+			t.Fatalf("CompareEffort(%q) = (%v, %q), want (7.5, %q)", tt.prescribed, effort.AchievedRPE, effort.Verdict, tt.want)
+		}
+	}
+}
+
+func TestEstimateOneRepMaxWith(t *testing.T) {
+	tests := []struct {
+		formula string
+		want    float64
+	}{
+		{FormulaEpley, 133.33},
+		{FormulaBrzycki, 133.33},
+		{FormulaLombardi, 125.89},
+	}
+	for _, tt := range tests {
+		if got := EstimateOneRepMaxWith(tt.formula, 100, 10); math.Abs(got-tt.want) > 0.01 {
+			t.Errorf("EstimateOneRepMaxWith(%q, 100, 10) = %v, want %v", tt.formula, got, tt.want)
+		}
+	}
+	if got := EstimateOneRepMaxWith(FormulaBrzycki, 100, 1); got != 100 {
+		t.Errorf("EstimateOneRepMaxWith(brzycki, 100, 1) = %v, want 100", got)
+	}
+	if got := EstimateOneRepMaxWith("mayhew", 100, 10); got != 0 || IsFormula("mayhew") {
+		t.Errorf("unknown formula should not estimate, got %v", got)
+	}
+}
+
+func TestBucketStart(t *testing.T) {
+	sunday := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	tests := map[string]string{
+		BucketDay:   "2026-10-18",
+		BucketWeek:  "2026-10-12",
+		BucketMonth: "2026-10-01",
+	}
+	for bucket, want := range tests {
+		if got := BucketStart(sunday, bucket).Format("2006-01-02"); got != want {
+			t.Errorf("BucketStart(%s, %q) = %s, want %s", sunday.Format("2006-01-02"), bucket, got, want)
+		}
+	}
+}
+
+func TestTrend(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 0, 0, 0, 0, time.UTC) }
+	samples := []E1RMSample{
+		{Date: day(14), E1RM: 110},
+		{Date: day(5), E1RM: 100},
+		{Date: day(7), E1RM: 104},
+		{Date: day(13), E1RM: 0},
+		{Date: day(21), E1RM: 120},
+	}
+	points := Trend(samples, BucketWeek, 2)
+	if len(points) != 3 {
+		t.Fatalf("Trend() = %+v, want 3 weeks", points)
+	}
+	want := []models.TrendPoint{
+		{Start: "2026-10-05", E1RM: 104, MovingAverage: 104, Sessions: 2},
+		{Start: "2026-10-12", E1RM: 110, MovingAverage: 107, Sessions: 1},
+		{Start: "2026-10-19", E1RM: 120, MovingAverage: 115, Sessions: 1},
+	}
+	for i := range want {
+		if points[i] != want[i] {
+			t.Errorf("Trend()[%d] = %+v, want %+v", i, points[i], want[i])
+		}
+	}
+}
+
+func TestRecordCandidates(t *testing.T) {
+	sets := []models.UserExerciseSet{
+		{SetType: models.SetTypeWarmup, Reps: 5, Load: 120},
+		{SetType: models.SetTypeWorking, Reps: 5, Load: 100},
+		{SetType: models.SetTypeWorking, Reps: 3, Load: 110},
+		{SetType: models.SetTypeDrop, Reps: 20, Load: 50},
+	}
+	records := RecordCandidates(models.MeasurementWeighted, sets)
+	want := map[string]float64{
+		"max_load/0":       110,
+		"rep_max/3":        110,
+		"rep_max/5":        100,
+		"e1rm/0":           EstimateOneRepMax(110, 3),
+		"session_volume/0": 5*100 + 3*110 + 20*50,
+	}
+	if len(records) != len(want) {
+		t.Fatalf("RecordCandidates() = %+v, want %d records", records, len(want))
+	}
+	for _, record := range records {
+		key := fmt.Sprintf("%s/%d", record.Type, record.Reps)
+		if math.Abs(record.Value-want[key]) > 1e-9 {
+			t.Errorf("record %s = %v, want %v", key, record.Value, want[key])
+		}
+	}
+
+	if records := RecordCandidates(models.MeasurementDuration, []models.UserExerciseSet{{Reps: 1, DurationSeconds: ptr(60)}}); len(records) != 0 {
+		t.Errorf("duration exercises should set no records, got %+v", records)
+	}
+}
+
+func TestWeeklySummaries(t *testing.T) {
+	rpe6 := 6.0
+	entries := []models.UserProgressResponse{
+		{ExerciseID: 1, MeasurementType: models.MeasurementWeighted, SubmittedAt: "2026-10-13", Sets: []models.UserExerciseSet{
+			{SetType: models.SetTypeWarmup, Reps: 10, Load: 40},
+			{SetType: models.SetTypeWorking, Reps: 8, Load: 80},
+			{SetType: models.SetTypeWorking, Reps: 8, Load: 80, RPE: &rpe6},
+		}},
+		{ExerciseID: 2, MeasurementType: models.MeasurementWeighted, SubmittedAt: "2026-10-20", Sets: []models.UserExerciseSet{
+			{SetType: models.SetTypeWorking, Reps: 10, Load: 20},
+		}},
+	}
+	muscles := map[int]models.ExerciseMuscles{
+		1: {Primary: []string{models.MuscleChest}, Secondary: []string{models.MuscleTriceps}},
+		2: {Primary: []string{models.MuscleTriceps}},
+	}
+
+	summaries := WeeklySummaries(entries, muscles, time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC), 2)
+	if len(summaries) != 2 || summaries[0].WeekStart != "2026-10-12" || summaries[1].WeekStart != "2026-10-19" {
+		t.Fatalf("WeeklySummaries() weeks = %+v", summaries)
+	}
+	if want := (models.VolumeSummary{Sets: 2, Reps: 16, Tonnage: 1280, HardSets: 1}); summaries[0].Total != want {
+		t.Errorf("week 1 total = %+v, want %+v", summaries[0].Total, want)
+	}
+	wantMuscles := []models.MuscleVolume{
+		{Muscle: models.MuscleChest, VolumeSummary: models.VolumeSummary{Sets: 2, Reps: 16, Tonnage: 1280, HardSets: 1}},
+		{Muscle: models.MuscleTriceps, VolumeSummary: models.VolumeSummary{Sets: 1, Reps: 8, Tonnage: 640, HardSets: 0.5}},
+	}
+	if len(summaries[0].Muscles) != len(wantMuscles) {
+		t.Fatalf("week 1 muscles = %+v, want %+v", summaries[0].Muscles, wantMuscles)
+	}
+	for i := range wantMuscles {
+		if summaries[0].Muscles[i] != wantMuscles[i] {
+			t.Errorf("week 1 muscle %d = %+v, want %+v", i, summaries[0].Muscles[i], wantMuscles[i])
+		}
+	}
+	if len(summaries[1].Muscles) != 1 || summaries[1].Muscles[0].HardSets != 1 {
+		t.Errorf("week 2 muscles = %+v", summaries[1].Muscles)
+	}
+}
+
+func TestParseRepRange(t *testing.T) {
+	tests := []struct {
+		reps   string
+		lo, hi int
+		ok     bool
+	}{
+		{"8", 8, 8, true},
+		{"8-10", 8, 10, true},
+		{"~10-12 each", 10, 12, true},
+		{"12 - 15", 12, 15, true},
+		{"AMRAP", 0, 0, false},
+		{"10-8", 0, 0, false},
+	}
+	for _, tt := range tests {
+		lo, hi, ok := ParseRepRange(tt.reps)
+		if lo != tt.lo || hi != tt.hi || ok != tt.ok {
+			t.Errorf("ParseRepRange(%q) = %d, %d, %v, want %d, %d, %v", tt.reps, lo, hi, ok, tt.lo, tt.hi, tt.ok)
+		}
+	}
+}
+
+func TestDoubleProgression(t *testing.T) {
+	working := func(measurementType string, load float64, reps ...int) *models.UserProgressResponse {
+		entry := &models.UserProgressResponse{MeasurementType: measurementType, Sets: []models.UserExerciseSet{
+			{SetType: models.SetTypeWarmup, Reps: 10, Load: load / 2},
+		}}
+		for _, r := range reps {
+			entry.Sets = append(entry.Sets, models.UserExerciseSet{SetType: models.SetTypeWorking, Reps: r, Load: load})
+		}
+		return entry
+	}
+	prescription := Prescription{MeasurementType: models.MeasurementWeighted, Sets: 3, Reps: "8-10", Load: 60}
+	assisted := prescription
+	assisted.MeasurementType = models.MeasurementAssisted
+	bodyweight := prescription
+	bodyweight.MeasurementType = models.MeasurementBodyweight
+
+	tests := []struct {
+		name         string
+		prescription Prescription
+		last         *models.UserProgressResponse
+		action       string
+		reps         int
+		load         float64
+	}{
+		{"no session yet", prescription, nil, models.RecommendStart, 8, 60},
+		{"top of range", prescription, working(models.MeasurementWeighted, 80, 10, 10, 10), models.RecommendIncreaseLoad, 8, 82.5},
+		{"inside range", prescription, working(models.MeasurementWeighted, 80, 10, 9, 8), models.RecommendIncreaseReps, 9, 80},
+		{"below range", prescription, working(models.MeasurementWeighted, 80, 8, 7, 6), models.RecommendDecreaseLoad, 8, 77.5},
+		{"assisted top of range", assisted, working(models.MeasurementAssisted, 30, 10, 10, 10), models.RecommendIncreaseLoad, 8, 27.5},
+		{"bodyweight top of range", bodyweight, working(models.MeasurementBodyweight, 0, 10, 11, 10), models.RecommendIncreaseReps, 11, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := DoubleProgression{}.Recommend(tt.prescription, tt.last, 2.5)
+			if rec.Action != tt.action || rec.Reps != tt.reps || math.Abs(rec.Load-tt.load) > 1e-9 || rec.Sets != 3 {
+				t.Errorf("Recommend() = %+v, want %s %d reps at %v", rec, tt.action, tt.reps, tt.load)
+			}
+		})
+	}
+}
+
+func TestRPEAutoregulation(t *testing.T) {
+	prescription := Prescription{MeasurementType: models.MeasurementWeighted, Sets: 3, Reps: "8-10", RPE: "9-10", Load: 60}
+	last := &models.UserProgressResponse{Sets: []models.UserExerciseSet{
+		{SetType: models.SetTypeWorking, Reps: 8, Load: 100, RPE: ptr(8.0)},
+		{SetType: models.SetTypeWorking, Reps: 8, Load: 100, RPE: ptr(8.0)},
+	}}
+
+	// 100 x 8 with 2 in reserve is an e1RM of 133.3, 8 reps at RPE 9.5 is 103.9
+	rec := RPEAutoregulation{}.Recommend(prescription, last, 2.5)
+	if rec.Action != models.RecommendIncreaseLoad || rec.Reps != 8 || rec.Load != 105 {
+		t.Errorf("Recommend() = %+v, want increase_load to 8 reps at 105", rec)
+	}
+
+	// no logged RPE follows the rep range
+	last.Sets[0].RPE, last.Sets[1].RPE = nil, nil
+	rec = RPEAutoregulation{}.Recommend(prescription, last, 2.5)
+	if rec.Action != models.RecommendIncreaseReps || rec.Reps != 9 || rec.Load != 100 {
+		t.Errorf("Recommend() without RPE = %+v, want double progression's increase_reps", rec)
+	}
+}
@@ -0,0 +1,111 @@
+package training
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+)
+
+// one rep max formulas
+const (
+	FormulaEpley    = "epley"
+	FormulaBrzycki  = "brzycki"
+	FormulaLombardi = "lombardi"
+)
+
+// trend bucket sizes
+const (
+	BucketDay   = "day"
+	BucketWeek  = "week"
+	BucketMonth = "month"
+)
+
+// whether formula is one of the one rep max formulas
+func IsFormula(formula string) bool {
+	switch formula {
+	case FormulaEpley, FormulaBrzycki, FormulaLombardi:
+		return true
+	}
+	return false
+}
+
+// the estimate of the one rep max from a set of reps at load by formula, 0
+// for an unknown formula
+func EstimateOneRepMaxWith(formula string, load float64, reps int) float64 {
+	if reps <= 0 || load <= 0 {
+		return 0
+	}
+	if reps == 1 {
+		return load
+	}
+	switch formula {
+	case FormulaEpley:
+		return load * (1 + float64(reps)/30)
+	case FormulaBrzycki:
+		// diverges at 37 reps, far past maxE1RMReps
+		return load * 36 / (37 - float64(reps))
+	case FormulaLombardi:
+		return load * math.Pow(float64(reps), 0.1)
+	default:
+		return 0
+	}
+}
+
+// E1RMSample is the e1RM of one logged session of an exercise
+type E1RMSample struct {
+	Date time.Time
+	E1RM float64
+}
+
+// the first day of the bucket holding date, weeks start on Monday
+func BucketStart(date time.Time, bucket string) time.Time {
+	year, month, day := date.Date()
+	switch bucket {
+	case BucketWeek:
+		// Sunday is the 7th day of an ISO week
+		weekday := (int(date.Weekday()) + 6) % 7
+		return time.Date(year, month, day-weekday, 0, 0, 0, 0, date.Location())
+	case BucketMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, date.Location())
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, date.Location())
+	}
+}
+
+// groups samples by bucket, oldest first, keeping each bucket's best e1RM
+// and its simple moving average over the last window buckets with samples.
+// Samples without an e1RM are left out.
+func Trend(samples []E1RMSample, bucket string, window int) []models.TrendPoint {
+	byStart := map[time.Time]*models.TrendPoint{}
+	var starts []time.Time
+	for _, sample := range samples {
+		if sample.E1RM <= 0 {
+			continue
+		}
+		start := BucketStart(sample.Date, bucket)
+		point, ok := byStart[start]
+		if !ok {
+			point = &models.TrendPoint{Start: start.Format("2006-01-02")}
+			byStart[start] = point
+			starts = append(starts, start)
+		}
+		point.E1RM = max(point.E1RM, sample.E1RM)
+		point.Sessions++
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+
+	window = max(window, 1)
+	points := make([]models.TrendPoint, len(starts))
+	sum := 0.0
+	for i, start := range starts {
+		points[i] = *byStart[start]
+		sum += points[i].E1RM
+		if i >= window {
+			sum -= points[i-window].E1RM
+		}
+		points[i].MovingAverage = sum / float64(min(i+1, window))
+	}
+	return points
+}
@@ -0,0 +1,44 @@
+package training
+
+import (
+	"math"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+)
+
+const kgPerLb = 0.45359237
+
+// converts a load entered in unit to kg
+func ToKg(load float64, unit string) float64 {
+	if unit == models.UnitLb {
+		return load * kgPerLb
+	}
+	return load
+}
+
+// converts a load in kg to unit, rounded to 2 decimals so loads entered in
+// unit come back as they were entered
+func FromKg(kg float64, unit string) float64 {
+	load := kg
+	if unit == models.UnitLb {
+		load = kg / kgPerLb
+	}
+	return math.Round(load*100) / 100
+}
+
+// rounds load to the nearest multiple of increment, e.g. what can be loaded
+// on a bar
+func RoundToPlates(load, increment float64) float64 {
+	if increment <= 0 {
+		return load
+	}
+	return math.Round(math.Round(load/increment)*increment*100) / 100
+}
+
+// the smallest common plate step of a unit
+func DefaultPlateIncrement(unit string) float64 {
+	if unit == models.UnitLb {
+		return 5
+	}
+	return 2.5
+}
@@ -0,0 +1,92 @@
+package training
+
+import (
+	"sort"
+	"time"
+
+	"github.com/haikali3/gymbara-backend/pkg/models"
+)
+
+// sets of at least this RPE are hard sets
+const hardSetRPE = 7
+
+// how much a set counts toward a secondary muscle's volume
+const secondaryMuscleShare = 0.5
+
+// the volume of entries for weeks consecutive weeks from the week holding
+// firstWeek, oldest first, tonnage in kg. Weeks without entries are zero.
+// Entries fall in the week of their submitted_at day.
+func WeeklySummaries(entries []models.UserProgressResponse, muscles map[int]models.ExerciseMuscles, firstWeek time.Time, weeks int) []models.WeeklySummary {
+	// entry days are parsed as UTC dates
+	year, month, day := firstWeek.Date()
+	start := BucketStart(time.Date(year, month, day, 0, 0, 0, 0, time.UTC), BucketWeek)
+	summaries := make([]models.WeeklySummary, weeks)
+	byMuscle := make([]map[string]*models.VolumeSummary, weeks)
+	for i := range summaries {
+		summaries[i].WeekStart = start.AddDate(0, 0, 7*i).Format("2006-01-02")
+		byMuscle[i] = map[string]*models.VolumeSummary{}
+	}
+
+	for _, entry := range entries {
+		day, err := time.Parse("2006-01-02", entry.SubmittedAt)
+		if err != nil {
+			continue
+		}
+		week := int(BucketStart(day, BucketWeek).Sub(start).Hours() / (24 * 7))
+		if week < 0 || week >= weeks {
+			continue
+		}
+
+		volume := entryVolume(entry)
+		addVolume(&summaries[week].Total, volume, 1)
+		exercise := muscles[entry.ExerciseID]
+		for _, tagged := range []struct {
+			names []string
+			share float64
+		}{{exercise.Primary, 1}, {exercise.Secondary, secondaryMuscleShare}} {
+			for _, muscle := range tagged.names {
+				if byMuscle[week][muscle] == nil {
+					byMuscle[week][muscle] = &models.VolumeSummary{}
+				}
+				addVolume(byMuscle[week][muscle], volume, tagged.share)
+			}
+		}
+	}
+
+	for i := range summaries {
+		summaries[i].Muscles = []models.MuscleVolume{}
+		for muscle, volume := range byMuscle[i] {
+			summaries[i].Muscles = append(summaries[i].Muscles, models.MuscleVolume{Muscle: muscle, VolumeSummary: *volume})
+		}
+		sort.Slice(summaries[i].Muscles, func(a, b int) bool {
+			return summaries[i].Muscles[a].Muscle < summaries[i].Muscles[b].Muscle
+		})
+	}
+	return summaries
+}
+
+// the volume of an entry's non-warmup sets
+func entryVolume(entry models.UserProgressResponse) models.VolumeSummary {
+	var volume models.VolumeSummary
+	for _, set := range entry.Sets {
+		if set.SetType == models.SetTypeWarmup {
+			continue
+		}
+		volume.Sets++
+		volume.Reps += float64(set.Reps)
+		if load, ok := EffectiveLoad(entry.MeasurementType, set); ok {
+			volume.Tonnage += float64(set.Reps) * load
+		}
+		if set.RPE == nil || *set.RPE >= hardSetRPE {
+			volume.HardSets++
+		}
+	}
+	return volume
+}
+
+func addVolume(total *models.VolumeSummary, volume models.VolumeSummary, share float64) {
+	total.Sets += volume.Sets * share
+	total.Reps += volume.Reps * share
+	total.Tonnage += volume.Tonnage * share
+	total.HardSets += volume.HardSets * share
+}
@@ -3,6 +3,7 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 
@@ -80,3 +81,17 @@ func WriteStandardResponse(w http.ResponseWriter, statusCode int, message string
 		)
 	}
 }
+
+// ClientIP returns the caller's IP, preferring the first X-Forwarded-For hop
+// set by our proxy. Only use it for display and logging, it can be spoofed.
+func ClientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(first)
+	}
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}